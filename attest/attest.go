@@ -2,33 +2,105 @@ package attest
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/errordeveloper/tape/attest/digest"
 	"github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
 	"github.com/errordeveloper/tape/attest/vcs/git"
+	"github.com/errordeveloper/tape/attest/vcs/hg"
+	"github.com/errordeveloper/tape/attest/vcs/jj"
 )
 
 var (
 	_ types.PathChecker = (*git.PathChecker)(nil)
+	_ types.PathChecker = (*hg.PathChecker)(nil)
+	_ types.PathChecker = (*jj.PathChecker)(nil)
+	_ types.PathChecker = (*dir.PathChecker)(nil)
 )
 
+type vcsProvider struct {
+	name string
+	// newPathCheckerFactory is called for each root, so that checkers of the
+	// same root may share state
+	newPathCheckerFactory func() func(string, digest.SHA256) types.PathChecker
+}
+
+func stateless(newPathChecker func(string, digest.SHA256) types.PathChecker) func() func(string, digest.SHA256) types.PathChecker {
+	return func() func(string, digest.SHA256) types.PathChecker { return newPathChecker }
+}
+
+// order matters, as Jujutsu repos are often colocated with git,
+// it needs to be detected first
+var vcsProviders = []vcsProvider{
+	{jj.ProviderName, stateless(jj.NewPathChecker)},
+	{git.ProviderName, stateless(git.NewPathChecker)},
+	{hg.ProviderName, hg.NewPathCheckerFactory},
+}
+
+// DetectVCS is the same as DetectVCSWithFallback with empty source information
 func DetectVCS(path string) (bool, *PathCheckerRegistry, error) {
-	for _, provider := range map[string]func(string, digest.SHA256) types.PathChecker{
-		// TODO: support other VCS providers
-		git.ProviderName: git.NewPathChecker,
-	} {
-		checker := provider(path, "")
+	return DetectVCSWithFallback(path, dir.Source{})
+}
+
+// DetectVCSWithFallback checks if path is in any of the supported VCS, when it's
+// not, plain directory provider is used with given source information, so that
+// a registry is always returned along with an indication of VCS being detected
+func DetectVCSWithFallback(path string, source dir.Source) (bool, *PathCheckerRegistry, error) {
+	return DetectVCSForRoots([]Root{{Path: path, IsDir: true}}, source)
+}
+
+// DetectVCSForRoots is like DetectVCSWithFallback, but VCS is detected for each of
+// the roots separately, as these may reside in different repos; the indication is
+// only true when all of the roots are in VCS
+func DetectVCSForRoots(roots []Root, source dir.Source) (bool, *PathCheckerRegistry, error) {
+	registry := newEmptyPathCheckerRegistry()
+	allDetected := true
+	for i := range roots {
+		detected, err := registry.addRoot(roots[i], source)
+		if err != nil {
+			return false, nil, err
+		}
+		allDetected = allDetected && detected
+	}
+	return allDetected, registry, nil
+}
+
+func (r *PathCheckerRegistry) addRoot(contentRoot Root, source dir.Source) (bool, error) {
+	path := contentRoot.Path
+	if !contentRoot.IsDir {
+		path = filepath.Dir(contentRoot.Path)
+	}
+	if !contentRoot.Source.IsZero() {
+		source = contentRoot.Source
+	}
+	// content read from stdin is stored in a temporary directory, which
+	// is never meant to be in VCS
+	if contentRoot.Stdin {
+		newPathChecker := dir.NewPathCheckerFactory(path, source)
+		return false, r.initRoot(contentRoot, path, newPathChecker, newPathChecker(path, ""))
+	}
+	for _, provider := range vcsProviders {
+		newPathChecker := provider.newPathCheckerFactory()
+		checker := newPathChecker(path, "")
 		ok, err := checker.DetectRepo()
 		if err != nil {
-			return false, nil, fmt.Errorf("unable to detect VCS: %w", err)
+			return false, fmt.Errorf("unable to detect VCS (%s): %w", provider.name, err)
 		}
 		if ok {
-			registry := NewPathCheckerRegistry(path, provider)
-			if err := registry.init(checker); err != nil {
-				return false, nil, err
-			}
-			return true, registry, nil
+			return true, r.initRoot(contentRoot, path, newPathChecker, checker)
 		}
 	}
-	return false, nil, nil
+
+	newPathChecker := dir.NewPathCheckerFactory(path, source)
+	return false, r.initRoot(contentRoot, path, newPathChecker, newPathChecker(path, ""))
+}
+
+func (r *PathCheckerRegistry) initRoot(contentRoot Root, path string, newPathChecker func(string, digest.SHA256) types.PathChecker, checker types.PathChecker) error {
+	r.roots = append(r.roots, &root{
+		Root:           contentRoot,
+		newPathChecker: newPathChecker,
+		baseDir:        baseDir{fromWorkDir: path},
+	})
+	return r.init(checker)
 }
@@ -35,6 +35,12 @@ type ImageRefenceWithLocation struct {
 	Line      int     `json:"line"`
 	Column    int     `json:"column"`
 	Alias     *string `json:"alias,omitempty"`
+	// Mirror is the reference digest was actually resolved from,
+	// it is only set when a mirror was used
+	Mirror string `json:"mirror,omitempty"`
+	// CopyMirror is the reference image was actually copied from,
+	// it is only set when a mirror was used
+	CopyMirror string `json:"copyMirror,omitempty"`
 }
 
 // TODO:
@@ -44,7 +50,7 @@ type ImageRefenceWithLocation struct {
 
 func MakeOriginalImageRefStatements(images *manifestTypes.ImageList) attestTypes.Statements {
 	statements := attestTypes.Statements{}
-	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation) {
+	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation, _ manifestTypes.Image) {
 		s := &OriginalImageRef{
 			attestTypes.MakeStatement(
 				OriginalImageRefPredicateType,
@@ -61,7 +67,7 @@ func MakeOriginalImageRefStatements(images *manifestTypes.ImageList) attestTypes
 
 func MakeReplacedImageRefStatements(images *manifestTypes.ImageList) attestTypes.Statements {
 	statements := attestTypes.Statements{}
-	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation) {
+	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation, _ manifestTypes.Image) {
 		statements = append(statements, &ReplacedImageRef{
 			attestTypes.MakeStatement(
 				ReplacedImageRefPredicateType,
@@ -77,7 +83,10 @@ func MakeReplacedImageRefStatements(images *manifestTypes.ImageList) attestTypes
 
 func MakeResovedImageRefStatements(images *manifestTypes.ImageList) attestTypes.Statements {
 	statements := attestTypes.Statements{}
-	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation) {
+	forEachImage(images, func(subject attestTypes.Subject, ref ImageRefenceWithLocation, image manifestTypes.Image) {
+		// only resolved references are affected by mirrors
+		ref.Mirror = image.MirrorRef
+		ref.CopyMirror = image.CopyMirrorRef
 		statements = append(statements, &ResolvedImageRef{
 			attestTypes.MakeStatement(
 				ResolvedImageRefPredicateType,
@@ -91,7 +100,7 @@ func MakeResovedImageRefStatements(images *manifestTypes.ImageList) attestTypes.
 	return statements
 }
 
-func forEachImage(images *manifestTypes.ImageList, do func(attestTypes.Subject, ImageRefenceWithLocation)) {
+func forEachImage(images *manifestTypes.ImageList, do func(attestTypes.Subject, ImageRefenceWithLocation, manifestTypes.Image)) {
 	for _, image := range images.Items() {
 		for _, source := range image.Sources {
 			do(
@@ -105,6 +114,7 @@ func forEachImage(images *manifestTypes.ImageList, do func(attestTypes.Subject,
 					Column:    source.Column,
 					Alias:     image.Alias,
 				},
+				image,
 			)
 		}
 	}
@@ -120,5 +130,11 @@ func (a ImageRefenceWithLocation) Compare(b ImageRefenceWithLocation) attestType
 	if cmp := cmp.Compare(a.Column, b.Column); cmp != 0 {
 		return &cmp
 	}
+	if cmp := cmp.Compare(a.Mirror, b.Mirror); cmp != 0 {
+		return &cmp
+	}
+	if cmp := cmp.Compare(a.CopyMirror, b.CopyMirror); cmp != 0 {
+		return &cmp
+	}
 	return attestTypes.CmpEqual()
 }
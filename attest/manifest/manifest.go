@@ -2,13 +2,21 @@ package manifest
 
 import (
 	"cmp"
+	"slices"
 
 	"github.com/errordeveloper/tape/attest/types"
 	attestTypes "github.com/errordeveloper/tape/attest/types"
 )
 
 const (
-	ManifestDirPredicateType = "docker.com/tape/ManifestDir/v0.2"
+	ManifestDirPredicateType = "docker.com/tape/ManifestDir/v0.3"
+	// ManifestDirV02PredicateType is the type of statements of older artefacts, where
+	// names of subjects are not qualified with the root even if there are multiple roots
+	ManifestDirV02PredicateType = "docker.com/tape/ManifestDir/v0.2"
+
+	// SourceRootSeparator separates prefix of the root from the path in names of
+	// subjects when there are multiple roots, i.e. '<prefix>//<path>'
+	SourceRootSeparator = "//"
 )
 
 var (
@@ -20,23 +28,46 @@ type DirContents struct {
 }
 
 type SourceDirectory struct {
+	// Path is the directory that content was loaded from, when it was loaded
+	// from multiple directories or files, it's the first of Roots
 	Path string `json:"path"`
+	// Stdin is set when content of Path was read from stdin
+	Stdin bool `json:"stdin,omitempty"`
+	// Roots lists all directories and files that content was loaded from
+	Roots []SourceRoot `json:"roots,omitempty"`
 
 	VCSEntries *types.PathCheckSummaryCollection `json:"vcsEntries"`
+
+	// Excluded lists paths that were left out, directories have a trailing slash
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// SourceRoot is a directory or a file that content was loaded from,
+// Prefix is where it's placed in the artefact, and it qualifies names
+// of subjects, as paths of different roots may be the same; Stdin is
+// set when it was read from stdin
+type SourceRoot struct {
+	Path   string `json:"path"`
+	Prefix string `json:"prefix"`
+	Stdin  bool   `json:"stdin,omitempty"`
 }
 
 type SourceDirectoryContents struct {
 	SourceDirectory `json:"containedInDirectory"`
 }
 
-func MakeDirContentsStatement(dir string, entries *types.PathCheckSummaryCollection) types.Statement {
+// MakeDirContentsStatement makes a statement about content loaded from a single root,
+// prefix of the root is not recorded, as it doesn't qualify names of subjects
+func MakeDirContentsStatement(root SourceRoot, entries *types.PathCheckSummaryCollection, excluded []string) types.Statement {
 	return &DirContents{
 		types.MakeStatement[SourceDirectoryContents](
 			ManifestDirPredicateType,
 			SourceDirectoryContents{
 				SourceDirectory: SourceDirectory{
-					Path:       dir,
+					Path:       root.Path,
+					Stdin:      root.Stdin,
 					VCSEntries: entries,
+					Excluded:   excluded,
 				},
 			},
 			entries.Subject()...,
@@ -44,6 +75,25 @@ func MakeDirContentsStatement(dir string, entries *types.PathCheckSummaryCollect
 	}
 }
 
+// MakeMultiRootDirContentsStatement takes subjects separately, as their names are qualified with the root
+func MakeMultiRootDirContentsStatement(roots []SourceRoot, entries *types.PathCheckSummaryCollection, excluded []string, subjects ...types.Subject) types.Statement {
+	return &DirContents{
+		types.MakeStatement[SourceDirectoryContents](
+			ManifestDirPredicateType,
+			SourceDirectoryContents{
+				SourceDirectory: SourceDirectory{
+					Path:       roots[0].Path,
+					Stdin:      roots[0].Stdin,
+					Roots:      roots,
+					VCSEntries: entries,
+					Excluded:   excluded,
+				},
+			},
+			subjects...,
+		),
+	}
+}
+
 func MakeDirContentsStatementFrom(statement types.Statement) DirContents {
 	dirContents := DirContents{
 		GenericStatement: attestTypes.GenericStatement[SourceDirectoryContents]{},
@@ -56,13 +106,40 @@ func (a SourceDirectory) Compare(b SourceDirectory) types.Cmp {
 	if cmp := cmp.Compare(a.Path, b.Path); cmp != 0 {
 		return &cmp
 	}
+	if a.Stdin != b.Stdin {
+		if !a.Stdin {
+			return types.CmpLess()
+		}
+		return types.CmpMore()
+	}
 	if a.VCSEntries == nil && b.VCSEntries != nil {
 		return types.CmpLess()
 	}
 	if a.VCSEntries != nil && b.VCSEntries == nil {
 		return types.CmpMore()
 	}
-	cmp := a.VCSEntries.Compare(*b.VCSEntries)
+	if cmp := a.VCSEntries.Compare(*b.VCSEntries); cmp != 0 {
+		return &cmp
+	}
+	if cmp := slices.CompareFunc(a.Roots, b.Roots, func(a, b SourceRoot) int {
+		if cmp := cmp.Compare(a.Path, b.Path); cmp != 0 {
+			return cmp
+		}
+		if cmp := cmp.Compare(a.Prefix, b.Prefix); cmp != 0 {
+			return cmp
+		}
+		switch {
+		case a.Stdin == b.Stdin:
+			return 0
+		case !a.Stdin:
+			return -1
+		default:
+			return 1
+		}
+	}); cmp != 0 {
+		return &cmp
+	}
+	cmp := slices.Compare(a.Excluded, b.Excluded)
 	return &cmp
 }
 
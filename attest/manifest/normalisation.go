@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"cmp"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/normaliser"
+)
+
+const (
+	ManifestNormalisationPredicateType = "docker.com/tape/ManifestNormalisation/v0.1"
+)
+
+var (
+	_ attestTypes.Statement = (*ManifestNormalisation)(nil)
+)
+
+type ManifestNormalisation struct {
+	attestTypes.GenericStatement[NormalisedObjects]
+}
+
+type NormalisedObjects struct {
+	Objects []normaliser.Object `json:"objects"`
+}
+
+// MakeNormalisationStatements makes a statement for each original manifest, which
+// maps position of each object in the manifest to the file it was written to
+func MakeNormalisationStatements(results []normaliser.Result) attestTypes.Statements {
+	statements := attestTypes.Statements{}
+	for _, result := range results {
+		statements = append(statements, &ManifestNormalisation{
+			attestTypes.MakeStatement(
+				ManifestNormalisationPredicateType,
+				struct {
+					NormalisedObjects `json:"normalisedObjects"`
+				}{NormalisedObjects{
+					Objects: result.Objects,
+				}},
+				attestTypes.Subject{
+					Name:   result.Manifest,
+					Digest: result.ManifestDigest,
+				},
+			),
+		})
+	}
+	return statements
+}
+
+func (a NormalisedObjects) Compare(b NormalisedObjects) attestTypes.Cmp {
+	if cmp := cmp.Compare(len(a.Objects), len(b.Objects)); cmp != 0 {
+		return &cmp
+	}
+	for i := range a.Objects {
+		if cmp := cmp.Compare(a.Objects[i].Line, b.Objects[i].Line); cmp != 0 {
+			return &cmp
+		}
+		if cmp := cmp.Compare(a.Objects[i].Column, b.Objects[i].Column); cmp != 0 {
+			return &cmp
+		}
+		if cmp := cmp.Compare(a.Objects[i].Path, b.Objects[i].Path); cmp != 0 {
+			return &cmp
+		}
+	}
+	return attestTypes.CmpEqual()
+}
@@ -0,0 +1,87 @@
+package manifest
+
+import (
+	"cmp"
+	"slices"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/secretscanner"
+)
+
+const (
+	SecretsScanPredicateType = "docker.com/tape/SecretsScan/v0.1"
+)
+
+var (
+	_ attestTypes.Statement = (*SecretsScan)(nil)
+)
+
+type SecretsScan struct {
+	attestTypes.GenericStatement[SecretsScanFindings]
+}
+
+type SecretsScanFindings struct {
+	// AllowedBy records why findings didn't prevent packaging, it's only set when there are findings
+	AllowedBy *SecretsScanAllowance   `json:"allowedBy,omitempty"`
+	Findings  []secretscanner.Finding `json:"findings"`
+}
+
+// SecretsScanAllowance is set when all findings were allowed, otherwise it lists rules
+// that findings were allowed for
+type SecretsScanAllowance struct {
+	All   bool     `json:"all,omitempty"`
+	Rules []string `json:"rules,omitempty"`
+}
+
+// MakeSecretsScanStatements makes a statement for each manifest that was scanned, findings
+// only include redacted values; findings of a manifest are recorded as allowed by all of
+// them being allowed, or by the allowed rules that these match
+func MakeSecretsScanStatements(allowAll bool, allowedRules []string, results []secretscanner.Result) attestTypes.Statements {
+	statements := attestTypes.Statements{}
+	for _, result := range results {
+		var allowedBy *SecretsScanAllowance
+		if len(result.Findings) != 0 {
+			allowedBy = &SecretsScanAllowance{All: allowAll}
+			for _, finding := range result.Findings {
+				if slices.Contains(allowedRules, finding.Rule) && !slices.Contains(allowedBy.Rules, finding.Rule) {
+					allowedBy.Rules = append(allowedBy.Rules, finding.Rule)
+				}
+			}
+			slices.Sort(allowedBy.Rules)
+		}
+		statements = append(statements, &SecretsScan{
+			attestTypes.MakeStatement(
+				SecretsScanPredicateType,
+				struct {
+					SecretsScanFindings `json:"secretsScan"`
+				}{SecretsScanFindings{
+					AllowedBy: allowedBy,
+					Findings:  result.Findings,
+				}},
+				attestTypes.Subject{
+					Name:   result.Manifest,
+					Digest: result.ManifestDigest,
+				},
+			),
+		})
+	}
+	return statements
+}
+
+func (a SecretsScanFindings) Compare(b SecretsScanFindings) attestTypes.Cmp {
+	if cmp := cmp.Compare(len(a.Findings), len(b.Findings)); cmp != 0 {
+		return &cmp
+	}
+	for i := range a.Findings {
+		if cmp := cmp.Compare(a.Findings[i].Line, b.Findings[i].Line); cmp != 0 {
+			return &cmp
+		}
+		if cmp := cmp.Compare(a.Findings[i].Column, b.Findings[i].Column); cmp != 0 {
+			return &cmp
+		}
+		if cmp := cmp.Compare(a.Findings[i].Rule, b.Findings[i].Rule); cmp != 0 {
+			return &cmp
+		}
+	}
+	return attestTypes.CmpEqual()
+}
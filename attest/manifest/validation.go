@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"cmp"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/validator"
+)
+
+const (
+	ManifestValidationPredicateType = "docker.com/tape/ManifestValidation/v0.1"
+)
+
+var (
+	_ attestTypes.Statement = (*ManifestValidation)(nil)
+)
+
+type ManifestValidation struct {
+	attestTypes.GenericStatement[ValidatedResources]
+}
+
+type ValidatedResources struct {
+	KubernetesVersion string               `json:"kubernetesVersion"`
+	Resources         []validator.Resource `json:"resources"`
+}
+
+// MakeValidationStatements makes a statement for each manifest that passed validation,
+// resources that had no schema are listed without schema name
+func MakeValidationStatements(kubernetesVersion string, results []validator.Result) attestTypes.Statements {
+	statements := attestTypes.Statements{}
+	for _, result := range results {
+		statements = append(statements, &ManifestValidation{
+			attestTypes.MakeStatement(
+				ManifestValidationPredicateType,
+				struct {
+					ValidatedResources `json:"validatedResources"`
+				}{ValidatedResources{
+					KubernetesVersion: kubernetesVersion,
+					Resources:         result.Resources,
+				}},
+				attestTypes.Subject{
+					Name:   result.Manifest,
+					Digest: result.ManifestDigest,
+				},
+			),
+		})
+	}
+	return statements
+}
+
+func (a ValidatedResources) Compare(b ValidatedResources) attestTypes.Cmp {
+	if cmp := cmp.Compare(a.KubernetesVersion, b.KubernetesVersion); cmp != 0 {
+		return &cmp
+	}
+	if cmp := cmp.Compare(len(a.Resources), len(b.Resources)); cmp != 0 {
+		return &cmp
+	}
+	for i := range a.Resources {
+		if cmp := cmp.Compare(a.Resources[i].Line, b.Resources[i].Line); cmp != 0 {
+			return &cmp
+		}
+		if cmp := cmp.Compare(a.Resources[i].Column, b.Resources[i].Column); cmp != 0 {
+			return &cmp
+		}
+	}
+	return attestTypes.CmpEqual()
+}
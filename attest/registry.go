@@ -7,19 +7,41 @@ import (
 	"io"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/errordeveloper/tape/attest/digest"
 	"github.com/errordeveloper/tape/attest/manifest"
 	"github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
 	"github.com/fxamacker/cbor/v2"
 )
 
 type PathCheckerRegistry struct {
-	newPathChecker func(string, digest.SHA256) types.PathChecker
-
 	registry     map[types.PathCheckerRegistryKey]types.PathChecker
 	mutatedPaths types.Mutations
+	normalised   map[types.PathCheckerRegistryKey]struct{}
 	statements   types.Statements
+	excluded     []string
+
+	roots []*root
+}
+
+// Root is a directory or a file that content was loaded from, Prefix is
+// where it's placed in the content directory, paths that are passed to
+// the registry are relative to the content directory; Stdin is set when content
+// was read from stdin, and Source is recorded instead of the source that applies
+// to all roots when the root is not in VCS
+type Root struct {
+	Path   string
+	Prefix string
+	IsDir  bool
+	Stdin  bool
+	Source dir.Source
+}
+
+type root struct {
+	Root
+	newPathChecker func(string, digest.SHA256) types.PathChecker
 
 	baseDir
 }
@@ -32,38 +54,61 @@ type baseDir struct {
 }
 
 func NewPathCheckerRegistry(dir string, newPathChecker func(string, digest.SHA256) types.PathChecker) *PathCheckerRegistry {
-	return &PathCheckerRegistry{
-		baseDir:        baseDir{fromWorkDir: dir},
+	registry := newEmptyPathCheckerRegistry()
+	registry.roots = append(registry.roots, &root{
+		Root:           Root{Path: dir, IsDir: true},
 		newPathChecker: newPathChecker,
-		registry:       map[types.PathCheckerRegistryKey]types.PathChecker{},
-		statements:     types.Statements{},
+		baseDir:        baseDir{fromWorkDir: dir},
+	})
+	return registry
+}
+
+func newEmptyPathCheckerRegistry() *PathCheckerRegistry {
+	return &PathCheckerRegistry{
+		registry:   map[types.PathCheckerRegistryKey]types.PathChecker{},
+		statements: types.Statements{},
 	}
 }
 
+// BaseDirSummary returns summary of the first root
 func (r *PathCheckerRegistry) BaseDirSummary() types.PathCheckSummary {
-	return r.baseDir.cachedSummary
+	return r.roots[0].cachedSummary
+}
+
+// BaseDirSummaries returns summaries of all roots
+func (r *PathCheckerRegistry) BaseDirSummaries() []types.PathCheckSummary {
+	summaries := make([]types.PathCheckSummary, len(r.roots))
+	for i := range r.roots {
+		summaries[i] = r.roots[i].cachedSummary
+	}
+	return summaries
 }
 
 func (r *PathCheckerRegistry) init(baseDirChecker types.PathChecker) error {
+	root := r.roots[len(r.roots)-1]
 	summary, err := baseDirChecker.MakeSummary()
 	if err != nil {
-		return fmt.Errorf("unable to make summary for %#v: %w", r.dir(), err)
+		return fmt.Errorf("unable to make summary for %#v: %w", root.dir(), err)
 	}
-	r.baseDir = baseDir{
+	root.baseDir = baseDir{
 		pathChecker:   baseDirChecker,
 		cachedSummary: summary,
 		fromRepoRoot:  summary.Common().Path,
-		fromWorkDir:   r.fromWorkDir,
+		fromWorkDir:   root.fromWorkDir,
 	}
 	return nil
 }
 
 func (r *PathCheckerRegistry) Register(path string, digest digest.SHA256) error {
-	key := r.makeKey(r.pathFromRepoRoot(path), digest)
+	root, pathInRoot := r.resolve(path)
+	if root == nil {
+		return fmt.Errorf("path %q is not in any of the roots", path)
+	}
+	key := r.makeKey(r.subjectName(root, pathInRoot), digest)
 	if _, ok := r.registry[key]; ok {
-		return fmt.Errorf("path checker already reigstered for %#v", key)
+		return fmt.Errorf("path checker already registered for %#v", key)
 	}
-	r.registry[key] = r.newPathChecker(r.pathFromWorkDir(path), digest)
+	r.registry[key] = root.newPathChecker(root.pathFromWorkDir(pathInRoot), digest)
 	return nil
 }
 
@@ -71,18 +116,43 @@ func (r *PathCheckerRegistry) RegisterMutated(mutatedPaths types.Mutations) {
 	r.mutatedPaths = make(types.Mutations, len(mutatedPaths)) // avoid stale entries
 	for k := range mutatedPaths {
 		oldDigest := mutatedPaths[k]
-		k.Path = r.pathFromRepoRoot(k.Path)
+		k.Path = r.subjectNameFromContentDir(k.Path)
 		r.mutatedPaths[k] = oldDigest
 	}
 }
 
+// RegisterNormalised records files that were written by normalisation, as these
+// are not present in the source, statements about these refer to paths within
+// the artefact
+func (r *PathCheckerRegistry) RegisterNormalised(subjects ...types.Subject) {
+	r.normalised = make(map[types.PathCheckerRegistryKey]struct{}, len(subjects))
+	for _, subject := range subjects {
+		r.normalised[r.makeKey(subject.Name, subject.Digest)] = struct{}{}
+	}
+}
+
+// SetExcludedPaths records paths that were excluded from the directory,
+// so that these are listed in the directory contents statement
+func (r *PathCheckerRegistry) SetExcludedPaths(paths []string) {
+	r.excluded = make([]string, len(paths))
+	for i := range paths {
+		r.excluded[i] = r.pathFromRepoRoot(paths[i])
+		if strings.HasSuffix(paths[i], "/") {
+			r.excluded[i] += "/"
+		}
+	}
+}
+
 func (r *PathCheckerRegistry) AssociateStatements(statements ...types.Statement) error {
 	for i := range statements {
 		if err := statements[i].SetSubjects(func(subject *types.Subject) error {
-			path := r.pathFromRepoRoot(subject.Name)
+			if _, ok := r.normalised[r.makeKey(subject.Name, subject.Digest)]; ok {
+				return nil
+			}
+			path := r.subjectNameFromContentDir(subject.Name)
 			key := r.makeKey(path, subject.Digest)
 			if _, ok := r.registry[key]; !ok {
-				err := fmt.Errorf("statement with subject %#v is not relevant (path resoved to %q)", subject, path)
+				err := fmt.Errorf("statement with subject %#v is not relevant (path resolved to %q)", subject, path)
 				if r.mutatedPaths == nil {
 					return err
 				}
@@ -101,12 +171,14 @@ func (r *PathCheckerRegistry) AssociateStatements(statements ...types.Statement)
 }
 
 func (r *PathCheckerRegistry) MakePathCheckSummarySummaryCollection() (*types.PathCheckSummaryCollection, error) {
-	numEntries := len(r.registry) + 1
+	numEntries := len(r.registry) + len(r.roots)
 	if numEntries == 0 {
 		return nil, nil
 	}
-	entries := make([]types.PathChecker, 1, numEntries)
-	entries[0] = r.baseDir.pathChecker
+	entries := make([]types.PathChecker, 0, numEntries)
+	for i := range r.roots {
+		entries = append(entries, r.roots[i].pathChecker)
+	}
 	for key := range r.registry {
 		entries = append(entries, r.registry[key])
 	}
@@ -124,7 +196,22 @@ func (r *PathCheckerRegistry) AssociateCoreStatements() error {
 	// this flow is different from AssociateCoreStatements, as path to
 	// files is always relative to repo root and statement.SetSubjects
 	// doesn't need to be called
-	statement := manifest.MakeDirContentsStatement(r.dir(), entries)
+	roots := make([]manifest.SourceRoot, len(r.roots))
+	for i := range r.roots {
+		roots[i] = manifest.SourceRoot{Path: r.roots[i].dir(), Prefix: r.roots[i].Prefix, Stdin: r.roots[i].Stdin}
+	}
+	statement := manifest.MakeDirContentsStatement(roots[0], entries, r.excluded)
+	if len(r.roots) > 1 {
+		// paths in summaries don't indicate which root these belong to, names
+		// of subjects have to be qualified with the root
+		subjects := make([]types.Subject, 0, len(r.registry))
+		for key := range r.registry {
+			if key.Digest != "" {
+				subjects = append(subjects, types.MakeSubject(key.Path, key.Digest))
+			}
+		}
+		statement = manifest.MakeMultiRootDirContentsStatement(roots, entries, r.excluded, subjects...)
+	}
 	for _, subject := range statement.GetSubject() {
 		key := r.makeKey(subject.Name, subject.Digest)
 		if _, ok := r.registry[key]; !ok {
@@ -169,22 +256,71 @@ func (r *PathCheckerRegistry) GetStatements() types.Statements {
 	return r.statements
 }
 
-func (r *PathCheckerRegistry) dir() string {
+// dir returns path of the root from repo root, for a file root it's the
+// path to the file
+func (r *root) dir() string {
+	dir := "."
 	switch {
-	case r.baseDir.fromRepoRoot != "":
-		return r.baseDir.fromRepoRoot
-	case r.baseDir.fromWorkDir != "":
-		return r.baseDir.fromWorkDir
-	default:
-		return "."
+	case r.fromRepoRoot != "":
+		dir = r.fromRepoRoot
+	case r.fromWorkDir != "":
+		dir = r.fromWorkDir
 	}
+	if !r.IsDir {
+		return filepath.Join(dir, filepath.Base(r.Path))
+	}
+	return dir
+}
+
+// resolve finds the root that given path belongs to, and returns
+// the path relative to directory of the root
+func (r *PathCheckerRegistry) resolve(path string) (*root, string) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, root := range r.roots {
+		switch {
+		case root.Prefix == "":
+			return root, path
+		case !root.IsDir && path == root.Prefix:
+			return root, filepath.Base(root.Path)
+		case root.IsDir && strings.HasPrefix(path, root.Prefix+"/"):
+			return root, strings.TrimPrefix(path, root.Prefix+"/")
+		}
+	}
+	return nil, path
 }
 
 func (r *PathCheckerRegistry) pathFromRepoRoot(path string) string {
+	root, pathInRoot := r.resolve(path)
+	if root == nil {
+		return path
+	}
+	return root.pathFromRepoRoot(pathInRoot)
+}
+
+// subjectName returns name of the subject for a path in the root, it's relative to repo
+// root, and when there are multiple roots it's qualified with prefix of the root as
+// '<prefix>//<path>', since roots may reside at the same path in different repos
+func (r *PathCheckerRegistry) subjectName(root *root, pathInRoot string) string {
+	path := root.pathFromRepoRoot(pathInRoot)
+	if len(r.roots) > 1 {
+		return root.Prefix + manifest.SourceRootSeparator + filepath.ToSlash(path)
+	}
+	return path
+}
+
+func (r *PathCheckerRegistry) subjectNameFromContentDir(path string) string {
+	root, pathInRoot := r.resolve(path)
+	if root == nil {
+		return path
+	}
+	return r.subjectName(root, pathInRoot)
+}
+
+func (r *root) pathFromRepoRoot(path string) string {
 	return filepath.Join(r.fromRepoRoot, path)
 }
 
-func (r *PathCheckerRegistry) pathFromWorkDir(path string) string {
+func (r *root) pathFromWorkDir(path string) string {
 	return filepath.Join(r.fromWorkDir, path)
 }
 
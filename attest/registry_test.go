@@ -6,6 +6,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -13,6 +15,7 @@ import (
 
 	. "github.com/errordeveloper/tape/attest"
 	"github.com/errordeveloper/tape/attest/manifest"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
 	"github.com/errordeveloper/tape/manifest/imageresolver"
 	"github.com/errordeveloper/tape/manifest/imagescanner"
 	"github.com/errordeveloper/tape/manifest/loader"
@@ -99,3 +102,139 @@ func makeRegistryTest(tc testdata.TestCase) func(t *testing.T) {
 		}
 	}
 }
+
+func TestRegistryWithMultipleRoots(t *testing.T) {
+	g := NewWithT(t)
+
+	tempDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(tempDir, "namespace.yaml"), []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: test\n"), 0o644)).To(Succeed())
+
+	workDir, err := os.Getwd()
+	g.Expect(err).NotTo(HaveOccurred())
+	tempDirPath, err := filepath.Rel(workDir, tempDir)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	loader := loader.NewMultiPathLoader("../manifest/testdata/basic", filepath.Join(tempDirPath, "namespace.yaml"))
+	g.Expect(loader.Load()).To(Succeed())
+	defer loader.Cleanup()
+
+	roots := []Root{}
+	for _, root := range loader.Roots() {
+		roots = append(roots, Root{Path: root.Path, Prefix: root.Prefix, IsDir: root.IsDir})
+	}
+	repoDetected, attreg, err := DetectVCSForRoots(roots, dir.Source{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repoDetected).To(BeFalse())
+	g.Expect(attreg.BaseDirSummaries()).To(HaveLen(2))
+
+	scanner := imagescanner.NewDefaultImageScanner()
+	scanner.WithProvinanceAttestor(attreg)
+	g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+
+	collection, err := attreg.MakePathCheckSummarySummaryCollection()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(collection.Providers).To(ConsistOf("dir", "git"))
+	g.Expect(collection.EntryGroups).To(HaveLen(2))
+
+	g.Expect(attreg.AssociateCoreStatements()).To(Succeed())
+	g.Expect(attreg.AssociateStatements(manifest.MakeOriginalImageRefStatements(scanner.GetImages())...)).To(Succeed())
+
+	dirContents := manifest.MakeDirContentsStatementFrom(attreg.GetStatements()[0])
+	predicate := dirContents.GetPredicate().(manifest.SourceDirectoryContents)
+	g.Expect(predicate.Path).To(Equal("manifest/testdata/basic"))
+	g.Expect(predicate.Roots).To(Equal([]manifest.SourceRoot{
+		{Path: "manifest/testdata/basic", Prefix: "basic"},
+		{Path: "namespace.yaml", Prefix: "namespace.yaml"},
+	}))
+
+	subjects := []string{}
+	for _, subject := range dirContents.GetSubject() {
+		subjects = append(subjects, subject.Name)
+	}
+	g.Expect(subjects).To(ContainElements(
+		"basic//manifest/testdata/basic/deployment.json",
+		"basic//manifest/testdata/basic/list.json",
+		"namespace.yaml//namespace.yaml",
+	))
+}
+
+func TestRegistryWithMultipleRootsAtSamePath(t *testing.T) {
+	g := NewWithT(t)
+
+	workDir, err := os.Getwd()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	paths := []string{}
+	for _, name := range []string{"a", "b"} {
+		tempDir := filepath.Join(t.TempDir(), name)
+		g.Expect(os.Mkdir(tempDir, 0o755)).To(Succeed())
+		data := []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: test\n")
+		g.Expect(os.WriteFile(filepath.Join(tempDir, "namespace.yaml"), data, 0o644)).To(Succeed())
+		tempDirPath, err := filepath.Rel(workDir, tempDir)
+		g.Expect(err).NotTo(HaveOccurred())
+		paths = append(paths, tempDirPath)
+	}
+
+	loader := loader.NewMultiPathLoader(paths...)
+	g.Expect(loader.Load()).To(Succeed())
+	defer loader.Cleanup()
+
+	roots := []Root{}
+	for _, root := range loader.Roots() {
+		roots = append(roots, Root{Path: root.Path, Prefix: root.Prefix, IsDir: root.IsDir})
+	}
+	_, attreg, err := DetectVCSForRoots(roots, dir.Source{})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	scanner := imagescanner.NewDefaultImageScanner()
+	scanner.WithProvinanceAttestor(attreg)
+	g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+
+	g.Expect(attreg.AssociateCoreStatements()).To(Succeed())
+
+	dirContents := manifest.MakeDirContentsStatementFrom(attreg.GetStatements()[0])
+	subjects := map[string]string{}
+	for _, subject := range dirContents.GetSubject() {
+		subjects[subject.Name] = string(subject.Digest)
+	}
+	g.Expect(subjects).To(HaveLen(2))
+	g.Expect(subjects).To(HaveKey(HavePrefix("a//")))
+	g.Expect(subjects).To(HaveKey(HavePrefix("b//")))
+}
+
+func TestRegistryWithStdinRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	tempDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(tempDir, loader.StdinFileName), []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: test\n"), 0o644)).To(Succeed())
+
+	manifestLoader := loader.NewRecursiveDirectoryLoader(filepath.Join(tempDir, loader.StdinFileName), loader.ContentKindManifests)
+	g.Expect(manifestLoader.Load()).To(Succeed())
+	defer manifestLoader.Cleanup()
+
+	roots := []Root{}
+	for _, root := range manifestLoader.Roots() {
+		roots = append(roots, Root{
+			Path:   root.Path,
+			Prefix: root.Prefix,
+			IsDir:  root.IsDir,
+			Stdin:  true,
+			Source: dir.Source{URL: "stdin", Revision: "sha256:0000"},
+		})
+	}
+	repoDetected, attreg, err := DetectVCSForRoots(roots, dir.Source{URL: "https://example.com/app.git"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repoDetected).To(BeFalse())
+
+	summary := attreg.BaseDirSummary()
+	g.Expect(summary.ProviderName()).To(Equal(dir.ProviderName))
+	g.Expect(summary.Full().(*dir.Summary).Dir.Source).To(Equal(&dir.Source{URL: "stdin", Revision: "sha256:0000"}))
+
+	g.Expect(attreg.AssociateCoreStatements()).To(Succeed())
+
+	dirContents := manifest.MakeDirContentsStatementFrom(attreg.GetStatements()[0])
+	predicate := dirContents.GetPredicate().(manifest.SourceDirectoryContents)
+	g.Expect(predicate.Path).To(Equal(loader.StdinFileName))
+	g.Expect(predicate.Stdin).To(BeTrue())
+	g.Expect(predicate.Roots).To(BeEmpty())
+}
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	toto "github.com/in-toto/in-toto-golang/in_toto"
+	totoCommon "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+const (
+	StatementTypeV01 = toto.StatementInTotoV01
+	StatementTypeV1  = "https://in-toto.io/Statement/v1"
+
+	// CurrentStatementType is what statements are exported as
+	CurrentStatementType = StatementTypeV1
+)
+
+type (
+	// ResourceDescriptor is used for subjects in in-toto v1 statements,
+	// v0.1 subjects only have name and digest, so these are compatible
+	ResourceDescriptor struct {
+		Name             string               `json:"name,omitempty"`
+		URI              string               `json:"uri,omitempty"`
+		Digest           totoCommon.DigestSet `json:"digest,omitempty"`
+		DownloadLocation string               `json:"downloadLocation,omitempty"`
+		MediaType        string               `json:"mediaType,omitempty"`
+		Annotations      map[string]any       `json:"annotations,omitempty"`
+	}
+
+	// ExportedStatement holds an in-toto statement of any of the supported
+	// versions, it is what gets encoded and what readers decode
+	ExportedStatement struct {
+		Type          string               `json:"_type"`
+		Subject       []ResourceDescriptor `json:"subject"`
+		PredicateType string               `json:"predicateType"`
+		Predicate     any                  `json:"predicate"`
+	}
+)
+
+func IsSupportedStatementType(t string) bool {
+	switch t {
+	case StatementTypeV01, StatementTypeV1:
+		return true
+	default:
+		return false
+	}
+}
+
+func exportSubject(subjects []toto.Subject) []ResourceDescriptor {
+	descriptors := make([]ResourceDescriptor, len(subjects))
+	for i := range subjects {
+		descriptors[i] = ResourceDescriptor{
+			Name:   subjects[i].Name,
+			Digest: subjects[i].Digest,
+		}
+	}
+	return descriptors
+}
+
+// Upgrade converts the statement to the current version, it is a no-op for
+// statements that are already current, v0.1 subjects are valid resource
+// descriptors, so only the type needs to change
+func (s *ExportedStatement) Upgrade() bool {
+	if s.Type == CurrentStatementType {
+		return false
+	}
+	s.Type = CurrentStatementType
+	return true
+}
+
+func (s *ExportedStatement) Validate() error {
+	if !IsSupportedStatementType(s.Type) {
+		return fmt.Errorf("unsupported statement type %q", s.Type)
+	}
+	if s.PredicateType == "" {
+		return fmt.Errorf("statement of type %q is missing predicate type", s.Type)
+	}
+	if len(s.Subject) == 0 {
+		return fmt.Errorf("statement with predicate type %q has no subjects", s.PredicateType)
+	}
+	return nil
+}
+
+// DecodeStatement decodes a single statement and checks that it's of a supported version
+func DecodeStatement(data []byte) (*ExportedStatement, error) {
+	statement := &ExportedStatement{}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(statement); err != nil {
+		return nil, fmt.Errorf("unable to decode statement: %w", err)
+	}
+	if err := statement.Validate(); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// DecodeStatements reads statements encoded as JSONL
+func DecodeStatements(r io.Reader) ([]ExportedStatement, error) {
+	statements := []ExportedStatement{}
+	scanner := bufio.NewScanner(r)
+	// statements can be quite large, e.g. when there are many files in a directory
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		statement, err := DecodeStatement(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *statement)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func EncodeStatements(w io.Writer, statements []ExportedStatement) error {
+	encoder := json.NewEncoder(w)
+	for i := range statements {
+		if err := encoder.Encode(statements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
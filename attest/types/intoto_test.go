@@ -0,0 +1,44 @@
+package types_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/attest/types"
+)
+
+func TestDecodeStatements(t *testing.T) {
+	const (
+		v01 = `{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"a.yaml","digest":{"sha256":"abc"}}],"predicateType":"test/v1","predicate":{"foo":"bar"}}`
+		v1  = `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"b.yaml","digest":{"sha256":"def"}}],"predicateType":"test/v1","predicate":{}}`
+	)
+
+	g := NewWithT(t)
+
+	statements, err := DecodeStatements(strings.NewReader(v01 + "\n\n" + v1 + "\n"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statements).To(HaveLen(2))
+	g.Expect(statements[0].Type).To(Equal(StatementTypeV01))
+	g.Expect(statements[0].Subject[0].Name).To(Equal("a.yaml"))
+	g.Expect(statements[0].Subject[0].Digest).To(HaveKeyWithValue("sha256", "abc"))
+	g.Expect(statements[1].Type).To(Equal(StatementTypeV1))
+
+	g.Expect(statements[0].Upgrade()).To(BeTrue())
+	g.Expect(statements[0].Type).To(Equal(StatementTypeV1))
+	g.Expect(statements[1].Upgrade()).To(BeFalse())
+
+	buf := bytes.NewBuffer(nil)
+	g.Expect(EncodeStatements(buf, statements)).To(Succeed())
+	reencoded, err := DecodeStatements(buf)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reencoded).To(Equal(statements))
+
+	_, err = DecodeStatements(strings.NewReader(`{"_type":"https://in-toto.io/Statement/v2","subject":[{"name":"a"}],"predicateType":"test/v1"}`))
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported statement type")))
+
+	_, err = DecodeStatements(strings.NewReader(`{"_type":"https://in-toto.io/Statement/v1","predicateType":"test/v1"}`))
+	g.Expect(err).To(MatchError(ContainSubstring("has no subjects")))
+}
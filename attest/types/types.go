@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"slices"
+	"time"
 
 	toto "github.com/in-toto/in-toto-golang/in_toto"
 
@@ -48,6 +49,12 @@ type (
 		SameRepo(PathCheckSummary) bool
 	}
 
+	// CommitTimeProvider is implemented by summaries of VCS providers that
+	// can tell when current revision was committed
+	CommitTimeProvider interface {
+		CommitTime() *time.Time
+	}
+
 	Subject struct {
 		Name   string        `json:"name"`
 		Digest digest.SHA256 `json:"digest"`
@@ -65,7 +72,7 @@ type (
 		GetType() string
 		GetPredicate() any
 		ExportSubject() []toto.Subject
-		Export() toto.Statement
+		Export() ExportedStatement
 	}
 	Statement interface {
 		ExportableStatement
@@ -108,14 +115,12 @@ func CmpLess() Cmp  { cmp := -1; return &cmp }
 func CmpEqual() Cmp { cmp := 0; return &cmp }
 func CmpMore() Cmp  { cmp := +1; return &cmp }
 
-func Export(s ExportableStatement) toto.Statement {
-	return toto.Statement{
-		StatementHeader: toto.StatementHeader{
-			Type:          toto.StatementInTotoV01,
-			PredicateType: s.GetType(),
-			Subject:       s.ExportSubject(),
-		},
-		Predicate: s.GetPredicate(),
+func Export(s ExportableStatement) ExportedStatement {
+	return ExportedStatement{
+		Type:          CurrentStatementType,
+		PredicateType: s.GetType(),
+		Subject:       exportSubject(s.ExportSubject()),
+		Predicate:     s.GetPredicate(),
 	}
 }
 
@@ -143,8 +148,8 @@ func (s *GenericStatement[T]) ConvertFrom(statement Statement) error {
 	return nil
 }
 
-func (s Statements) Export() []toto.Statement {
-	statements := make([]toto.Statement, len(s))
+func (s Statements) Export() []ExportedStatement {
+	statements := make([]ExportedStatement, len(s))
 	for i := range s {
 		statements[i] = s[i].Export()
 	}
@@ -419,7 +424,7 @@ func MakeStatement[T any](predicateType string, predicate ComparablePredicate[T]
 
 func (s GenericStatement[T]) GetSubject() Subjects          { return s.Subjects }
 func (s GenericStatement[T]) ExportSubject() []toto.Subject { return s.Subjects.Export() }
-func (s GenericStatement[T]) Export() toto.Statement        { return Export(s) }
+func (s GenericStatement[T]) Export() ExportedStatement     { return Export(s) }
 func (s GenericStatement[T]) EncodeWith(e EncodeFunc) error { return e(s.Export()) }
 
 func (s GenericStatement[T]) Encode(w io.Writer) error {
@@ -0,0 +1,300 @@
+package dir
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/ioutil"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/attest/types"
+)
+
+const (
+	ProviderName = "dir"
+
+	entryKindFile    = "file"
+	entryKindDir     = "dir"
+	entryKindSymlink = "symlink"
+)
+
+// Source holds optional information about where the contents of a directory
+// came from, since it cannot be detected it must be provided by the user
+type Source struct {
+	URL      string `json:"url,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+func (s Source) IsZero() bool { return s.URL == "" && s.Revision == "" }
+
+// NewPathCheckerFactory returns a constructor for path checkers which record
+// paths relative to the given root directory
+func NewPathCheckerFactory(root string, source Source) func(string, digest.SHA256) types.PathChecker {
+	return func(path string, digest digest.SHA256) types.PathChecker {
+		return &PathChecker{
+			root:   root,
+			path:   path,
+			digest: digest,
+			source: source,
+		}
+	}
+}
+
+type PathChecker struct {
+	root   string
+	path   string
+	digest digest.SHA256
+	source Source
+	cache  *pathCheckerCache
+}
+
+type (
+	Summary struct {
+		types.PathCheckSummaryCommon `json:",inline"`
+
+		Dir *DirSummary `json:"dir,omitempty"`
+	}
+
+	DirSummary struct {
+		Source     *Source       `json:"source,omitempty"`
+		TreeDigest digest.SHA256 `json:"treeDigest"`
+		Files      []File        `json:"files,omitempty"`
+	}
+
+	File struct {
+		Path   string        `json:"path"`
+		Digest digest.SHA256 `json:"digest"`
+	}
+)
+
+type pathCheckerCache struct {
+	checked    bool
+	isDir      bool
+	relPath    string
+	treeDigest digest.SHA256
+	files      []File
+}
+
+func (s *Summary) SameRepo(other types.PathCheckSummary) bool {
+	if other.ProviderName() != ProviderName {
+		return false
+	}
+	otherSummary, ok := other.Full().(*Summary)
+	if !ok || s.Dir == nil || otherSummary.Dir == nil {
+		return false
+	}
+	if s.URI != otherSummary.URI {
+		return false
+	}
+	switch {
+	case s.Dir.Source == nil && otherSummary.Dir.Source == nil:
+		return true
+	case s.Dir.Source == nil || otherSummary.Dir.Source == nil:
+		return false
+	default:
+		return *s.Dir.Source == *otherSummary.Dir.Source
+	}
+}
+
+func (s *Summary) Full() interface{} { return s }
+
+func (s *Summary) ProviderName() string { return ProviderName }
+
+func (PathChecker) ProviderName() string { return ProviderName }
+
+// DetectRepo always succeeds for an existing path, as this provider is
+// meant to be used as a fallback when no VCS is present
+func (c *PathChecker) DetectRepo() (bool, error) {
+	if _, err := os.Stat(c.path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Check returns whether the path exists, and it never reports the path as
+// unmodified, since there is nothing to compare it to
+func (c *PathChecker) Check() (bool, bool, error) {
+	ok, err := c.DetectRepo()
+	if err != nil || !ok {
+		return c.negative(err)
+	}
+
+	relPath, err := c.relPath()
+	if err != nil {
+		return c.negative(err)
+	}
+
+	info, err := os.Lstat(c.path)
+	if err != nil {
+		return c.negative(err)
+	}
+
+	c.cache = &pathCheckerCache{
+		isDir:   info.IsDir(),
+		relPath: relPath,
+	}
+
+	if c.cache.isDir {
+		files := []File{}
+		treeDigest, err := hashDir(c.path, "", &files)
+		if err != nil {
+			return c.negative(err)
+		}
+		c.cache.treeDigest = treeDigest
+		c.cache.files = files
+	} else {
+		_, fileDigest, err := hashEntry(c.path, info)
+		if err != nil {
+			return c.negative(err)
+		}
+		c.cache.treeDigest = fileDigest
+	}
+
+	c.cache.checked = true
+	return true, false, nil
+}
+
+func (c *PathChecker) MakeSummary() (types.PathCheckSummary, error) {
+	if c.cache == nil || !c.cache.checked {
+		checked, _, err := c.Check()
+		if err != nil {
+			return nil, err
+		}
+		if !checked {
+			return nil, fmt.Errorf("%q is not checked", c.path)
+		}
+	}
+
+	summary := &Summary{
+		PathCheckSummaryCommon: types.PathCheckSummaryCommon{
+			Unmodified: false,
+			Path:       c.cache.relPath,
+			URI:        c.source.URL,
+			IsDir:      c.cache.isDir,
+			Digest:     c.digest,
+		},
+		Dir: &DirSummary{
+			TreeDigest: c.cache.treeDigest,
+			Files:      c.cache.files,
+		},
+	}
+	if !c.source.IsZero() {
+		source := c.source
+		summary.Dir.Source = &source
+	}
+	return summary, nil
+}
+
+func (c *PathChecker) Reset() { c.cache = nil }
+
+func (c *PathChecker) negative(err error) (bool, bool, error) {
+	c.Reset()
+	return false, false, err
+}
+
+func (c *PathChecker) relPath() (string, error) {
+	absRoot, err := filepath.Abs(c.root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(c.path)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return "", err
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside of %q", c.path, c.root)
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// hashDir computes a Merkle-style digest of a directory, digest of each entry is
+// computed first, then the digest of the directory is computed from a sorted
+// list of entries, each of which is represented as "<kind> <digest> <name>\n";
+// all regular files found are appended to files
+func hashDir(dir, relDir string, files *[]File) (digest.SHA256, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	// os.ReadDir already returns entries sorted by name, yet it's best to be explicit
+	slices.SortFunc(entries, func(a, b os.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		entryRelPath := filepath.ToSlash(filepath.Join(relDir, entry.Name()))
+
+		var (
+			kind        string
+			entryDigest digest.SHA256
+		)
+		if entry.IsDir() {
+			kind = entryKindDir
+			entryDigest, err = hashDir(entryPath, entryRelPath, files)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			info, err := entry.Info()
+			if err != nil {
+				return "", err
+			}
+			kind, entryDigest, err = hashEntry(entryPath, info)
+			if err != nil {
+				return "", err
+			}
+			if kind == "" {
+				// ignore anything that is not a file, directory or a symlink
+				continue
+			}
+			if kind == entryKindFile {
+				*files = append(*files, File{Path: entryRelPath, Digest: entryDigest})
+			}
+		}
+		if _, err := fmt.Fprintf(hash, "%s %s %s\n", kind, entryDigest, entry.Name()); err != nil {
+			return "", err
+		}
+	}
+	return digest.MakeSHA256(hash), nil
+}
+
+func hashEntry(path string, info os.FileInfo) (_ string, _ digest.SHA256, err error) {
+	hash := sha256.New()
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", "", err
+		}
+		_, _ = hash.Write([]byte(target))
+		return entryKindSymlink, digest.MakeSHA256(hash), nil
+	case mode.IsRegular():
+		// err is the named result, so that an error of closing the file is returned
+		var file *os.File
+		file, err = os.Open(path)
+		if err != nil {
+			return "", "", err
+		}
+		defer ioutil.CheckClose(file, &err)
+		if _, err = io.Copy(hash, file); err != nil {
+			return "", "", err
+		}
+		return entryKindFile, digest.MakeSHA256(hash), nil
+	default:
+		return "", "", nil
+	}
+}
@@ -0,0 +1,110 @@
+package dir_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	. "github.com/errordeveloper/tape/attest/vcs/dir"
+)
+
+func TestDirProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeFile(t, root, "a.yaml", "kind: A\n")
+	writeFile(t, root, "sub/b.yaml", "kind: B\n")
+	writeFile(t, root, "sub/c.yaml", "kind: C\n")
+	g.Expect(os.Symlink("a.yaml", filepath.Join(root, "link.yaml"))).To(Succeed())
+
+	source := Source{URL: "https://example.com/configs.tar.gz", Revision: "v1.0.0"}
+	newPathChecker := NewPathCheckerFactory(root, source)
+
+	summarise := func(path string) *Summary {
+		checker := newPathChecker(filepath.Join(root, path), "")
+		checked, unmodified, err := checker.Check()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(checked).To(BeTrue())
+		g.Expect(unmodified).To(BeFalse())
+
+		summary, err := checker.MakeSummary()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(summary.ProviderName()).To(Equal(ProviderName))
+		dirSummary, ok := summary.Full().(*Summary)
+		g.Expect(ok).To(BeTrue())
+		return dirSummary
+	}
+
+	baseDir := summarise(".")
+	g.Expect(baseDir.Path).To(Equal("."))
+	g.Expect(baseDir.IsDir).To(BeTrue())
+	g.Expect(baseDir.URI).To(Equal(source.URL))
+	g.Expect(baseDir.Dir.Source).To(Equal(&source))
+	g.Expect(baseDir.Dir.Files).To(HaveLen(3))
+	g.Expect([]string{
+		baseDir.Dir.Files[0].Path,
+		baseDir.Dir.Files[1].Path,
+		baseDir.Dir.Files[2].Path,
+	}).To(Equal([]string{"a.yaml", "sub/b.yaml", "sub/c.yaml"}))
+
+	file := summarise("sub/b.yaml")
+	g.Expect(file.Path).To(Equal("sub/b.yaml"))
+	g.Expect(file.IsDir).To(BeFalse())
+	g.Expect(file.Dir.Files).To(BeEmpty())
+	g.Expect(file.Dir.TreeDigest).To(Equal(baseDir.Dir.Files[1].Digest))
+	g.Expect(file.Dir.TreeDigest).To(Equal(digest.SHA256("842a98ecc59197765dbdcbb2d0d5d4b7ae2cba04843be0191e093dd2256587b7")))
+
+	g.Expect(file.SameRepo(baseDir)).To(BeTrue())
+
+	// digest must be stable when contents don't change
+	g.Expect(summarise(".").Dir.TreeDigest).To(Equal(baseDir.Dir.TreeDigest))
+
+	// digest of parent directories changes, but not of unrelated ones
+	subDir := summarise("sub")
+	writeFile(t, root, "sub/c.yaml", "kind: D\n")
+	g.Expect(summarise("sub").Dir.TreeDigest).ToNot(Equal(subDir.Dir.TreeDigest))
+	g.Expect(summarise(".").Dir.TreeDigest).ToNot(Equal(baseDir.Dir.TreeDigest))
+	g.Expect(summarise("a.yaml").Dir.TreeDigest).To(Equal(baseDir.Dir.Files[0].Digest))
+
+	// renaming a file must change the digest, even if contents are the same
+	renamed := summarise(".")
+	g.Expect(os.Rename(filepath.Join(root, "a.yaml"), filepath.Join(root, "z.yaml"))).To(Succeed())
+	g.Expect(summarise(".").Dir.TreeDigest).ToNot(Equal(renamed.Dir.TreeDigest))
+
+	otherSource := NewPathCheckerFactory(root, Source{})(root, "")
+	otherSummary, err := otherSource.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherSummary.Full().(*Summary).Dir.Source).To(BeNil())
+	g.Expect(otherSummary.SameRepo(baseDir)).To(BeFalse())
+}
+
+func TestDirProviderOutsideOfRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	writeFile(t, root, "sub/a.yaml", "kind: A\n")
+
+	checker := NewPathCheckerFactory(filepath.Join(root, "sub"), Source{})(root, "")
+	checked, _, err := checker.Check()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(checked).To(BeFalse())
+
+	checker = NewPathCheckerFactory(root, Source{})(filepath.Join(root, "non-existent"), "")
+	checked, _, err = checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeFalse())
+}
+
+func writeFile(t *testing.T, root, path, contents string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,8 +3,10 @@ package git
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -44,9 +46,10 @@ type (
 	}
 
 	GitSummary struct {
-		Object    GitObject           `json:"object,omitempty"`
-		Remotes   map[string][]string `json:"remotes,omitempty"`
-		Reference GitReference        `json:"reference,omitempty"`
+		Object        GitObject           `json:"object,omitempty"`
+		Remotes       map[string][]string `json:"remotes,omitempty"`
+		Reference     GitReference        `json:"reference,omitempty"`
+		ModifiedFiles []string            `json:"modifiedFiles,omitempty"`
 	}
 
 	GitObject struct {
@@ -67,12 +70,13 @@ type (
 	}
 
 	GitReference struct {
-		Name      string     `json:"name,omitempty"`
-		Hash      string     `json:"hash,omitempty"`
-		Type      string     `json:"type,omitempty"`
-		Target    string     `json:"target,omitempty"`
-		Tags      []GitTag   `json:"tags,omitempty"`
-		Signature *Signature `json:"signature,omitempty"`
+		Name       string     `json:"name,omitempty"`
+		Hash       string     `json:"hash,omitempty"`
+		Type       string     `json:"type,omitempty"`
+		Target     string     `json:"target,omitempty"`
+		Tags       []GitTag   `json:"tags,omitempty"`
+		Signature  *Signature `json:"signature,omitempty"`
+		CommitTime *time.Time `json:"commitTime,omitempty"`
 	}
 )
 
@@ -171,17 +175,36 @@ func (c *PathChecker) MakeSummary() (types.PathCheckSummary, error) {
 	}
 
 	obj := &GitObject{}
-	if summary.Unmodified {
+	switch {
+	case summary.Unmodified:
 		obj.TreeHash = c.cache.obj.ID().String()
-	} else if c.IsBlob() {
-		// there is currently no easy way to obtain a hash for a subtree
+	case c.IsBlob():
 		obj.TreeHash = c.cache.blobHash
+		git.ModifiedFiles = []string{filepath.ToSlash(c.cache.repoPath)}
+	case c.IsTree():
+		// hash of working tree contents is computed in the same way git would do it,
+		// so that a modified directory can be matched to a commit made later on
+		worktree, err := c.cache.repo.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		worktreeTree, err := hashWorktreeDir(worktree, c.Tree(), filepath.ToSlash(c.cache.repoPath))
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute hash of working tree for %q: %w", c.cache.repoPath, err)
+		}
+		obj.TreeHash = worktreeTree.hash.String()
+		git.ModifiedFiles, err = worktreeTree.modifiedFiles(c.Tree(), filepath.ToSlash(c.cache.repoPath))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	headCommit, err := c.cache.repo.CommitObject(head.Hash())
 	if err != nil {
 		return nil, err
 	}
+	commitTime := headCommit.Committer.When.UTC()
+	ref.CommitTime = &commitTime
 	if headCommit.PGPSignature != "" {
 		ref.Signature = &Signature{
 			PGP:       []byte(headCommit.PGPSignature),
@@ -299,6 +322,13 @@ func (s *Summary) Full() interface{} { return s }
 
 func (c *Summary) ProviderName() string { return ProviderName }
 
+func (s *Summary) CommitTime() *time.Time {
+	if s.Git == nil {
+		return nil
+	}
+	return s.Git.Reference.CommitTime
+}
+
 func (c *PathChecker) DetectRepo() (bool, error) {
 	if c.cache == nil {
 		c.cache = &pathCheckerCache{}
@@ -344,7 +374,7 @@ func (c *PathChecker) Check() (bool, bool, error) {
 
 	c.cache.repoPath = repoPath
 
-	obj, err := findByPath(c.cache.repo, repoPath)
+	obj, mode, err := findByPath(c.cache.repo, repoPath)
 	if err != nil {
 		return c.negative(err)
 	}
@@ -357,7 +387,7 @@ func (c *PathChecker) Check() (bool, bool, error) {
 
 	switch obj.Type() {
 	case plumbing.BlobObject:
-		unmodified, blobHash, err := isBlobUnmodified(worktree, obj.(*object.Blob), repoPath)
+		unmodified, blobHash, err := isBlobUnmodified(worktree, obj.(*object.Blob), mode, repoPath)
 		if err != nil {
 			return c.negative(err)
 		}
@@ -375,10 +405,11 @@ func (c *PathChecker) Check() (bool, bool, error) {
 			if !c.cache.unmodified {
 				return nil
 			}
-			unmodified, _, err := isBlobUnmodified(worktree, &f.Blob, filepath.Join(repoPath, f.Name))
+			unmodified, _, err := isBlobUnmodified(worktree, &f.Blob, f.Mode, filepath.Join(repoPath, f.Name))
 			if err != nil {
-				if f.Mode == filemode.Symlink {
-					// TODO: should at least log a warning for broken symlink
+				if os.IsNotExist(err) {
+					// tracked file was removed
+					c.cache.unmodified = false
 					return nil
 				}
 				return err
@@ -438,7 +469,31 @@ func fileIsInDir(file, dir string) bool {
 	return relParts[0] != ".."
 }
 
-func isBlobUnmodified(worktree *gogit.Worktree, blob *object.Blob, repoPath string) (_ bool, _ string, err error) {
+func isBlobUnmodified(worktree *gogit.Worktree, blob *object.Blob, mode filemode.FileMode, repoPath string) (_ bool, _ string, err error) {
+	// git records changes of mode, e.g. when executable bit is set or unset
+	info, err := worktree.Filesystem.Lstat(repoPath)
+	if err != nil {
+		return false, "", err
+	}
+	worktreeMode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return false, "", err
+	}
+	if mode == filemode.Deprecated {
+		mode = filemode.Regular
+	}
+	modeUnchanged := worktreeMode == mode
+
+	if mode == filemode.Symlink {
+		// contents of a symlink blob is the target path, it shouldn't be followed
+		target, err := worktree.Filesystem.Readlink(repoPath)
+		if err != nil {
+			return false, "", err
+		}
+		blobHash := plumbing.ComputeHash(plumbing.BlobObject, []byte(target)).String()
+		return modeUnchanged && blobHash == blob.Hash.String(), blobHash, nil
+	}
+
 	// there is blob.Reader(), however it reads checked contents, while for this check
 	// a hash of working tree contents is needed
 	file, err := worktree.Filesystem.Open(repoPath)
@@ -452,7 +507,7 @@ func isBlobUnmodified(worktree *gogit.Worktree, blob *object.Blob, repoPath stri
 		return false, "", err
 	}
 	blobHash := plumbing.ComputeHash(plumbing.BlobObject, data).String()
-	return blobHash == blob.Hash.String(), blobHash, nil
+	return modeUnchanged && blobHash == blob.Hash.String(), blobHash, nil
 }
 
 func (c *PathChecker) Repository() *gogit.Repository {
@@ -499,37 +554,40 @@ func (c *PathChecker) negative(err error) (bool, bool, error) {
 	return false, false, err
 }
 
-func findByPath(repo *gogit.Repository, path string) (object.Object, error) {
+// findByPath returns the object at path in HEAD along with the mode it's recorded with
+func findByPath(repo *gogit.Repository, path string) (object.Object, filemode.FileMode, error) {
 	head, err := repo.Head()
 	if err != nil {
-		return nil, err
+		return nil, filemode.Empty, err
 	}
 	commitObj, err := repo.CommitObject(head.Hash())
 	if err != nil {
-		return nil, err
+		return nil, filemode.Empty, err
 	}
 	tree, err := commitObj.Tree()
 	if err != nil {
-		return nil, err
+		return nil, filemode.Empty, err
 	}
 	if path == "." {
-		return tree, nil
+		return tree, filemode.Dir, nil
 	}
 	treeEntry, err := tree.FindEntry(path)
 	switch err {
 	case nil:
 		switch treeEntry.Mode {
-		case filemode.Regular, filemode.Deprecated, filemode.Executable:
-			return repo.BlobObject(treeEntry.Hash)
+		case filemode.Regular, filemode.Deprecated, filemode.Executable, filemode.Symlink:
+			blob, err := repo.BlobObject(treeEntry.Hash)
+			return blob, treeEntry.Mode, err
 		case filemode.Dir:
-			return repo.TreeObject(treeEntry.Hash)
+			tree, err := repo.TreeObject(treeEntry.Hash)
+			return tree, treeEntry.Mode, err
 		default:
-			return nil, fmt.Errorf("unsupported mode: %q %q", path, treeEntry.Mode.String())
+			return nil, filemode.Empty, fmt.Errorf("unsupported mode: %q %q", path, treeEntry.Mode.String())
 		}
 	case object.ErrDirectoryNotFound, object.ErrFileNotFound, plumbing.ErrObjectNotFound, object.ErrEntryNotFound:
-		return nil, nil
+		return nil, filemode.Empty, nil
 	default:
-		return nil, err
+		return nil, filemode.Empty, err
 	}
 }
 
@@ -71,6 +71,31 @@ func TestGitProvider(t *testing.T) {
 			unmodified: BeFalse(),
 			setup:      makeModifyFileSetupFunc("testdata/dir1/modify"),
 		},
+		{
+			desc:       "executable-file",
+			path:       "testdata/dir1/executable",
+			err:        Not(HaveOccurred()),
+			checked:    BeTrue(),
+			unmodified: BeTrue(),
+		},
+		{
+			desc:       "executable-file-mode-changed",
+			path:       "testdata/dir1/executable",
+			err:        Not(HaveOccurred()),
+			checked:    BeTrue(),
+			unmodified: BeFalse(),
+			setup: func(t *testing.T, tc *gitTestCases) {
+				g := NewWithT(t)
+
+				g.Expect(os.Chmod(tc.path, 0644)).To(Succeed())
+
+				tc.cleanup = func(t *testing.T, tc *gitTestCases) {
+					g := NewWithT(t)
+
+					g.Expect(os.Chmod(tc.path, 0755)).To(Succeed())
+				}
+			},
+		},
 		{
 			desc:       "unchecked-file",
 			path:       "testdata/dir1/unchecked",
@@ -214,3 +239,95 @@ func makeCreateFileSetupFunc(path string) func(*testing.T, *gitTestCases) {
 		}
 	}
 }
+
+func makeChmodFileSetupFunc(path string, mode os.FileMode) func(*testing.T, *gitTestCases) {
+	return func(t *testing.T, tc *gitTestCases) {
+		g := NewWithT(t)
+
+		info, err := os.Stat(path)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(os.Chmod(path, mode)).To(Succeed())
+
+		tc.cleanup = func(t *testing.T, tc *gitTestCases) {
+			g := NewWithT(t)
+
+			g.Expect(os.Chmod(path, info.Mode())).To(Succeed())
+		}
+	}
+}
+
+func TestGitSummary(t *testing.T) {
+	cases := []struct {
+		desc, path    string
+		treeHash      string
+		modifiedFiles []string
+		setup         func(*testing.T, *gitTestCases)
+	}{
+		{
+			desc:     "clean-dir",
+			path:     "testdata/dir2",
+			treeHash: "5bdd8df3661fb7db64f7f6c80da1bf0682116ac7",
+		},
+		{
+			desc:          "modified-file",
+			path:          "testdata/dir2/modify",
+			treeHash:      "aecc55e12efd8c8f6cd27317185fdf60f1903e07",
+			modifiedFiles: []string{"attest/vcs/git/testdata/dir2/modify"},
+			setup:         makeModifyFileSetupFunc("testdata/dir2/modify"),
+		},
+		{
+			desc:          "dir-with-modified-file",
+			path:          "testdata/dir2",
+			treeHash:      "f7aacf0a345f7da76365c8364bb04315ebc65527",
+			modifiedFiles: []string{"attest/vcs/git/testdata/dir2/modify"},
+			setup:         makeModifyFileSetupFunc("testdata/dir2/modify"),
+		},
+		{
+			desc:          "dir-with-new-file",
+			path:          "testdata/dir2",
+			treeHash:      "3024a888b89a1d887457c985cb335a8036bbd12a",
+			modifiedFiles: []string{"attest/vcs/git/testdata/dir2/new-file"},
+			setup:         makeCreateFileSetupFunc("testdata/dir2/new-file"),
+		},
+		{
+			desc:          "dir-with-mode-changed-file",
+			path:          "testdata/dir2",
+			treeHash:      "f171ef2889bf0eed099f720557666c9b776f7fb1",
+			modifiedFiles: []string{"attest/vcs/git/testdata/dir2/modify"},
+			setup:         makeChmodFileSetupFunc("testdata/dir2/modify", 0o755),
+		},
+		{
+			desc:     "dir-with-ignored-file",
+			path:     "testdata/dir2",
+			treeHash: "5bdd8df3661fb7db64f7f6c80da1bf0682116ac7",
+			setup:    makeCreateFileSetupFunc("testdata/dir2/unchecked"),
+		},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			// cannot use t.Parallel() here since same directory is used for all test cases
+			setupTC := &gitTestCases{path: tc.path}
+			if tc.setup != nil {
+				tc.setup(t, setupTC)
+			}
+
+			g := NewWithT(t)
+
+			summary, err := NewPathChecker(tc.path, "").MakeSummary()
+			g.Expect(err).NotTo(HaveOccurred())
+
+			gitSummary, ok := summary.Full().(*Summary)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(gitSummary.Unmodified).To(Equal(tc.modifiedFiles == nil))
+			g.Expect(gitSummary.Git.Object.TreeHash).To(Equal(tc.treeHash))
+			g.Expect(gitSummary.Git.ModifiedFiles).To(Equal(tc.modifiedFiles))
+			g.Expect(gitSummary.CommitTime()).ToNot(BeNil())
+
+			if setupTC.cleanup != nil {
+				setupTC.cleanup(t, setupTC)
+			}
+		})
+	}
+}
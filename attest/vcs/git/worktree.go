@@ -0,0 +1,271 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+)
+
+const (
+	gitDir          = ".git"
+	gitignoreFile   = ".gitignore"
+	infoExcludeFile = ".git/info/exclude"
+)
+
+// worktreeTree holds a tree hash computed from the contents of working tree,
+// it matches what `git add --all <dir> && git write-tree --prefix=<dir>` would
+// produce, so that it's possible to find a commit with identical contents
+type worktreeTree struct {
+	hash  plumbing.Hash
+	blobs map[string]worktreeBlob
+}
+
+// worktreeBlob is a file found in working tree, mode is what git would record,
+// as a change of mode alone is a modification too
+type worktreeBlob struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+type worktreeHasher struct {
+	fs      billy.Filesystem
+	tracked map[string]object.TreeEntry
+	blobs   map[string]worktreeBlob
+}
+
+func hashWorktreeDir(worktree *gogit.Worktree, headTree *object.Tree, repoPath string) (*worktreeTree, error) {
+	dir := path.Clean(repoPath)
+	if dir == "." {
+		dir = ""
+	}
+
+	h := &worktreeHasher{
+		fs:      worktree.Filesystem,
+		tracked: map[string]object.TreeEntry{},
+		blobs:   map[string]worktreeBlob{},
+	}
+
+	// files that are tracked must be included regardless of any ignore rules
+	walker := object.NewTreeWalker(headTree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		h.tracked[path.Join(dir, name)] = entry
+	}
+
+	patterns, err := h.readAncestorPatterns(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, _, err := h.hashDir(dir, patterns, false)
+	if err != nil {
+		return nil, err
+	}
+	return &worktreeTree{hash: hash, blobs: h.blobs}, nil
+}
+
+// modifiedFiles compares blobs found in working tree with what is in the tree
+// object of HEAD, it returns a sorted list of paths that were added, removed
+// or modified, including those where only the mode was changed
+func (t *worktreeTree) modifiedFiles(headTree *object.Tree, repoPath string) ([]string, error) {
+	dir := path.Clean(repoPath)
+	seen := make(map[string]struct{}, len(t.blobs))
+	modified := []string{}
+
+	if err := headTree.Files().ForEach(func(f *object.File) error {
+		filePath := path.Join(dir, f.Name)
+		seen[filePath] = struct{}{}
+		if blob, ok := t.blobs[filePath]; !ok || blob.hash != f.Hash || blob.mode != f.Mode {
+			modified = append(modified, filePath)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for filePath := range t.blobs {
+		if _, ok := seen[filePath]; !ok {
+			modified = append(modified, filePath)
+		}
+	}
+	slices.Sort(modified)
+	return modified, nil
+}
+
+func (h *worktreeHasher) hashDir(dir string, patterns []gitignore.Pattern, ignored bool) (plumbing.Hash, bool, error) {
+	dirPatterns, err := h.readPatterns(dir, gitignoreFile)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	patterns = append(slices.Clip(patterns), dirPatterns...)
+	matcher := gitignore.NewMatcher(patterns)
+
+	entries, err := h.fs.ReadDir(h.path(dir))
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	tree := &object.Tree{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == gitDir {
+			continue
+		}
+		entryPath := path.Join(dir, name)
+		entryIgnored := ignored || matcher.Match(splitPath(entryPath), entry.IsDir())
+
+		switch mode := entry.Mode(); {
+		case mode.IsDir():
+			if tracked, ok := h.tracked[entryPath]; ok && tracked.Mode == filemode.Submodule {
+				// submodules have no working tree representation that could be
+				// hashed, so the entry is carried over from HEAD
+				tree.Entries = append(tree.Entries, tracked)
+				continue
+			}
+			hash, empty, err := h.hashDir(entryPath, patterns, entryIgnored)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			if empty {
+				// git doesn't record empty directories
+				continue
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+		case mode.IsRegular(), mode&os.ModeSymlink != 0:
+			if _, tracked := h.tracked[entryPath]; entryIgnored && !tracked {
+				continue
+			}
+			fileMode, err := filemode.NewFromOSFileMode(mode)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			hash, err := h.hashFile(entryPath, fileMode)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			h.blobs[entryPath] = worktreeBlob{hash: hash, mode: fileMode}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: fileMode, Hash: hash})
+		}
+	}
+
+	if len(tree.Entries) == 0 {
+		return plumbing.ZeroHash, true, nil
+	}
+
+	// git sorts entries by name, with directory names being compared as if they had a trailing slash
+	slices.SortFunc(tree.Entries, func(a, b object.TreeEntry) int {
+		return strings.Compare(treeEntrySortKey(a), treeEntrySortKey(b))
+	})
+
+	obj := &plumbing.MemoryObject{}
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	return obj.Hash(), false, nil
+}
+
+func (h *worktreeHasher) hashFile(filePath string, mode filemode.FileMode) (_ plumbing.Hash, err error) {
+	if mode == filemode.Symlink {
+		target, err := h.fs.Readlink(h.path(filePath))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(target)), nil
+	}
+
+	file, err := h.fs.Open(h.path(filePath))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer ioutil.CheckClose(file, &err)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, data), nil
+}
+
+// readAncestorPatterns loads ignore rules that apply to dir from the repo root
+// and each of the parent directories, rules found inside dir are loaded while
+// walking the tree
+func (h *worktreeHasher) readAncestorPatterns(dir string) ([]gitignore.Pattern, error) {
+	patterns, err := h.readPatterns("", infoExcludeFile)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return patterns, nil
+	}
+	parts := splitPath(dir)
+	for i := range parts {
+		dirPatterns, err := h.readPatterns(path.Join(parts[:i]...), gitignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, dirPatterns...)
+	}
+	return patterns, nil
+}
+
+func (h *worktreeHasher) readPatterns(dir, name string) (_ []gitignore.Pattern, err error) {
+	file, err := h.fs.Open(h.path(path.Join(dir, name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read ignore rules: %w", err)
+	}
+	defer ioutil.CheckClose(file, &err)
+
+	domain := splitPath(dir)
+	patterns := []gitignore.Pattern{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns, scanner.Err()
+}
+
+func (h *worktreeHasher) path(p string) string {
+	if p == "" {
+		return "."
+	}
+	return h.fs.Join(splitPath(p)...)
+}
+
+func treeEntrySortKey(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+func splitPath(p string) []string {
+	if p == "" || p == "." {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
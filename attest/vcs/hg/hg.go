@@ -0,0 +1,380 @@
+package hg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/internal/command"
+)
+
+const (
+	ProviderName = "hg"
+
+	DefaultPrimaryRemoteName = "default"
+
+	hgCommand  = "hg"
+	repoMarker = ".hg"
+	tipTag     = "tip"
+)
+
+func NewPathChecker(path string, digest digest.SHA256) types.PathChecker {
+	return &PathChecker{
+		path:   path,
+		digest: digest,
+		repos:  &repoCache{},
+	}
+}
+
+// NewPathCheckerFactory returns a constructor for path checkers which share results
+// of queries that are the same for all paths in a repo, so that these only run once
+// per repo, and not for each of the files; as results are never refreshed, checkers
+// made by the same constructor should only be used while the repo is not modified
+func NewPathCheckerFactory() func(string, digest.SHA256) types.PathChecker {
+	repos := &repoCache{}
+	return func(path string, digest digest.SHA256) types.PathChecker {
+		return &PathChecker{
+			path:   path,
+			digest: digest,
+			repos:  repos,
+		}
+	}
+}
+
+type PathChecker struct {
+	path   string
+	digest digest.SHA256
+	cache  *pathCheckerCache
+	repos  *repoCache
+}
+
+type (
+	Summary struct {
+		types.PathCheckSummaryCommon `json:",inline"`
+
+		Hg *HgSummary `json:"hg,omitempty"`
+	}
+
+	HgSummary struct {
+		Revision      HgRevision        `json:"revision"`
+		LastChangeset string            `json:"lastChangeset,omitempty"`
+		Remotes       map[string]string `json:"remotes,omitempty"`
+		ModifiedFiles []string          `json:"modifiedFiles,omitempty"`
+	}
+
+	HgRevision struct {
+		Node       string     `json:"node"`
+		Branch     string     `json:"branch,omitempty"`
+		Phase      string     `json:"phase,omitempty"`
+		Bookmarks  []string   `json:"bookmarks,omitempty"`
+		Tags       []string   `json:"tags,omitempty"`
+		CommitTime *time.Time `json:"commitTime,omitempty"`
+	}
+)
+
+type repoCache struct {
+	lock  sync.Mutex
+	repos map[string]*repoInfo
+}
+
+type repoInfo struct {
+	// files are tracked in the working copy parent, and modifiedFiles are
+	// all changes in the working copy, both are relative to the root
+	files         []string
+	modifiedFiles []string
+	revision      *HgRevision
+	remotes       map[string]string
+	uri           string
+}
+
+type pathCheckerCache struct {
+	checked       bool
+	unmodified    bool
+	isDir         bool
+	absPath       string
+	root          string
+	repoPath      string
+	modifiedFiles []string
+	hg            *command.Runner
+}
+
+func (s *Summary) SameRepo(other types.PathCheckSummary) bool {
+	if other.ProviderName() != ProviderName {
+		return false
+	}
+	otherSummary, ok := other.Full().(*Summary)
+	if !ok || s.Hg == nil || otherSummary.Hg == nil {
+		return false
+	}
+	return s.URI == otherSummary.URI &&
+		s.Hg.Revision.Node == otherSummary.Hg.Revision.Node &&
+		len(s.Hg.Remotes) == len(otherSummary.Hg.Remotes)
+}
+
+func (s *Summary) Full() interface{} { return s }
+
+func (s *Summary) ProviderName() string { return ProviderName }
+
+func (PathChecker) ProviderName() string { return ProviderName }
+
+func (s *Summary) CommitTime() *time.Time {
+	if s.Hg == nil {
+		return nil
+	}
+	return s.Hg.Revision.CommitTime
+}
+
+// DetectRepo looks for Mercurial repository that contains the path, when hg command
+// is not available, the repository is not detected, same as it's done by jj provider,
+// so that the path is checked by fallback provider instead
+func (c *PathChecker) DetectRepo() (bool, error) {
+	if c.cache == nil {
+		c.cache = &pathCheckerCache{}
+	}
+
+	absPath, err := filepath.Abs(c.path)
+	if err != nil {
+		return false, err
+	}
+	root := command.FindRoot(absPath, repoMarker)
+	if root == "" || !command.IsAvailable(hgCommand) {
+		return false, nil
+	}
+
+	c.cache.absPath = absPath
+	c.cache.root = root
+	c.cache.hg = &command.Runner{
+		Name: hgCommand,
+		Dir:  root,
+		// ensure output is not affected by user configuration
+		Env: []string{"HGPLAIN=1"},
+	}
+	return true, nil
+}
+
+func (c *PathChecker) Check() (bool, bool, error) {
+	if c.cache == nil || c.cache.hg == nil {
+		ok, err := c.DetectRepo()
+		if err != nil {
+			return c.negative(err)
+		}
+		if !ok {
+			return c.negative(nil)
+		}
+	}
+
+	repoPath, err := filepath.Rel(c.cache.root, c.cache.absPath)
+	if err != nil {
+		return c.negative(err)
+	}
+	c.cache.repoPath = filepath.ToSlash(repoPath)
+
+	repo, err := c.repoInfo()
+	if err != nil {
+		return c.negative(err)
+	}
+
+	files := c.filterPaths(repo.files)
+	if len(files) == 0 {
+		// the path is not tracked
+		return c.negative(nil)
+	}
+	c.cache.isDir = c.cache.repoPath == "." || len(files) > 1 || files[0] != c.cache.repoPath
+
+	modifiedFiles := c.filterPaths(repo.modifiedFiles)
+
+	c.cache.modifiedFiles = modifiedFiles
+	c.cache.unmodified = len(modifiedFiles) == 0
+	c.cache.checked = true
+
+	return c.cache.checked, c.cache.unmodified, nil
+}
+
+func (c *PathChecker) MakeSummary() (types.PathCheckSummary, error) {
+	if c.cache == nil || !c.cache.checked {
+		checked, _, err := c.Check()
+		if err != nil {
+			return nil, err
+		}
+		if !checked {
+			return nil, fmt.Errorf("%q is not checked", c.path)
+		}
+	}
+
+	hg := HgSummary{}
+
+	summary := &Summary{
+		PathCheckSummaryCommon: types.PathCheckSummaryCommon{
+			Unmodified: c.cache.unmodified,
+			Path:       c.cache.repoPath,
+			IsDir:      c.cache.isDir,
+			Digest:     c.digest,
+		},
+		Hg: &hg,
+	}
+
+	repo, err := c.repoInfo()
+	if err != nil {
+		return nil, err
+	}
+	hg.Revision = *repo.revision
+
+	if summary.Unmodified {
+		lastChangeset, err := c.run("log", "--limit", "1", "--rev", "reverse(::.)", "--template", "{node}\n")
+		if err != nil {
+			return nil, err
+		}
+		if len(lastChangeset) > 0 {
+			hg.LastChangeset = lastChangeset[0]
+		}
+	} else {
+		hg.ModifiedFiles = c.cache.modifiedFiles
+	}
+
+	hg.Remotes, summary.URI = repo.remotes, repo.uri
+
+	return summary, nil
+}
+
+func (c *PathChecker) Reset() { c.cache = nil }
+
+func (c *PathChecker) negative(err error) (bool, bool, error) {
+	c.Reset()
+	return false, false, err
+}
+
+// run executes hg command with the path as the only file pattern
+func (c *PathChecker) run(args ...string) ([]string, error) {
+	if c.cache.repoPath != "." {
+		args = append(args, "--", "path:"+c.cache.repoPath)
+	}
+	return c.cache.hg.RunLines(args...)
+}
+
+// repoInfo runs queries that are the same for all paths in the repo, unless
+// these already ran for the same root
+func (c *PathChecker) repoInfo() (*repoInfo, error) {
+	c.repos.lock.Lock()
+	defer c.repos.lock.Unlock()
+
+	if repo, ok := c.repos.repos[c.cache.root]; ok {
+		return repo, nil
+	}
+
+	files, err := c.cache.hg.RunLines("files", "--rev", ".")
+	if err != nil && command.ExitCode(err) != 1 {
+		// exit code 1 means that there are no files
+		return nil, err
+	}
+	modifiedFiles, err := c.cache.hg.RunLines("status", "--modified", "--added", "--removed", "--deleted", "--unknown", "--no-status")
+	if err != nil {
+		return nil, err
+	}
+	repo := &repoInfo{
+		files:         toSlash(files),
+		modifiedFiles: toSlash(modifiedFiles),
+	}
+	slices.Sort(repo.modifiedFiles)
+
+	repo.revision, err = c.revision()
+	if err != nil {
+		return nil, err
+	}
+	repo.remotes, repo.uri, err = c.remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.repos.repos == nil {
+		c.repos.repos = map[string]*repoInfo{}
+	}
+	c.repos.repos[c.cache.root] = repo
+	return repo, nil
+}
+
+// filterPaths returns paths that are the same as the path or are inside of it
+func (c *PathChecker) filterPaths(paths []string) []string {
+	if c.cache.repoPath == "." {
+		return slices.Clone(paths)
+	}
+	filtered := []string{}
+	for _, path := range paths {
+		if path == c.cache.repoPath || strings.HasPrefix(path, c.cache.repoPath+"/") {
+			filtered = append(filtered, path)
+		}
+	}
+	return filtered
+}
+
+func toSlash(paths []string) []string {
+	for i := range paths {
+		paths[i] = filepath.ToSlash(paths[i])
+	}
+	return paths
+}
+
+func (c *PathChecker) revision() (*HgRevision, error) {
+	output, err := c.cache.hg.Run("log", "--rev", ".", "--template", "json")
+	if err != nil {
+		return nil, err
+	}
+	revisions := []struct {
+		HgRevision
+		// date is a pair of unix timestamp and timezone offset
+		Date []float64 `json:"date"`
+	}{}
+	if err := json.Unmarshal(output, &revisions); err != nil {
+		return nil, fmt.Errorf("unable to parse revision info: %w", err)
+	}
+	if len(revisions) != 1 {
+		return nil, fmt.Errorf("unexpected number of revisions: %d", len(revisions))
+	}
+	revision := &revisions[0].HgRevision
+	if date := revisions[0].Date; len(date) > 0 {
+		commitTime := time.Unix(int64(date[0]), 0).UTC()
+		revision.CommitTime = &commitTime
+	}
+	// tip is a moving pointer and not a real tag
+	revision.Tags = slices.DeleteFunc(revision.Tags, func(tag string) bool { return tag == tipTag })
+	if len(revision.Tags) == 0 {
+		revision.Tags = nil
+	}
+	if len(revision.Bookmarks) == 0 {
+		revision.Bookmarks = nil
+	}
+	return revision, nil
+}
+
+func (c *PathChecker) remotes() (map[string]string, string, error) {
+	output, err := c.cache.hg.Run("paths", "--template", "json")
+	if err != nil {
+		return nil, "", err
+	}
+	paths := []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}{}
+	if err := json.Unmarshal(output, &paths); err != nil {
+		return nil, "", fmt.Errorf("unable to parse remotes: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, "", nil
+	}
+
+	remotes := make(map[string]string, len(paths))
+	for _, path := range paths {
+		remotes[path.Name] = path.URL
+	}
+	// fallback to first entry
+	primaryURL := paths[0].URL
+	if url, ok := remotes[DefaultPrimaryRemoteName]; ok {
+		primaryURL = url
+	}
+	return remotes, primaryURL, nil
+}
@@ -0,0 +1,135 @@
+package hg_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/attest/vcs/hg"
+)
+
+func TestHgProvider(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg command is not available")
+	}
+
+	g := NewWithT(t)
+
+	root := makeFixtureRepo(t)
+
+	checker := NewPathChecker(filepath.Join(root, "non-existent"), "")
+	checked, _, err := checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeFalse())
+
+	checker = NewPathChecker(filepath.Join(root, "dir"), "")
+	checked, unmodified, err := checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeTrue())
+
+	summary, err := checker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	hgSummary, ok := summary.Full().(*Summary)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(hgSummary.Path).To(Equal("dir"))
+	g.Expect(hgSummary.IsDir).To(BeTrue())
+	g.Expect(hgSummary.URI).To(Equal("https://example.com/repo"))
+	g.Expect(hgSummary.Hg.Revision.Node).To(HaveLen(40))
+	g.Expect(hgSummary.Hg.Revision.Branch).To(Equal("default"))
+	g.Expect(hgSummary.Hg.Revision.Bookmarks).To(ConsistOf("main"))
+	g.Expect(hgSummary.Hg.Revision.Tags).To(ConsistOf("v0.1.0"))
+	g.Expect(hgSummary.Hg.LastChangeset).To(Equal(hgSummary.Hg.Revision.Node))
+	g.Expect(hgSummary.Hg.Remotes).To(HaveKeyWithValue("default", "https://example.com/repo"))
+	g.Expect(hgSummary.Hg.ModifiedFiles).To(BeEmpty())
+
+	// checkers made by the same factory share results of repo-level queries
+	newPathChecker := NewPathCheckerFactory()
+	fileChecker := newPathChecker(filepath.Join(root, "dir", "a.yaml"), "")
+	fileSummary, err := fileChecker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fileSummary.Common().IsDir).To(BeFalse())
+	g.Expect(fileSummary.SameRepo(summary)).To(BeTrue())
+
+	otherFileChecker := newPathChecker(filepath.Join(root, "dir", "b.yaml"), "")
+	otherFileSummary, err := otherFileChecker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherFileSummary.Common().Path).To(Equal("dir/b.yaml"))
+	g.Expect(otherFileSummary.SameRepo(fileSummary)).To(BeTrue())
+
+	g.Expect(os.WriteFile(filepath.Join(root, "dir", "a.yaml"), []byte("kind: Modified\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "dir", "new.yaml"), []byte("kind: New\n"), 0o644)).To(Succeed())
+
+	checker = NewPathChecker(filepath.Join(root, "dir"), "")
+	checked, unmodified, err = checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeFalse())
+
+	summary, err = checker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(summary.Full().(*Summary).Hg.ModifiedFiles).To(Equal([]string{"dir/a.yaml", "dir/new.yaml"}))
+
+	checker = NewPathChecker(filepath.Join(root, "other.yaml"), "")
+	checked, unmodified, err = checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeTrue())
+}
+
+func TestHgProviderWithoutCommand(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	g.Expect(os.Mkdir(filepath.Join(root, ".hg"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "a.yaml"), []byte("kind: A\n"), 0o644)).To(Succeed())
+
+	t.Setenv("PATH", t.TempDir())
+
+	// same as with jj, the repo is not detected, so that fallback provider is used
+	checker := NewPathCheckerFactory()(filepath.Join(root, "a.yaml"), "")
+	ok, err := checker.DetectRepo()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
+
+func makeFixtureRepo(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	hg := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("hg", args...)
+		cmd.Dir = root
+		cmd.Env = append(os.Environ(), "HGPLAIN=1", "HGRCPATH=", "HGUSER=test <test@example.com>")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("hg %v failed: %s\n%s", args, err, output)
+		}
+	}
+	writeFile := func(path, contents string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hg("init")
+	writeFile(".hg/hgrc", "[paths]\ndefault = https://example.com/repo\n")
+	writeFile("dir/a.yaml", "kind: A\n")
+	writeFile("dir/b.yaml", "kind: B\n")
+	writeFile("other.yaml", "kind: Other\n")
+	hg("add")
+	hg("commit", "--message", "initial commit")
+	hg("bookmark", "main")
+	// local tags don't require a commit, so the working copy parent is tagged
+	hg("tag", "--local", "v0.1.0")
+
+	return root
+}
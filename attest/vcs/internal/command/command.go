@@ -0,0 +1,97 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Runner runs commands of a VCS tool in a given directory
+type Runner struct {
+	Name string
+	Dir  string
+	Env  []string
+}
+
+func IsAvailable(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Run returns stdout of the command, on failure stderr is included in the error
+func (r *Runner) Run(args ...string) ([]byte, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(r.Name, args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), r.Env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &Error{
+			Command: r.Name + " " + strings.Join(args, " "),
+			Stderr:  strings.TrimSpace(stderr.String()),
+			err:     err,
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// RunLines is the same as Run, but splits the output into non-empty lines
+func (r *Runner) RunLines(args ...string) ([]string, error) {
+	output, err := r.Run(args...)
+	if err != nil {
+		return nil, err
+	}
+	return SplitLines(output), nil
+}
+
+func SplitLines(output []byte) []string {
+	lines := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+type Error struct {
+	Command string
+	Stderr  string
+	err     error
+}
+
+func (e *Error) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command %q failed: %s", e.Command, e.err)
+	}
+	return fmt.Sprintf("command %q failed: %s: %s", e.Command, e.err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error { return e.err }
+
+// ExitCode returns exit code of the command, or -1 if err is not an exit error
+func ExitCode(err error) int {
+	exitErr := &exec.ExitError{}
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// FindRoot walks up from path looking for a directory that contains marker,
+// it returns an empty string if none was found
+func FindRoot(path, marker string) string {
+	if info, err := os.Stat(filepath.Join(path, marker)); err == nil && info.IsDir() {
+		return path
+	}
+	dir := filepath.Dir(path)
+	if dir == path { // reached root
+		return ""
+	}
+	return FindRoot(dir, marker)
+}
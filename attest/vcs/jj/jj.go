@@ -0,0 +1,327 @@
+package jj
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/internal/command"
+)
+
+const (
+	ProviderName = "jj"
+
+	DefaultPrimaryRemoteName = "origin"
+
+	jjCommand  = "jj"
+	repoMarker = ".jj"
+
+	workingCopyRevision = "@"
+	parentRevisions     = "@-"
+
+	// each field is printed on a separate line, bookmarks and tags are space-separated
+	revisionTemplate = `commit_id ++ "\n" ++ change_id ++ "\n" ++ local_bookmarks ++ "\n" ++ tags ++ "\n" ++ ` +
+		`committer.timestamp().format("%s") ++ "\n"`
+	revisionNumFields = 5
+)
+
+func NewPathChecker(path string, digest digest.SHA256) types.PathChecker {
+	return &PathChecker{
+		path:   path,
+		digest: digest,
+	}
+}
+
+type PathChecker struct {
+	path   string
+	digest digest.SHA256
+	cache  *pathCheckerCache
+}
+
+type (
+	Summary struct {
+		types.PathCheckSummaryCommon `json:",inline"`
+
+		JJ *JJSummary `json:"jj,omitempty"`
+	}
+
+	JJSummary struct {
+		// working copy is a commit in Jujutsu, when path is unmodified
+		// its contents are the same as in the parent commit(s)
+		WorkingCopy   JJRevision        `json:"workingCopy"`
+		Parents       []JJRevision      `json:"parents,omitempty"`
+		Remotes       map[string]string `json:"remotes,omitempty"`
+		ModifiedFiles []string          `json:"modifiedFiles,omitempty"`
+	}
+
+	JJRevision struct {
+		CommitID   string     `json:"commitID"`
+		ChangeID   string     `json:"changeID"`
+		Bookmarks  []string   `json:"bookmarks,omitempty"`
+		Tags       []string   `json:"tags,omitempty"`
+		CommitTime *time.Time `json:"commitTime,omitempty"`
+	}
+)
+
+type pathCheckerCache struct {
+	checked       bool
+	unmodified    bool
+	isDir         bool
+	absPath       string
+	root          string
+	repoPath      string
+	modifiedFiles []string
+	jj            *command.Runner
+}
+
+func (s *Summary) SameRepo(other types.PathCheckSummary) bool {
+	if other.ProviderName() != ProviderName {
+		return false
+	}
+	otherSummary, ok := other.Full().(*Summary)
+	if !ok || s.JJ == nil || otherSummary.JJ == nil {
+		return false
+	}
+	return s.URI == otherSummary.URI &&
+		s.JJ.WorkingCopy.CommitID == otherSummary.JJ.WorkingCopy.CommitID &&
+		len(s.JJ.Remotes) == len(otherSummary.JJ.Remotes)
+}
+
+func (s *Summary) Full() interface{} { return s }
+
+func (s *Summary) ProviderName() string { return ProviderName }
+
+func (PathChecker) ProviderName() string { return ProviderName }
+
+// CommitTime returns time of the parent commit, as working copy commit
+// gets updated every time a snapshot is taken
+func (s *Summary) CommitTime() *time.Time {
+	if s.JJ == nil || len(s.JJ.Parents) == 0 {
+		return nil
+	}
+	return s.JJ.Parents[0].CommitTime
+}
+
+// DetectRepo looks for Jujutsu repository that contains the path, when jj command
+// is not available, the repository is not detected, so that git provider can be
+// used instead for repositories that are colocated with git
+func (c *PathChecker) DetectRepo() (bool, error) {
+	if c.cache == nil {
+		c.cache = &pathCheckerCache{}
+	}
+
+	absPath, err := filepath.Abs(c.path)
+	if err != nil {
+		return false, err
+	}
+	root := command.FindRoot(absPath, repoMarker)
+	if root == "" || !command.IsAvailable(jjCommand) {
+		return false, nil
+	}
+
+	c.cache.absPath = absPath
+	c.cache.root = root
+	c.cache.jj = &command.Runner{
+		Name: jjCommand,
+		Dir:  root,
+	}
+	return true, nil
+}
+
+func (c *PathChecker) Check() (bool, bool, error) {
+	if c.cache == nil || c.cache.jj == nil {
+		ok, err := c.DetectRepo()
+		if err != nil {
+			return c.negative(err)
+		}
+		if !ok {
+			return c.negative(nil)
+		}
+	}
+
+	repoPath, err := filepath.Rel(c.cache.root, c.cache.absPath)
+	if err != nil {
+		return c.negative(err)
+	}
+	c.cache.repoPath = filepath.ToSlash(repoPath)
+
+	// the first command takes a snapshot of the working copy, so that any changes
+	// are recorded in the working copy commit, all subsequent commands can use it
+	files, err := c.cache.jj.RunLines(c.withFileset("--color=never", "file", "list", "-r", workingCopyRevision)...)
+	if err != nil {
+		return c.negative(err)
+	}
+	if len(files) == 0 {
+		// path may have been removed from working copy, it's still
+		// considered as checked when it's present in parent commit(s)
+		files, err = c.run("file", "list", "-r", parentRevisions)
+		if err != nil {
+			return c.negative(err)
+		}
+	}
+	if len(files) == 0 {
+		return c.negative(nil)
+	}
+	c.cache.isDir = c.cache.repoPath == "." || len(files) > 1 || filepath.ToSlash(files[0]) != c.cache.repoPath
+
+	modifiedFiles, err := c.run("diff", "-r", workingCopyRevision, "--name-only")
+	if err != nil {
+		return c.negative(err)
+	}
+	for i := range modifiedFiles {
+		modifiedFiles[i] = filepath.ToSlash(modifiedFiles[i])
+	}
+	slices.Sort(modifiedFiles)
+
+	c.cache.modifiedFiles = modifiedFiles
+	c.cache.unmodified = len(modifiedFiles) == 0
+	c.cache.checked = true
+
+	return c.cache.checked, c.cache.unmodified, nil
+}
+
+func (c *PathChecker) MakeSummary() (types.PathCheckSummary, error) {
+	if c.cache == nil || !c.cache.checked {
+		checked, _, err := c.Check()
+		if err != nil {
+			return nil, err
+		}
+		if !checked {
+			return nil, fmt.Errorf("%q is not checked", c.path)
+		}
+	}
+
+	jj := JJSummary{}
+
+	summary := &Summary{
+		PathCheckSummaryCommon: types.PathCheckSummaryCommon{
+			Unmodified: c.cache.unmodified,
+			Path:       c.cache.repoPath,
+			IsDir:      c.cache.isDir,
+			Digest:     c.digest,
+		},
+		JJ: &jj,
+	}
+
+	workingCopy, err := c.revisions(workingCopyRevision)
+	if err != nil {
+		return nil, err
+	}
+	if len(workingCopy) != 1 {
+		return nil, fmt.Errorf("unexpected number of working copy revisions: %d", len(workingCopy))
+	}
+	jj.WorkingCopy = workingCopy[0]
+
+	jj.Parents, err = c.revisions(parentRevisions)
+	if err != nil {
+		return nil, err
+	}
+
+	if !summary.Unmodified {
+		jj.ModifiedFiles = c.cache.modifiedFiles
+	}
+
+	jj.Remotes, summary.URI, err = c.remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func (c *PathChecker) Reset() { c.cache = nil }
+
+func (c *PathChecker) negative(err error) (bool, bool, error) {
+	c.Reset()
+	return false, false, err
+}
+
+// run executes jj command with the path as fileset, it doesn't snapshot the working copy
+func (c *PathChecker) run(args ...string) ([]string, error) {
+	return c.cache.jj.RunLines(c.withFileset(append([]string{"--color=never", "--ignore-working-copy"}, args...)...)...)
+}
+
+func (c *PathChecker) withFileset(args ...string) []string {
+	if c.cache.repoPath == "." {
+		return args
+	}
+	return append(args, "--", "root:"+strconv.Quote(c.cache.repoPath))
+}
+
+func (c *PathChecker) revisions(revset string) ([]JJRevision, error) {
+	output, err := c.cache.jj.Run("--color=never", "--ignore-working-copy",
+		"log", "--no-graph", "-r", revset, "-T", revisionTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines)%revisionNumFields != 0 {
+		return nil, fmt.Errorf("unable to parse revision info: unexpected number of lines (%d)", len(lines))
+	}
+	revisions := make([]JJRevision, 0, len(lines)/revisionNumFields)
+	for i := 0; i < len(lines); i += revisionNumFields {
+		timestamp, err := strconv.ParseInt(lines[i+4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse commit timestamp: %w", err)
+		}
+		commitTime := time.Unix(timestamp, 0).UTC()
+		revisions = append(revisions, JJRevision{
+			CommitID:   lines[i],
+			ChangeID:   lines[i+1],
+			Bookmarks:  refNames(lines[i+2]),
+			Tags:       refNames(lines[i+3]),
+			CommitTime: &commitTime,
+		})
+	}
+	return revisions, nil
+}
+
+// refNames parses a list of names, it removes markers that indicate whether a ref
+// is conflicted or out of sync with a remote
+func refNames(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i := range fields {
+		names[i] = strings.TrimRight(fields[i], "*?")
+	}
+	return names
+}
+
+func (c *PathChecker) remotes() (map[string]string, string, error) {
+	lines, err := c.cache.jj.RunLines("--color=never", "--ignore-working-copy", "git", "remote", "list")
+	if err != nil {
+		return nil, "", err
+	}
+	if len(lines) == 0 {
+		return nil, "", nil
+	}
+
+	remotes := make(map[string]string, len(lines))
+	primaryURL := ""
+	for _, line := range lines {
+		name, url, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, "", fmt.Errorf("unable to parse remote: %q", line)
+		}
+		remotes[name] = url
+		if primaryURL == "" {
+			// fallback to first entry
+			primaryURL = url
+		}
+	}
+	if url, ok := remotes[DefaultPrimaryRemoteName]; ok {
+		primaryURL = url
+	}
+	return remotes, primaryURL, nil
+}
@@ -0,0 +1,111 @@
+package jj_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/attest/vcs/jj"
+)
+
+func TestJJProvider(t *testing.T) {
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("jj command is not available")
+	}
+
+	g := NewWithT(t)
+
+	root := makeFixtureRepo(t)
+
+	checker := NewPathChecker(filepath.Join(root, "non-existent"), "")
+	checked, _, err := checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeFalse())
+
+	checker = NewPathChecker(filepath.Join(root, "dir"), "")
+	checked, unmodified, err := checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeTrue())
+
+	summary, err := checker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	jjSummary, ok := summary.Full().(*Summary)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(jjSummary.Path).To(Equal("dir"))
+	g.Expect(jjSummary.IsDir).To(BeTrue())
+	g.Expect(jjSummary.URI).To(Equal("https://example.com/repo.git"))
+	g.Expect(jjSummary.JJ.WorkingCopy.CommitID).To(HaveLen(40))
+	g.Expect(jjSummary.JJ.Parents).To(HaveLen(1))
+	g.Expect(jjSummary.JJ.Parents[0].Bookmarks).To(ConsistOf("main"))
+	g.Expect(jjSummary.JJ.Remotes).To(HaveKeyWithValue("origin", "https://example.com/repo.git"))
+	g.Expect(jjSummary.JJ.ModifiedFiles).To(BeEmpty())
+
+	fileChecker := NewPathChecker(filepath.Join(root, "dir", "a.yaml"), "")
+	fileSummary, err := fileChecker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fileSummary.Common().IsDir).To(BeFalse())
+	g.Expect(fileSummary.SameRepo(summary)).To(BeTrue())
+
+	g.Expect(os.WriteFile(filepath.Join(root, "dir", "a.yaml"), []byte("kind: Modified\n"), 0o644)).To(Succeed())
+
+	checker = NewPathChecker(filepath.Join(root, "dir"), "")
+	checked, unmodified, err = checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeFalse())
+
+	summary, err = checker.MakeSummary()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(summary.Full().(*Summary).JJ.ModifiedFiles).To(Equal([]string{"dir/a.yaml"}))
+
+	checker = NewPathChecker(filepath.Join(root, "other.yaml"), "")
+	checked, unmodified, err = checker.Check()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checked).To(BeTrue())
+	g.Expect(unmodified).To(BeTrue())
+}
+
+func makeFixtureRepo(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	config := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(config, []byte("[user]\nname = \"test\"\nemail = \"test@example.com\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JJ_CONFIG", config)
+
+	jj := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("jj", args...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("jj %v failed: %s\n%s", args, err, output)
+		}
+	}
+	writeFile := func(path, contents string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jj("git", "init")
+	jj("git", "remote", "add", "origin", "https://example.com/repo.git")
+	writeFile("dir/a.yaml", "kind: A\n")
+	writeFile("dir/b.yaml", "kind: B\n")
+	writeFile("other.yaml", "kind: Other\n")
+	// commit leaves an empty working copy commit on top of the new one
+	jj("commit", "--message", "initial commit")
+	jj("bookmark", "create", "main", "-r", "@-")
+
+	return root
+}
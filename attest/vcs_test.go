@@ -11,10 +11,12 @@ import (
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/otiai10/copy"
 
 	. "github.com/onsi/gomega"
 
 	. "github.com/errordeveloper/tape/attest"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
 	"github.com/errordeveloper/tape/attest/vcs/git"
 	"github.com/errordeveloper/tape/manifest/imagescanner"
 	"github.com/errordeveloper/tape/manifest/loader"
@@ -172,6 +174,50 @@ func makeVCSTest(repos *repos, tc vcsTestCase) func(t *testing.T) {
 	}
 }
 
+func TestNoVCS(t *testing.T) {
+	g := NewWithT(t)
+
+	tempDir := t.TempDir()
+	g.Expect(copy.Copy("../manifest/testdata/basic", tempDir)).To(Succeed())
+
+	workDir, err := os.Getwd()
+	g.Expect(err).NotTo(HaveOccurred())
+	loadPath, err := filepath.Rel(workDir, tempDir)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	loader := loader.NewRecursiveManifestDirectoryLoader(loadPath)
+	g.Expect(loader.Load()).To(Succeed())
+
+	source := dir.Source{URL: "https://example.com/basic.tar.gz", Revision: "v0.1.0"}
+	repoDetected, attreg, err := DetectVCSWithFallback(loadPath, source)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repoDetected).To(BeFalse())
+	g.Expect(attreg).ToNot(BeNil())
+
+	scanner := imagescanner.NewDefaultImageScanner()
+	scanner.WithProvinanceAttestor(attreg)
+	g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+
+	collection, err := attreg.MakePathCheckSummarySummaryCollection()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(collection.Providers).To(ConsistOf(dir.ProviderName))
+	g.Expect(collection.EntryGroups).To(HaveLen(1))
+	g.Expect(collection.EntryGroups[0]).To(HaveLen(len(loader.Paths()) + 1))
+
+	baseDirSummary, ok := attreg.BaseDirSummary().Full().(*dir.Summary)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(baseDirSummary.Path).To(Equal("."))
+	g.Expect(baseDirSummary.Dir.Source).To(Equal(&source))
+	g.Expect(baseDirSummary.Dir.TreeDigest).ToNot(BeEmpty())
+	g.Expect(baseDirSummary.Dir.Files).To(HaveLen(len(loader.Paths())))
+
+	g.Expect(attreg.AssociateCoreStatements()).To(Succeed())
+
+	statements := attreg.GetStatements()
+	g.Expect(statements).To(HaveLen(1))
+	g.Expect(statements[0].GetSubject()).To(HaveLen(len(loader.Paths())))
+}
+
 type repos struct {
 	workDir string
 	tempDir string
@@ -0,0 +1,243 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/attest/manifest"
+	"github.com/errordeveloper/tape/attest/types"
+)
+
+const (
+	// StatusVerified is set for files that match a subject
+	StatusVerified = "verified"
+	// StatusMismatch is set for files that have subjects, but don't match any of them
+	StatusMismatch = "mismatch"
+	// StatusUncovered is set for files that have no subjects
+	StatusUncovered = "uncovered"
+)
+
+// FileResult is the result of verification of a file
+type FileResult struct {
+	Path   string
+	Digest digest.SHA256
+	Status string
+	// PredicateTypes lists types of statements that have subjects which match the file
+	PredicateTypes []string
+}
+
+// Summary lists results of all files that were verified
+type Summary struct {
+	Files                         []FileResult
+	Verified, Mismatch, Uncovered int
+}
+
+// Err returns an error listing all files that didn't match, or nil when all files were verified
+func (s *Summary) Err() error {
+	if s.Mismatch == 0 && s.Uncovered == 0 {
+		return nil
+	}
+	messages := []string{}
+	for _, file := range s.Files {
+		switch file.Status {
+		case StatusMismatch:
+			messages = append(messages, fmt.Sprintf("%s: digest %s doesn't match any of the subjects", file.Path, file.Digest))
+		case StatusUncovered:
+			messages = append(messages, fmt.Sprintf("%s: not covered by any of the subjects", file.Path))
+		}
+	}
+	return fmt.Errorf("verification failed for %d of %d files:\n%s", s.Mismatch+s.Uncovered, len(s.Files), strings.Join(messages, "\n"))
+}
+
+// Verifier checks that files of an artefact are covered by statements, i.e. that digest
+// of each file matches a subject of directory contents or replaced image reference
+// statement, or an object of normalisation statement
+type Verifier struct {
+	// subjects maps paths in the artefact to digests and types of predicates about these
+	subjects map[string]map[digest.SHA256][]string
+	summary  Summary
+}
+
+func NewVerifier(statements []types.ExportedStatement) (*Verifier, error) {
+	v := &Verifier{
+		subjects: map[string]map[digest.SHA256][]string{},
+	}
+
+	roots := []manifest.SourceRoot{}
+	// since v0.3 names of subjects are qualified with the root when there are
+	// multiple roots, older artefacts have names that are never qualified
+	qualified := false
+	for _, statement := range statements {
+		if !isManifestDir(statement.PredicateType) {
+			continue
+		}
+		predicate := struct {
+			SourceDirectory struct {
+				Path  string                `json:"path"`
+				Roots []manifest.SourceRoot `json:"roots"`
+			} `json:"containedInDirectory"`
+		}{}
+		if err := convertPredicate(statement, &predicate); err != nil {
+			return nil, err
+		}
+		if len(predicate.SourceDirectory.Roots) == 0 {
+			roots = append(roots, manifest.SourceRoot{Path: predicate.SourceDirectory.Path})
+		} else {
+			roots = append(roots, predicate.SourceDirectory.Roots...)
+		}
+		if statement.PredicateType == manifest.ManifestDirPredicateType && len(predicate.SourceDirectory.Roots) > 1 {
+			qualified = true
+		}
+	}
+
+	for _, statement := range statements {
+		switch statement.PredicateType {
+		case manifest.ManifestDirPredicateType, manifest.ManifestDirV02PredicateType, manifest.ReplacedImageRefPredicateType:
+			for _, subject := range statement.Subject {
+				value, ok := subject.Digest["sha256"]
+				if !ok {
+					continue
+				}
+				paths := unqualifiedPathsInArtefact(subject.Name, roots)
+				if qualified {
+					paths = qualifiedPathsInArtefact(subject.Name, roots)
+				}
+				for _, path := range paths {
+					v.add(path, digest.SHA256(value), statement.PredicateType)
+				}
+			}
+		case manifest.ManifestNormalisationPredicateType:
+			predicate := struct {
+				manifest.NormalisedObjects `json:"normalisedObjects"`
+			}{}
+			if err := convertPredicate(statement, &predicate); err != nil {
+				return nil, err
+			}
+			for _, object := range predicate.Objects {
+				v.add(object.Path, object.Digest, statement.PredicateType)
+			}
+		}
+	}
+	if len(v.subjects) == 0 {
+		return nil, fmt.Errorf("no statements with subjects that files can be verified against")
+	}
+	return v, nil
+}
+
+func (v *Verifier) add(path string, fileDigest digest.SHA256, predicateType string) {
+	if _, ok := v.subjects[path]; !ok {
+		v.subjects[path] = map[digest.SHA256][]string{}
+	}
+	if !slices.Contains(v.subjects[path][fileDigest], predicateType) {
+		v.subjects[path][fileDigest] = append(v.subjects[path][fileDigest], predicateType)
+	}
+}
+
+// Verify checks a file at the given path in the artefact, result is added to the summary
+func (v *Verifier) Verify(path string, data []byte) FileResult {
+	result := FileResult{
+		Path:   path,
+		Digest: digest.SHA256(fmt.Sprintf("%x", sha256.Sum256(data))),
+	}
+	digests, ok := v.subjects[path]
+	switch {
+	case !ok:
+		result.Status = StatusUncovered
+		v.summary.Uncovered++
+	case digests[result.Digest] == nil:
+		result.Status = StatusMismatch
+		v.summary.Mismatch++
+	default:
+		result.Status = StatusVerified
+		result.PredicateTypes = digests[result.Digest]
+		v.summary.Verified++
+	}
+	v.summary.Files = append(v.summary.Files, result)
+	return result
+}
+
+func (v *Verifier) Summary() *Summary { return &v.summary }
+
+func isManifestDir(predicateType string) bool {
+	return predicateType == manifest.ManifestDirPredicateType || predicateType == manifest.ManifestDirV02PredicateType
+}
+
+// qualifiedPathsInArtefact returns path that a subject name qualified with prefix of the
+// root as '<prefix>//<path>' refers to, the path is relative to the repo root, and files
+// of each root are placed at its prefix; only subjects of normalised files are not
+// qualified, as these are already relative to the artefact
+func qualifiedPathsInArtefact(name string, roots []manifest.SourceRoot) []string {
+	prefix, pathInRepo, ok := strings.Cut(name, manifest.SourceRootSeparator)
+	if !ok {
+		return []string{name}
+	}
+	for _, root := range roots {
+		if root.Prefix != prefix {
+			continue
+		}
+		if pathInRepo == root.Path {
+			return []string{root.Prefix}
+		}
+		if rel, ok := relPath(root.Path, pathInRepo); ok {
+			return []string{path.Join(root.Prefix, rel)}
+		}
+	}
+	return nil
+}
+
+// unqualifiedPathsInArtefact returns paths that a subject name may refer to, names of
+// subjects are relative to the repo root, and files of each root are placed at its prefix;
+// subjects of normalised files are already relative to the artefact, so the name is
+// returned as is too; older artefacts with multiple roots have names that are not
+// qualified, and these may refer to a path in any of the roots
+func unqualifiedPathsInArtefact(name string, roots []manifest.SourceRoot) []string {
+	paths := []string{name}
+	for _, root := range roots {
+		if name == root.Path && root.Prefix != "" {
+			paths = append(paths, root.Prefix)
+			continue
+		}
+		rel, ok := relPath(root.Path, name)
+		if !ok {
+			continue
+		}
+		if root.Prefix == "" && rel == "." {
+			// a single file root without prefix is placed at its base name
+			rel = path.Base(name)
+		}
+		paths = append(paths, path.Join(root.Prefix, rel))
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+func relPath(base, name string) (string, bool) {
+	base, name = path.Clean(base), path.Clean(name)
+	switch {
+	case base == ".":
+		return name, true
+	case name == base:
+		return ".", true
+	case strings.HasPrefix(name, base+"/"):
+		return strings.TrimPrefix(name, base+"/"), true
+	default:
+		return "", false
+	}
+}
+
+func convertPredicate(statement types.ExportedStatement, predicate any) error {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(statement.Predicate); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(buf).Decode(predicate); err != nil {
+		return fmt.Errorf("unable to decode predicate of type %q: %w", statement.PredicateType, err)
+	}
+	return nil
+}
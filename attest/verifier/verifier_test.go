@@ -0,0 +1,217 @@
+package verifier_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/attest/manifest"
+	"github.com/errordeveloper/tape/attest/types"
+	. "github.com/errordeveloper/tape/attest/verifier"
+	"github.com/errordeveloper/tape/manifest/normaliser"
+)
+
+func sha256Of(data string) digest.SHA256 {
+	return digest.SHA256(fmt.Sprintf("%x", sha256.Sum256([]byte(data))))
+}
+
+func statement(predicateType string, predicate any, subjects ...string) types.ExportedStatement {
+	statement := types.ExportedStatement{
+		Type:          types.CurrentStatementType,
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}
+	for i := 0; i+1 < len(subjects); i += 2 {
+		statement.Subject = append(statement.Subject, types.ResourceDescriptor{
+			Name:   subjects[i],
+			Digest: sha256Of(subjects[i+1]).DigestSet(),
+		})
+	}
+	return statement
+}
+
+func dirContents(path string, roots []manifest.SourceRoot, subjects ...string) types.ExportedStatement {
+	return statement(manifest.ManifestDirPredicateType, manifest.SourceDirectoryContents{
+		SourceDirectory: manifest.SourceDirectory{Path: path, Roots: roots},
+	}, subjects...)
+}
+
+func dirContentsV02(path string, roots []manifest.SourceRoot, subjects ...string) types.ExportedStatement {
+	statement := dirContents(path, roots, subjects...)
+	statement.PredicateType = manifest.ManifestDirV02PredicateType
+	return statement
+}
+
+func TestVerifier(t *testing.T) {
+	testCases := []struct {
+		description    string
+		statements     []types.ExportedStatement
+		files          map[string]string
+		expectedStatus map[string]string
+		expectedErr    string
+	}{
+		{
+			description: "single directory with replaced images",
+			statements: []types.ExportedStatement{
+				dirContents("deploy/app", nil,
+					"deploy/app/deployment.yaml", "original",
+					"deploy/app/service.yaml", "service",
+				),
+				statement(manifest.ReplacedImageRefPredicateType, nil, "deploy/app/deployment.yaml", "updated"),
+			},
+			files: map[string]string{
+				"deployment.yaml": "updated",
+				"service.yaml":    "changed",
+				"extra.yaml":      "extra",
+			},
+			expectedStatus: map[string]string{
+				"deployment.yaml": StatusVerified,
+				"service.yaml":    StatusMismatch,
+				"extra.yaml":      StatusUncovered,
+			},
+			expectedErr: "verification failed for 2 of 3 files",
+		},
+		{
+			description: "multiple roots in older artefact",
+			statements: []types.ExportedStatement{
+				dirContentsV02("base", []manifest.SourceRoot{
+					{Path: "base", Prefix: "base"},
+					{Path: "extra/crds.yaml", Prefix: "crds.yaml"},
+				},
+					"base/deployment.yaml", "deployment",
+					"extra/crds.yaml", "crds",
+				),
+			},
+			files: map[string]string{
+				"base/deployment.yaml": "deployment",
+				"crds.yaml":            "crds",
+			},
+			expectedStatus: map[string]string{
+				"base/deployment.yaml": StatusVerified,
+				"crds.yaml":            StatusVerified,
+			},
+		},
+		{
+			description: "multiple roots with qualified subjects",
+			statements: []types.ExportedStatement{
+				dirContents("base", []manifest.SourceRoot{
+					{Path: "base", Prefix: "base"},
+					{Path: "extra/crds.yaml", Prefix: "crds.yaml"},
+				},
+					"base//base/deployment.yaml", "deployment",
+					"crds.yaml//extra/crds.yaml", "crds",
+				),
+			},
+			files: map[string]string{
+				"base/deployment.yaml": "deployment",
+				"crds.yaml":            "crds",
+			},
+			expectedStatus: map[string]string{
+				"base/deployment.yaml": StatusVerified,
+				"crds.yaml":            StatusVerified,
+			},
+		},
+		{
+			description: "multiple roots with subjects that are not qualified",
+			statements: []types.ExportedStatement{
+				dirContents("base", []manifest.SourceRoot{
+					{Path: "base", Prefix: "base"},
+					{Path: "extra/crds.yaml", Prefix: "crds.yaml"},
+				},
+					"base/deployment.yaml", "deployment",
+					"extra/crds.yaml", "crds",
+				),
+			},
+			files: map[string]string{
+				"base/deployment.yaml": "deployment",
+				"crds.yaml":            "crds",
+			},
+			expectedStatus: map[string]string{
+				"base/deployment.yaml": StatusVerified,
+				"crds.yaml":            StatusUncovered,
+			},
+			expectedErr: "verification failed for 1 of 2 files",
+		},
+		{
+			description: "multiple roots at the same path in different repos",
+			statements: []types.ExportedStatement{
+				dirContents(".", []manifest.SourceRoot{
+					{Path: ".", Prefix: "a"},
+					{Path: ".", Prefix: "b"},
+				},
+					"a//deploy.yaml", "a",
+					"b//deploy.yaml", "b",
+				),
+			},
+			files: map[string]string{
+				"a/deploy.yaml": "a",
+				"b/deploy.yaml": "a",
+				"deploy.yaml":   "a",
+			},
+			expectedStatus: map[string]string{
+				"a/deploy.yaml": StatusVerified,
+				"b/deploy.yaml": StatusMismatch,
+				"deploy.yaml":   StatusUncovered,
+			},
+			expectedErr: "verification failed for 2 of 3 files",
+		},
+		{
+			description: "single file",
+			statements: []types.ExportedStatement{
+				dirContents("deploy/all.yaml", nil, "deploy/all.yaml", "all"),
+			},
+			files:          map[string]string{"all.yaml": "all"},
+			expectedStatus: map[string]string{"all.yaml": StatusVerified},
+		},
+		{
+			description: "normalised files",
+			statements: []types.ExportedStatement{
+				dirContents(".", nil, "all.yaml", "all"),
+				statement(manifest.ManifestNormalisationPredicateType, struct {
+					manifest.NormalisedObjects `json:"normalisedObjects"`
+				}{manifest.NormalisedObjects{Objects: []normaliser.Object{
+					{Kind: "Namespace", Name: "app", Path: "namespace-app.yaml", Digest: sha256Of("namespace")},
+					{Kind: "Deployment", Name: "app", Namespace: "app", Path: "app/deployment-app.yaml", Digest: sha256Of("deployment")},
+				}}}, "all.yaml", "all"),
+				statement(manifest.ReplacedImageRefPredicateType, nil, "app/deployment-app.yaml", "deployment"),
+			},
+			files: map[string]string{
+				"namespace-app.yaml":      "namespace",
+				"app/deployment-app.yaml": "deployment",
+			},
+			expectedStatus: map[string]string{
+				"namespace-app.yaml":      StatusVerified,
+				"app/deployment-app.yaml": StatusVerified,
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			v, err := NewVerifier(tc.statements)
+			g.Expect(err).NotTo(HaveOccurred())
+			for path, data := range tc.files {
+				result := v.Verify(path, []byte(data))
+				g.Expect(result.Status).To(Equal(tc.expectedStatus[path]), path)
+				g.Expect(result.Digest).To(Equal(sha256Of(data)))
+			}
+
+			summary := v.Summary()
+			g.Expect(summary.Files).To(HaveLen(len(tc.files)))
+			if tc.expectedErr != "" {
+				g.Expect(summary.Err()).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(summary.Err()).NotTo(HaveOccurred())
+		})
+	}
+
+	_, err := NewVerifier([]types.ExportedStatement{statement("example.com/Other/v1", nil, "a.yaml", "a")})
+	NewWithT(t).Expect(err).To(MatchError(ContainSubstring("no statements with subjects")))
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Version of the event schema, it changes when fields are
+// removed or renamed, new fields may be added at any time
+const Version = "v1"
+
+type Type string
+
+const (
+	TypeManifestLoaded   Type = "ManifestLoaded"
+	TypeImageFound       Type = "ImageFound"
+	TypeDigestResolved   Type = "DigestResolved"
+	TypeRelatedTagFound  Type = "RelatedTagFound"
+	TypeBlobCopyProgress Type = "BlobCopyProgress"
+	TypePackagePushed    Type = "PackagePushed"
+)
+
+type (
+	// Data is implemented by each of the event payloads
+	Data interface {
+		EventType() Type
+	}
+
+	Event struct {
+		Version string    `json:"version"`
+		Type    Type      `json:"type"`
+		Time    time.Time `json:"time"`
+		Data    Data      `json:"data"`
+	}
+
+	Handler func(Event)
+
+	ManifestLoaded struct {
+		Path string `json:"path"`
+	}
+
+	ImageFound struct {
+		Ref      string `json:"ref"`
+		Manifest string `json:"manifest"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	}
+
+	DigestResolved struct {
+		Ref    string `json:"ref"`
+		Digest string `json:"digest"`
+		Mirror string `json:"mirror,omitempty"`
+	}
+
+	RelatedTagFound struct {
+		Ref        string `json:"ref"`
+		RelatedRef string `json:"relatedRef"`
+		Digest     string `json:"digest"`
+	}
+
+	BlobCopyProgress struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Complete    int64  `json:"complete"`
+		Total       int64  `json:"total"`
+	}
+
+	PackagePushed struct {
+		Ref    string   `json:"ref"`
+		Digest string   `json:"digest"`
+		Tags   []string `json:"tags,omitempty"`
+	}
+)
+
+func (ManifestLoaded) EventType() Type   { return TypeManifestLoaded }
+func (ImageFound) EventType() Type       { return TypeImageFound }
+func (DigestResolved) EventType() Type   { return TypeDigestResolved }
+func (RelatedTagFound) EventType() Type  { return TypeRelatedTagFound }
+func (BlobCopyProgress) EventType() Type { return TypeBlobCopyProgress }
+func (PackagePushed) EventType() Type    { return TypePackagePushed }
+
+// Emitter passes events to handlers, a nil emitter discards all events,
+// so that components don't need to check whether it was set
+type Emitter struct {
+	lock     sync.Mutex
+	handlers []Handler
+}
+
+func NewEmitter(handlers ...Handler) *Emitter {
+	return &Emitter{handlers: handlers}
+}
+
+func (e *Emitter) AddHandler(handler Handler) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.handlers = append(e.handlers, handler)
+}
+
+// Emit calls each of the handlers in order, it's safe to call concurrently
+// and handlers are never called concurrently
+func (e *Emitter) Emit(data Data) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	event := Event{
+		Version: Version,
+		Type:    data.EventType(),
+		Time:    time.Now().UTC(),
+		Data:    data,
+	}
+	for _, handler := range e.handlers {
+		handler(event)
+	}
+}
+
+// NewJSONHandler writes each event as a line of JSON, errors are passed
+// to onError when it's set
+func NewJSONHandler(w io.Writer, onError func(error)) Handler {
+	encoder := json.NewEncoder(w)
+	return func(event Event) {
+		if err := encoder.Encode(event); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
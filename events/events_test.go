@@ -0,0 +1,54 @@
+package events_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/events"
+)
+
+func TestEmitter(t *testing.T) {
+	g := NewWithT(t)
+
+	var nilEmitter *Emitter
+	g.Expect(func() { nilEmitter.Emit(ManifestLoaded{Path: "a.yaml"}) }).NotTo(Panic())
+
+	buf := &bytes.Buffer{}
+	received := []Type{}
+	emitter := NewEmitter(NewJSONHandler(buf, nil))
+	emitter.AddHandler(func(event Event) { received = append(received, event.Type) })
+
+	emitter.Emit(ManifestLoaded{Path: "a.yaml"})
+	emitter.Emit(DigestResolved{Ref: "nginx:1.25", Digest: "sha256:0000"})
+	emitter.Emit(BlobCopyProgress{Source: "nginx:1.25", Destination: "example.com/app:nginx", Complete: 10, Total: 20})
+
+	g.Expect(received).To(Equal([]Type{TypeManifestLoaded, TypeDigestResolved, TypeBlobCopyProgress}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	g.Expect(lines).To(HaveLen(3))
+
+	decoded := []map[string]interface{}{}
+	for _, line := range lines {
+		event := map[string]interface{}{}
+		g.Expect(json.Unmarshal(line, &event)).To(Succeed())
+		g.Expect(event).To(HaveKeyWithValue("version", Version))
+		g.Expect(event).To(HaveKey("time"))
+		decoded = append(decoded, event)
+	}
+
+	g.Expect(decoded[0]).To(HaveKeyWithValue("type", "ManifestLoaded"))
+	g.Expect(decoded[0]).To(HaveKeyWithValue("data", map[string]interface{}{"path": "a.yaml"}))
+	g.Expect(decoded[1]).To(HaveKeyWithValue("data", map[string]interface{}{
+		"ref":    "nginx:1.25",
+		"digest": "sha256:0000",
+	}))
+	g.Expect(decoded[2]).To(HaveKeyWithValue("data", map[string]interface{}{
+		"source":      "nginx:1.25",
+		"destination": "example.com/app:nginx",
+		"complete":    float64(10),
+		"total":       float64(20),
+	}))
+}
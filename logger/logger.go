@@ -24,3 +24,15 @@ func (l *Logger) SetLevel(level string) error {
 	l.Level = logrusLevel
 	return nil
 }
+
+func (l *Logger) SetFormat(format string) error {
+	switch format {
+	case "text":
+		l.Formatter = &logrus.TextFormatter{}
+	case "json":
+		l.Formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("unsupported log format %q", format)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Reader reads all documents from a stream, unlike kio.ByteReader
+// it doesn't split documents before decoding them, so that line numbers
+// are counted from the beginning of the stream and not of each document
+type Reader struct {
+	reader io.Reader
+}
+
+var _ kio.Reader = &Reader{}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{reader: reader}
+}
+
+// Decode reads all documents from data, empty documents are skipped
+// and items of lists are returned in place of the lists
+func Decode(data []byte) ([]*yaml.RNode, error) {
+	return NewReader(bytes.NewReader(data)).Read()
+}
+
+func (r *Reader) Read() ([]*yaml.RNode, error) {
+	nodes := []*yaml.RNode{}
+	decoder := yaml.NewDecoder(r.reader)
+	for {
+		document := &yaml.Node{}
+		if err := decoder.Decode(document); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nodes, nil
+			}
+			return nil, err
+		}
+		node := yaml.NewRNode(document)
+		if yaml.IsMissingOrNull(node) {
+			continue
+		}
+		nodes = appendItems(nodes, node)
+	}
+}
+
+// appendItems unwraps lists the same way kio.ByteReader does it
+func appendItems(nodes []*yaml.RNode, node *yaml.RNode) []*yaml.RNode {
+	if kind := node.GetKind(); (kind == "List" || kind == kio.ResourceListKind) && node.Field("items") != nil {
+		for _, item := range node.Field("items").Value.Content() {
+			nodes = appendItems(nodes, yaml.NewRNode(item))
+		}
+		return nodes
+	}
+	return append(nodes, node)
+}
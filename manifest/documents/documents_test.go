@@ -0,0 +1,77 @@
+package documents_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/manifest/documents"
+)
+
+func TestDecode(t *testing.T) {
+	type location struct {
+		Kind string
+		Line int
+	}
+
+	testCases := []struct {
+		description string
+		data        string
+		expected    []location
+		expectedErr string
+	}{
+		{
+			description: "empty",
+			data:        "",
+			expected:    []location{},
+		},
+		{
+			description: "empty documents are skipped",
+			data:        "---\n---\nkind: ConfigMap\n---\n# comment\n---\nkind: Secret\n",
+			expected: []location{
+				{Kind: "ConfigMap", Line: 3},
+				{Kind: "Secret", Line: 7},
+			},
+		},
+		{
+			description: "nested lists are unwrapped",
+			data: "kind: List\nitems:\n- kind: ConfigMap\n- kind: List\n  items:\n  - kind: Secret\n" +
+				"---\nkind: ResourceList\nitems:\n- kind: Service\n",
+			expected: []location{
+				{Kind: "ConfigMap", Line: 3},
+				{Kind: "Secret", Line: 6},
+				{Kind: "Service", Line: 10},
+			},
+		},
+		{
+			description: "list without items",
+			data:        "kind: List\n",
+			expected:    []location{{Kind: "List", Line: 1}},
+		},
+		{
+			description: "invalid document",
+			data:        "kind: ConfigMap\n---\nkind: [\n",
+			expectedErr: "line 3: did not find expected node content",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			nodes, err := Decode([]byte(tc.data))
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			locations := []location{}
+			for _, node := range nodes {
+				locations = append(locations, location{Kind: node.GetKind(), Line: node.YNode().Line})
+			}
+			g.Expect(locations).To(Equal(tc.expected))
+		})
+	}
+}
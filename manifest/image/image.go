@@ -19,12 +19,28 @@ type (
 		NewTag  string `json:"newTag,omitempty"`
 
 		Alias *string `json:"alias,omitempty"`
+
+		// MirrorRef is set when digest was resolved via a mirror
+		MirrorRef string `json:"mirrorRef,omitempty"`
+		// CopyMirrorRef is set when image was copied from a mirror
+		CopyMirrorRef string `json:"copyMirrorRef,omitempty"`
 	}
 
 	// ImageSource contains fields that are collected from a manifest and will not mutate
 	Source struct {
 		ImageSourceLocation `json:",inline"`
 		OriginalRef         string `json:"originalRef"`
+
+		// ValueFields is set for references found in Helm values files
+		ValueFields *ValueFields `json:"valueFields,omitempty"`
+	}
+	// ValueFields names fields of a map in Helm values that a reference is split
+	// across, Repository is empty when the reference is a single string value
+	ValueFields struct {
+		Registry   string `json:"registry,omitempty"`
+		Repository string `json:"repository,omitempty"`
+		Tag        string `json:"tag,omitempty"`
+		Digest     string `json:"digest,omitempty"`
 	}
 	// ImageSourceLocation is a unique location identifier for an image
 	ImageSourceLocation struct {
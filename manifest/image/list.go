@@ -112,12 +112,14 @@ func (l *ImageList) Dedup() error {
 				sources = append(sources, existing.Sources...)
 			}
 			unique[k] = Image{
-				Sources:      sources,
-				OriginalTag:  image.OriginalTag,
-				OriginalName: image.OriginalName,
-				Digest:       image.Digest,
-				NewName:      image.NewName,
-				NewTag:       image.NewTag,
+				Sources:       sources,
+				OriginalTag:   image.OriginalTag,
+				OriginalName:  image.OriginalName,
+				Digest:        image.Digest,
+				NewName:       image.NewName,
+				NewTag:        image.NewTag,
+				MirrorRef:     image.MirrorRef,
+				CopyMirrorRef: image.CopyMirrorRef,
 			}
 		}
 
@@ -34,16 +34,26 @@ func NewRegistryCopier(client *oci.Client, destinationRef string) ImageCopier {
 
 func (c *RegistryCopier) CopyImages(ctx context.Context, lists ...*types.ImageList) ([]string, error) {
 	copiedImages := []string{}
+	errs := oci.ReferenceErrors{}
 	for _, images := range lists {
 		SetNewImageRefs(c.DestinationRef, c.hash, images.Items())
-		for _, image := range images.Items() {
+		for i := range images.Items() {
+			image := &images.Items()[i]
 			newRef := image.NewName + ":" + image.NewTag
-			if err := c.Copy(ctx, image.Ref(true), newRef, image.Digest); err != nil {
-				return nil, err
+			source, err := c.CopyFrom(ctx, image.Ref(true), newRef, image.Digest)
+			if err != nil {
+				errs.Append(image.Ref(true), err)
+				continue
+			}
+			if source != image.Ref(true) {
+				image.CopyMirrorRef = source
 			}
 			copiedImages = append(copiedImages, newRef+"@"+image.Digest)
 		}
 	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
 	return copiedImages, nil
 }
 
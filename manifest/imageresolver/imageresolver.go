@@ -6,6 +6,7 @@ import (
 
 	kimage "sigs.k8s.io/kustomize/api/image"
 
+	"github.com/errordeveloper/tape/events"
 	"github.com/errordeveloper/tape/manifest/types"
 	"github.com/errordeveloper/tape/oci"
 )
@@ -33,25 +34,45 @@ func NewRegistryResolver(client *oci.Client) Resolver {
 	}
 }
 
+// ResolveDigests attempts to resolve all of the images, errors are collected
+// and returned together, so every failed reference is reported once; the same
+// reference may be found many times, it's not attempted again once it failed
 func (r *RegistryResolver) ResolveDigests(ctx context.Context, images *types.ImageList) error {
+	errs := oci.ReferenceErrors{}
+	failed := map[string]struct{}{}
 	for i := range images.Items() {
-		if err := r.doResolveDigest(ctx, &images.Items()[i]); err != nil {
-			return err
+		image := &images.Items()[i]
+		ref := image.Ref(true)
+		if _, ok := failed[ref]; ok {
+			continue
+		}
+		if err := r.doResolveDigest(ctx, image); err != nil {
+			failed[ref] = struct{}{}
+			errs.Append(ref, err)
 		}
 	}
-	return nil
+	return errs.ErrorOrNil()
 }
 
 func (r *RegistryResolver) doResolveDigest(ctx context.Context, i *types.Image) error {
-	digest, err := r.Digest(ctx, i.Ref(true))
+	ref := i.Ref(true)
+	digest, source, err := r.DigestFrom(ctx, ref)
 	if err != nil {
 		return err
 	}
+	if source != ref {
+		i.MirrorRef = source
+	}
 	if i.Digest != "" && i.Digest != digest {
 		// this is unexpected as when digest is being specified, that is exactly what will be retrieved
 		return fmt.Errorf("unexpected digest mismatch: %s (from manifest) != %s (form registry)", i.Digest, digest)
 	}
 	i.Digest = digest
+	r.Events().Emit(events.DigestResolved{
+		Ref:    ref,
+		Digest: digest,
+		Mirror: i.MirrorRef,
+	})
 	return nil
 }
 
@@ -59,6 +80,7 @@ func (c *RegistryResolver) FindRelatedTags(ctx context.Context, images *types.Im
 	// TODO: reduce redudant calls to registry, e.g. when multiple images have the same name;
 	// `images.Dedup()` doens't address this as it requires registry call, so proper registry cache is needed
 	result := types.NewImageList(images.Dir())
+	errs := oci.ReferenceErrors{}
 	for i := range images.Items() {
 		image := images.Items()[i]
 		if image.Digest == "" {
@@ -66,13 +88,19 @@ func (c *RegistryResolver) FindRelatedTags(ctx context.Context, images *types.Im
 		}
 		related, err := c.ListRelated(ctx, image.OriginalName, image.Digest)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list related tag for %s: %w", image.Ref(true), err)
+			errs.Append(image.Ref(true), fmt.Errorf("failed to list related tags: %w", err))
+			continue
 		}
 		for i := range related {
 			relatedImage := &related[i]
 			if relatedImage.Digest == "" {
 				return nil, fmt.Errorf("related image %s has no digest", relatedImage.URL)
 			}
+			c.Events().Emit(events.RelatedTagFound{
+				Ref:        image.Ref(true),
+				RelatedRef: relatedImage.URL,
+				Digest:     relatedImage.Digest,
+			})
 			name, tag, _ := kimage.Split(relatedImage.URL)
 			err := result.AppendWithRelationTo(image, types.Image{
 				Sources: []types.Source{{
@@ -88,6 +116,9 @@ func (c *RegistryResolver) FindRelatedTags(ctx context.Context, images *types.Im
 
 		}
 	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
 	if err := result.Dedup(); err != nil {
 		return nil, err
 	}
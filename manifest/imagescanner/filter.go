@@ -70,6 +70,7 @@ type SetValueArg struct {
 	Key, Value, Tag string
 	Line, Column    int
 	NodePath        []string
+	ValueFields     *types.ValueFields
 }
 
 type Tracker struct {
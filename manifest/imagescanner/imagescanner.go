@@ -13,6 +13,8 @@ import (
 
 	"github.com/errordeveloper/tape/attest"
 	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/events"
+	"github.com/errordeveloper/tape/manifest/documents"
 	"github.com/errordeveloper/tape/manifest/types"
 )
 
@@ -21,6 +23,8 @@ type ImageScanner interface {
 	GetImages() *types.ImageList
 	Reset()
 	WithProvinanceAttestor(*attest.PathCheckerRegistry)
+	WithEvents(*events.Emitter)
+	WithHelmValues(...ValuesImagePath)
 }
 
 type DefaultImageScanner struct {
@@ -28,6 +32,9 @@ type DefaultImageScanner struct {
 	trackers  []*Tracker
 	hash      hash.Hash
 	attestor  *attest.PathCheckerRegistry
+	events    *events.Emitter
+
+	valuesImagePaths []ValuesImagePath
 }
 
 func NewDefaultImageScanner() ImageScanner {
@@ -52,24 +59,35 @@ func (s *DefaultImageScanner) Scan(dir string, manifests []string) error {
 			Manifest: manifests[m],
 		}
 
-		filter.WithMutationTracker(tracker.MutationTracker)
+		if len(s.valuesImagePaths) != 0 && IsHelmValuesFile(manifests[m]) {
+			if err := s.scanValues(dir, tracker, io.TeeReader(manifest, s.hash)); err != nil {
+				return err
+			}
+		} else {
+			filter.WithMutationTracker(tracker.MutationTracker)
 
-		pipeline := kio.Pipeline{
-			Inputs: []kio.Reader{
-				&kio.ByteReader{
-					Reader:                io.TeeReader(manifest, s.hash),
-					OmitReaderAnnotations: true,
+			pipeline := kio.Pipeline{
+				Inputs: []kio.Reader{
+					documents.NewReader(io.TeeReader(manifest, s.hash)),
 				},
-			},
-			Filters: []kio.Filter{filter},
-		}
+				Filters: []kio.Filter{filter},
+			}
 
-		if err := pipeline.Execute(); err != nil {
-			return err
+			if err := pipeline.Execute(); err != nil {
+				return err
+			}
 		}
 
 		tracker.ManifestDigest = digest.MakeSHA256(s.hash)
 		s.trackers = append(s.trackers, tracker)
+		for _, v := range tracker.SetValueArgs() {
+			s.events.Emit(events.ImageFound{
+				Ref:      v.Value,
+				Manifest: tracker.Manifest,
+				Line:     v.Line,
+				Column:   v.Column,
+			})
+		}
 		if s.attestor != nil {
 			if err := s.attestor.Register(tracker.Manifest, tracker.ManifestDigest); err != nil {
 				return err
@@ -82,10 +100,36 @@ func (s *DefaultImageScanner) Scan(dir string, manifests []string) error {
 	return nil
 }
 
+func (s *DefaultImageScanner) scanValues(dir string, tracker *Tracker, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	appVersion, err := chartAppVersion(filepath.Join(dir, filepath.Dir(tracker.Manifest)))
+	if err != nil {
+		return err
+	}
+	scanner := &valuesScanner{
+		paths:      s.valuesImagePaths,
+		tracker:    tracker,
+		appVersion: appVersion,
+	}
+	return scanner.scan(data)
+}
+
 func (s *DefaultImageScanner) WithProvinanceAttestor(pcr *attest.PathCheckerRegistry) {
 	s.attestor = pcr
 }
 
+func (s *DefaultImageScanner) WithEvents(emitter *events.Emitter) {
+	s.events = emitter
+}
+
+// WithHelmValues enables scanning of Helm values files for images at given paths
+func (s *DefaultImageScanner) WithHelmValues(paths ...ValuesImagePath) {
+	s.valuesImagePaths = paths
+}
+
 func (s *DefaultImageScanner) GetImages() *types.ImageList {
 	images := types.NewImageList(s.directory)
 	for _, v := range s.trackers {
@@ -101,6 +145,7 @@ func (s *DefaultImageScanner) GetImages() *types.ImageList {
 						Column:         vv.Column,
 					},
 					OriginalRef: vv.Value,
+					ValueFields: vv.ValueFields,
 				}},
 				OriginalName: name,
 				OriginalTag:  tag,
@@ -114,4 +159,5 @@ func (s *DefaultImageScanner) GetImages() *types.ImageList {
 func (s *DefaultImageScanner) Reset() {
 	s.trackers = []*Tracker{}
 	s.attestor = nil
+	s.events = nil
 }
@@ -0,0 +1,273 @@
+package imagescanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/errordeveloper/tape/manifest/types"
+)
+
+const (
+	valuesPathSeparator = "."
+	valuesPathAnyKey    = "*"
+	valuesPathAnyDepth  = "**"
+	valuesPathSequence  = "[]"
+)
+
+// ValuesImagePath describes where images are referenced in Helm values, path is
+// a dot-separated list of keys where `*` matches any key, `**` matches any number
+// of keys and `[]` suffix matches each element of a list; a value found at path
+// is either a string with full reference or a map with the reference split into
+// registry, repository, tag and digest fields
+type ValuesImagePath struct {
+	Path       string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func DefaultValuesImagePaths() []ValuesImagePath {
+	return []ValuesImagePath{NewValuesImagePath("**.image")}
+}
+
+func NewValuesImagePath(path string) ValuesImagePath {
+	return ValuesImagePath{
+		Path:       path,
+		Registry:   "registry",
+		Repository: "repository",
+		Tag:        "tag",
+		Digest:     "digest",
+	}
+}
+
+// ParseValuesImagePath parses path with optional field names, e.g.
+// `controller.image:repository=repo,tag=version`
+func ParseValuesImagePath(s string) (ValuesImagePath, error) {
+	path, fields, hasFields := strings.Cut(s, ":")
+	if path == "" {
+		return ValuesImagePath{}, fmt.Errorf("empty path in values image path %q", s)
+	}
+	p := NewValuesImagePath(path)
+	if !hasFields {
+		return p, nil
+	}
+	for _, field := range strings.Split(fields, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return ValuesImagePath{}, fmt.Errorf("invalid field %q in values image path %q", field, s)
+		}
+		switch k {
+		case "registry":
+			p.Registry = v
+		case "repository":
+			p.Repository = v
+		case "tag":
+			p.Tag = v
+		case "digest":
+			p.Digest = v
+		default:
+			return ValuesImagePath{}, fmt.Errorf("unknown field %q in values image path %q", k, s)
+		}
+	}
+	return p, nil
+}
+
+func IsHelmValuesFile(path string) bool {
+	switch filepath.Base(path) {
+	case "values.yaml", "values.yml":
+		return true
+	}
+	return false
+}
+
+type valuesScanner struct {
+	paths   []ValuesImagePath
+	tracker *Tracker
+	// appVersion is used when tag is omitted, as most charts default to it
+	appVersion string
+}
+
+func (s *valuesScanner) scan(data []byte) error {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	values, err := yaml.Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse values in %q: %w", s.tracker.Manifest, err)
+	}
+	for i := range s.paths {
+		path := s.paths[i]
+		visit := func(node *yaml.RNode, nodePath []string) error {
+			return s.visit(path, node, nodePath)
+		}
+		if err := walkValues(values, strings.Split(path.Path, valuesPathSeparator), nil, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *valuesScanner) visit(path ValuesImagePath, node *yaml.RNode, nodePath []string) error {
+	for _, arg := range s.tracker.setValueArgs {
+		if slices.Equal(arg.NodePath, nodePath) {
+			// matched by another path already
+			return nil
+		}
+	}
+
+	switch node.YNode().Kind {
+	case yaml.ScalarNode:
+		if node.YNode().Tag != yaml.NodeTagString || !isImageRef(node.YNode().Value) {
+			return nil
+		}
+		s.tracker.setValueArgs = append(s.tracker.setValueArgs, SetValueArg{
+			Value:       node.YNode().Value,
+			Tag:         node.YNode().Tag,
+			Line:        node.YNode().Line,
+			Column:      node.YNode().Column,
+			NodePath:    nodePath,
+			ValueFields: &types.ValueFields{},
+		})
+	case yaml.MappingNode:
+		repository := node.Field(path.Repository)
+		if repository == nil || repository.Value.YNode().Kind != yaml.ScalarNode || repository.Value.YNode().Value == "" {
+			return nil
+		}
+		fields := &types.ValueFields{
+			Repository: path.Repository,
+			Tag:        path.Tag,
+		}
+		// registry and digest fields are updated when present, even if empty
+		if node.Field(path.Registry) != nil {
+			fields.Registry = path.Registry
+		}
+		if node.Field(path.Digest) != nil {
+			fields.Digest = path.Digest
+		}
+		ref := repository.Value.YNode().Value
+		if registry := scalarField(node, path.Registry); registry != "" {
+			ref = registry + "/" + ref
+		}
+		tag := scalarField(node, path.Tag)
+		digest := scalarField(node, path.Digest)
+		if tag == "" && digest == "" {
+			tag = s.appVersion
+		}
+		if tag != "" {
+			ref += ":" + tag
+		}
+		if digest != "" {
+			ref += "@" + digest
+		}
+		if !isImageRef(ref) {
+			return fmt.Errorf("invalid image reference %q in %q at %s", ref, s.tracker.Manifest, strings.Join(nodePath, valuesPathSeparator))
+		}
+		s.tracker.setValueArgs = append(s.tracker.setValueArgs, SetValueArg{
+			Value:       ref,
+			Tag:         yaml.NodeTagString,
+			Line:        repository.Value.YNode().Line,
+			Column:      repository.Value.YNode().Column,
+			NodePath:    nodePath,
+			ValueFields: fields,
+		})
+	}
+	return nil
+}
+
+func walkValues(node *yaml.RNode, path, nodePath []string, visit func(*yaml.RNode, []string) error) error {
+	if len(path) == 0 {
+		return visit(node, nodePath)
+	}
+	key := path[0]
+	switch {
+	case key == valuesPathAnyDepth:
+		if err := walkValues(node, path[1:], nodePath, visit); err != nil {
+			return err
+		}
+		return walkChildren(node, nodePath, func(child *yaml.RNode, childPath []string) error {
+			return walkValues(child, path, childPath, visit)
+		})
+	case key == valuesPathAnyKey:
+		if node.YNode().Kind != yaml.MappingNode {
+			return nil
+		}
+		return walkChildren(node, nodePath, func(child *yaml.RNode, childPath []string) error {
+			return walkValues(child, path[1:], childPath, visit)
+		})
+	case strings.HasSuffix(key, valuesPathSequence):
+		field := node.Field(strings.TrimSuffix(key, valuesPathSequence))
+		if field == nil || field.Value.YNode().Kind != yaml.SequenceNode {
+			return nil
+		}
+		return walkChildren(field.Value, append(slices.Clone(nodePath), field.Key.YNode().Value), func(child *yaml.RNode, childPath []string) error {
+			return walkValues(child, path[1:], childPath, visit)
+		})
+	default:
+		if node.YNode().Kind != yaml.MappingNode {
+			return nil
+		}
+		field := node.Field(key)
+		if field == nil {
+			return nil
+		}
+		return walkValues(field.Value, path[1:], append(slices.Clone(nodePath), key), visit)
+	}
+}
+
+// walkChildren calls fn for values of a map or elements of a list, elements
+// of a list are addressed as `[<index>]`
+func walkChildren(node *yaml.RNode, nodePath []string, fn func(*yaml.RNode, []string) error) error {
+	switch node.YNode().Kind {
+	case yaml.MappingNode:
+		return node.VisitFields(func(field *yaml.MapNode) error {
+			return fn(field.Value, append(slices.Clone(nodePath), field.Key.YNode().Value))
+		})
+	case yaml.SequenceNode:
+		elements, err := node.Elements()
+		if err != nil {
+			return err
+		}
+		for i := range elements {
+			if err := fn(elements[i], append(slices.Clone(nodePath), "["+strconv.Itoa(i)+"]")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scalarField(node *yaml.RNode, key string) string {
+	field := node.Field(key)
+	if field == nil || field.Value.YNode().Kind != yaml.ScalarNode || field.Value.YNode().Tag == yaml.NodeTagNull {
+		return ""
+	}
+	return field.Value.YNode().Value
+}
+
+func isImageRef(s string) bool {
+	_, err := name.ParseReference(s)
+	return err == nil
+}
+
+func chartAppVersion(chartDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	chart, err := yaml.Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("unable to parse chart metadata in %q: %w", chartDir, err)
+	}
+	return scalarField(chart, "appVersion"), nil
+}
@@ -0,0 +1,76 @@
+package loader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// TapeIgnoreFile lists paths to exclude using gitignore syntax,
+// it's only read from the top of the directory being loaded
+const TapeIgnoreFile = ".tapeignore"
+
+type pathFilter struct {
+	ignore  gitignore.Matcher
+	include gitignore.Matcher
+}
+
+func newPathFilter(dir string, include, exclude []string) (*pathFilter, error) {
+	patterns, err := readIgnoreFile(filepath.Join(dir, TapeIgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	for i := range exclude {
+		patterns = append(patterns, gitignore.ParsePattern(exclude[i], nil))
+	}
+	filter := &pathFilter{
+		ignore: gitignore.NewMatcher(patterns),
+	}
+	if len(include) != 0 {
+		patterns := make([]gitignore.Pattern, len(include))
+		for i := range include {
+			patterns[i] = gitignore.ParsePattern(include[i], nil)
+		}
+		filter.include = gitignore.NewMatcher(patterns)
+	}
+	return filter, nil
+}
+
+func readIgnoreFile(path string) ([]gitignore.Pattern, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []gitignore.Pattern{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %q: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// excludes checks if relPath is ignored, or if it's a file that isn't
+// matched by any of include globs when these are set
+func (f *pathFilter) excludes(relPath string, isDir bool) bool {
+	path := strings.Split(filepath.ToSlash(relPath), "/")
+	if f.ignore.Match(path, isDir) {
+		return true
+	}
+	return !isDir && f.include != nil && !f.include.Match(path, false)
+}
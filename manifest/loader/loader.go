@@ -19,85 +19,259 @@ type Loader interface {
 	ContainsRelPath(string) bool
 	Cleanup() error
 	MostRecentlyModified() (string, time.Time)
+	WithFilters(include, exclude []string)
+	Excluded() []string
+	Roots() []Root
 }
 
+// Root is a directory or a file that content is loaded from, Prefix is
+// where it's placed in the content directory
+type Root struct {
+	Path   string
+	Prefix string
+	IsDir  bool
+}
+
+// ContentKind determines which files are loaded and how the
+// content is meant to be interpreted once it's packaged
+type ContentKind string
+
+const (
+	// ContentKindManifests is a directory of plain manifests, only
+	// JSON and YAML files are loaded
+	ContentKindManifests ContentKind = "manifests"
+	// ContentKindHelmChart is an unrendered Helm chart, all files are loaded,
+	// but templates are not returned by RelPaths as these are not valid YAML
+	ContentKindHelmChart ContentKind = "helm-chart"
+	// ContentKindKustomize is an unrendered kustomize base, all files are loaded
+	ContentKindKustomize ContentKind = "kustomize"
+)
+
+const helmChartTemplatesDir = "templates"
+
 type RecursiveManifestDirectoryLoader struct {
-	fromPath string
-	tempDir  string
-	files    []fileWithModTime
-	relPaths map[string]string
+	fromPaths []string
+	roots     []Root
+	kind      ContentKind
+	tempDir   string
+	files     []fileWithModTime
+	relPaths  map[string]string
+
+	include, exclude []string
+	excluded         []string
 }
 
 func NewRecursiveManifestDirectoryLoader(path string) Loader {
-	return &RecursiveManifestDirectoryLoader{fromPath: path}
+	return &RecursiveManifestDirectoryLoader{fromPaths: []string{path}, kind: ContentKindManifests}
+}
+
+// NewRecursiveDirectoryLoader is like NewRecursiveManifestDirectoryLoader,
+// but it loads files according to the kind of content
+func NewRecursiveDirectoryLoader(path string, kind ContentKind) Loader {
+	return &RecursiveManifestDirectoryLoader{fromPaths: []string{path}, kind: kind}
+}
+
+// NewMultiPathLoader loads manifests from multiple directories and individual
+// files, see MakeRoots for how these are laid out in the content directory
+func NewMultiPathLoader(paths ...string) Loader {
+	return &RecursiveManifestDirectoryLoader{fromPaths: paths, kind: ContentKindManifests}
+}
+
+// MakeRoots determines where each of the paths is placed in the content
+// directory; when there is only one directory, its contents are placed at
+// the top, otherwise each directory and file is placed under its base name,
+// which must be unique
+func MakeRoots(paths ...string) ([]Root, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths given")
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]Root, len(paths))
+	prefixes := make(map[string]string, len(paths))
+	for i, path := range paths {
+		if filepath.IsAbs(path) {
+			relPath, err := filepath.Rel(workDir, path)
+			if err != nil {
+				return nil, err
+			}
+			path = relPath
+		}
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("the path %q does not exist: %w", path, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the path %q cannot be accessed: %v", path, err)
+		}
+		roots[i] = Root{Path: filepath.Clean(path), IsDir: fi.IsDir()}
+		if len(paths) == 1 && roots[i].IsDir {
+			break
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		roots[i].Prefix = filepath.Base(absPath)
+		if other, ok := prefixes[roots[i].Prefix]; ok {
+			return nil, fmt.Errorf("paths %q and %q would both be placed at %q", other, path, roots[i].Prefix)
+		}
+		prefixes[roots[i].Prefix] = path
+	}
+	return roots, nil
+}
+
+// DetectContentKind checks if path is a Helm chart or a kustomize base,
+// otherwise it's assumed to contain plain manifests
+func DetectContentKind(path string) (ContentKind, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to detect content kind: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	switch {
+	case slices.Contains(names, "Chart.yaml"):
+		return ContentKindHelmChart, nil
+	case useKustomize(names...):
+		return ContentKindKustomize, nil
+	default:
+		return ContentKindManifests, nil
+	}
+}
+
+// FindKustomization returns path of kustomization file in dir
+func FindKustomization(dir string) (string, error) {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no kustomization file found in %q", dir)
 }
 
 func (l *RecursiveManifestDirectoryLoader) Load() error {
+	roots, err := MakeRoots(l.fromPaths...)
+	if err != nil {
+		return err
+	}
+	if len(roots) > 1 && l.kind != ContentKindManifests {
+		return fmt.Errorf("content of kind %s must be loaded from a single directory", l.kind)
+	}
+	l.roots = roots
+
 	tempDir, err := mkdirTemp()
 	if err != nil {
 		return err
 	}
 	l.tempDir = tempDir
 
-	if filepath.IsAbs(l.fromPath) {
-		relPath, err := filepath.Rel("", l.fromPath)
+	l.relPaths = map[string]string{}
+	l.excluded = []string{}
+	for _, root := range l.roots {
+		if root.IsDir {
+			err = l.loadDir(root)
+		} else {
+			err = l.loadFile(root)
+		}
 		if err != nil {
 			return err
 		}
-		l.fromPath = relPath
 	}
 
-	files, err := getFiles(l.fromPath)
+	files, err := getFiles(l.tempDir)
 	if err != nil {
 		return err
 	}
-	l.relPaths = make(map[string]string, len(files))
+	l.files = files
+
+	return nil
+}
+
+func (l *RecursiveManifestDirectoryLoader) loadDir(root Root) error {
+	filter, err := newPathFilter(root.Path, l.include, l.exclude)
+	if err != nil {
+		return err
+	}
+
+	files, err := getFiles(root.Path)
+	if err != nil {
+		return err
+	}
+	numFiles := len(l.relPaths)
 	for _, f := range files {
-		relPath, err := filepath.Rel(l.fromPath, f.path)
+		relPath, err := filepath.Rel(root.Path, f.path)
 		if err != nil {
 			return err
 		}
-		l.relPaths[relPath] = f.path
+		if filter.excludes(relPath, false) {
+			continue
+		}
+		if l.kind == ContentKindHelmChart && isHelmTemplate(relPath) {
+			continue
+		}
+		l.relPaths[filepath.Join(root.Prefix, relPath)] = f.path
+	}
+	if len(l.relPaths) == numFiles {
+		return fmt.Errorf("no files left in %q after applying exclusions", root.Path)
 	}
-
-	// if useKustomize(l.fromPath) || useKustomize(files...) {
-	// 	// it's possible that kustomization references files out of the tempDir
-	// 	// this will do `kustomize build` on l.fromPath and write the result into l.tempDir
-	// 	// TODO: this assumes all files are to be handled by kustomize,
-	// 	// it would be sensible to enable using different handlers for different subdirs
-	// 	return fmt.Errorf("TODO: implement kustomize build")
-	// }
 
 	copyOptions := copy.Options{
-		// TODO: documentation for PreserveTimes says there is limited accuracy on Linux,
-		// namely it's only to up to 1ms, it's possible that it need a fix, e.g. by rouding
-		// stored timestamps to milliseconds on all platforms;
-		// the answer really depends on how accurately mtime can be preserved between platforms,
-		// it makes sense to consider what git, tar and rsync do in that regard
+		// documentation for PreserveTimes says there is limited accuracy on Linux, namely it's
+		// only to up to 1ms, and some filesystems and archive formats are only accurate to one
+		// second, so all timestamps are truncated to seconds (see getFiles)
 		PreserveTimes: true,
 		Skip: func(fi fs.FileInfo, src, _ string) (bool, error) {
+			relPath, err := filepath.Rel(root.Path, src)
+			if err != nil {
+				return false, err
+			}
 			if fi.IsDir() {
+				if fi.Name() == ".git" {
+					return true, nil
+				}
+				if relPath != "." && filter.excludes(relPath, true) {
+					l.excluded = append(l.excluded, filepath.ToSlash(filepath.Join(root.Prefix, relPath))+"/")
+					return true, nil
+				}
 				return false, nil
 			}
-			return ignoreFile(src), nil
+			// charts and kustomize bases may contain any files, e.g.
+			// helpers, notes or sources of generated config maps
+			if l.kind == ContentKindManifests && ignoreFile(src) {
+				return true, nil
+			}
+			if filter.excludes(relPath, false) {
+				l.excluded = append(l.excluded, filepath.ToSlash(filepath.Join(root.Prefix, relPath)))
+				return true, nil
+			}
+			return false, nil
 		},
 	}
 
-	if err := copy.Copy(l.fromPath, l.tempDir, copyOptions); err != nil {
-		return err
-	}
+	return copy.Copy(root.Path, filepath.Join(l.tempDir, root.Prefix), copyOptions)
+}
 
-	files, err = getFiles(l.tempDir)
-	if err != nil {
-		return err
+// loadFile loads a file that was given explicitly, so filters don't apply to it
+func (l *RecursiveManifestDirectoryLoader) loadFile(root Root) error {
+	if ignoreFile(root.Path) {
+		return fmt.Errorf("the file %q is not JSON or YAML", root.Path)
 	}
-	l.files = files
-
-	return nil
+	l.relPaths[root.Prefix] = root.Path
+	return copy.Copy(root.Path, filepath.Join(l.tempDir, root.Prefix), copy.Options{PreserveTimes: true})
 }
 
 func (l *RecursiveManifestDirectoryLoader) MostRecentlyModified() (string, time.Time) {
-	return l.files[0].path, l.files[0].time
+	// files are sorted in ascending order
+	last := l.files[len(l.files)-1]
+	return last.path, last.time
 }
 
 func (l *RecursiveManifestDirectoryLoader) Paths() []string {
@@ -121,6 +295,25 @@ func (l *RecursiveManifestDirectoryLoader) ContainsRelPath(p string) bool {
 	return ok
 }
 
+// WithFilters sets globs of paths to load, as well as paths to exclude in
+// addition to those listed in .tapeignore, all using gitignore syntax
+func (l *RecursiveManifestDirectoryLoader) WithFilters(include, exclude []string) {
+	l.include = include
+	l.exclude = exclude
+}
+
+// Roots returns directories and files that content was loaded from
+func (l *RecursiveManifestDirectoryLoader) Roots() []Root {
+	return l.roots
+}
+
+// Excluded returns paths that were excluded by .tapeignore or filters,
+// directories are denoted by a trailing slash
+func (l *RecursiveManifestDirectoryLoader) Excluded() []string {
+	slices.Sort(l.excluded)
+	return l.excluded
+}
+
 func (l *RecursiveManifestDirectoryLoader) Cleanup() error {
 	if l.tempDir == "" {
 		return nil
@@ -159,7 +352,7 @@ func getFiles(path string) ([]fileWithModTime, error) {
 	}
 
 	if !fi.IsDir() {
-		files = append(files, fileWithModTime{path: path, time: fi.ModTime()})
+		files = append(files, fileWithModTime{path: path, time: modTime(fi)})
 		return files, nil
 	}
 
@@ -176,7 +369,7 @@ func getFiles(path string) ([]fileWithModTime, error) {
 			return err
 		}
 
-		files = append(files, fileWithModTime{path: p, time: info.ModTime()})
+		files = append(files, fileWithModTime{path: p, time: modTime(info)})
 		return nil
 	}
 
@@ -195,6 +388,12 @@ func getFiles(path string) ([]fileWithModTime, error) {
 	return files, nil
 }
 
+// modTime returns mtime truncated to seconds, which is the precision that can be
+// preserved across most filesystems, archive formats and tools like git or rsync
+func modTime(fi fs.FileInfo) time.Time {
+	return fi.ModTime().Truncate(time.Second).UTC()
+}
+
 func ignoreFile(path string) bool {
 	switch filepath.Ext(path) {
 	case ".json", ".yaml", ".yml":
@@ -204,6 +403,10 @@ func ignoreFile(path string) bool {
 	}
 }
 
+func isHelmTemplate(relPath string) bool {
+	return strings.HasPrefix(filepath.ToSlash(relPath), helmChartTemplatesDir+"/")
+}
+
 func useKustomize(paths ...string) bool {
 	for i := range paths {
 		switch filepath.Base(paths[i]) {
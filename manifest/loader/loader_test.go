@@ -1,8 +1,10 @@
 package loader_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
@@ -51,3 +53,291 @@ func makeLoaderTest(tc testdata.TestCase) func(t *testing.T) {
 		g.Expect(mrmTimestamp1).To(Equal(mrmTimestamp2))
 	}
 }
+
+func TestMostRecentlyModified(t *testing.T) {
+	g := NewWithT(t)
+
+	tempDir := t.TempDir()
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"b.yaml", "c.yaml", "a.yaml"} {
+		path := filepath.Join(tempDir, name)
+		g.Expect(os.WriteFile(path, []byte("kind: Test\n"), 0o644)).To(Succeed())
+		// sub-second precision should be discarded
+		mtime := timestamp.Add(time.Duration(i)*time.Hour + 123*time.Millisecond)
+		g.Expect(os.Chtimes(path, mtime, mtime)).To(Succeed())
+	}
+
+	workDir, err := os.Getwd()
+	g.Expect(err).NotTo(HaveOccurred())
+	loadPath, err := filepath.Rel(workDir, tempDir)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	loader := NewRecursiveManifestDirectoryLoader(loadPath)
+	g.Expect(loader.Load()).To(Succeed())
+	defer loader.Cleanup()
+
+	path, mtime := loader.MostRecentlyModified()
+	g.Expect(filepath.Base(path)).To(Equal("a.yaml"))
+	g.Expect(mtime).To(Equal(timestamp.Add(2 * time.Hour)))
+}
+
+func TestContentKinds(t *testing.T) {
+	testCases := []struct {
+		description      string
+		files            map[string]string
+		expectedKind     ContentKind
+		expectedRelPaths []string
+		expectedCopied   []string
+	}{
+		{
+			description: "plain manifests",
+			files: map[string]string{
+				"deployment.yaml": "kind: Deployment\n",
+				"README.md":       "# app\n",
+			},
+			expectedKind:     ContentKindManifests,
+			expectedRelPaths: []string{"deployment.yaml"},
+			expectedCopied:   []string{"deployment.yaml"},
+		},
+		{
+			description: "helm chart",
+			files: map[string]string{
+				"Chart.yaml":                "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+				"values.yaml":               "image:\n  repository: nginx\n",
+				"templates/deployment.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Release.Name }}\n",
+				"templates/_helpers.tpl":    "{{- define \"app.name\" -}}app{{- end }}\n",
+				"templates/NOTES.txt":       "installed\n",
+			},
+			expectedKind:     ContentKindHelmChart,
+			expectedRelPaths: []string{"Chart.yaml", "values.yaml"},
+			expectedCopied: []string{
+				"Chart.yaml", "values.yaml",
+				"templates/deployment.yaml", "templates/_helpers.tpl", "templates/NOTES.txt",
+			},
+		},
+		{
+			description: "kustomize base",
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n- deployment.yaml\nconfigMapGenerator:\n- name: app\n  envs: [config.env]\n",
+				"deployment.yaml":    "kind: Deployment\n",
+				"config.env":         "KEY=value\n",
+			},
+			expectedKind:     ContentKindKustomize,
+			expectedRelPaths: []string{"kustomization.yaml", "deployment.yaml"},
+			expectedCopied:   []string{"kustomization.yaml", "deployment.yaml", "config.env"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tempDir := t.TempDir()
+			for name, contents := range tc.files {
+				path := filepath.Join(tempDir, name)
+				g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+				g.Expect(os.WriteFile(path, []byte(contents), 0o644)).To(Succeed())
+			}
+
+			workDir, err := os.Getwd()
+			g.Expect(err).NotTo(HaveOccurred())
+			loadPath, err := filepath.Rel(workDir, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			kind, err := DetectContentKind(loadPath)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(kind).To(Equal(tc.expectedKind))
+
+			loader := NewRecursiveDirectoryLoader(loadPath, kind)
+			g.Expect(loader.Load()).To(Succeed())
+			defer loader.Cleanup()
+
+			dir, relPaths := loader.RelPaths()
+			g.Expect(relPaths).To(ConsistOf(tc.expectedRelPaths))
+
+			copied := []string{}
+			g.Expect(filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+				if err != nil || e.IsDir() {
+					return err
+				}
+				relPath, err := filepath.Rel(dir, path)
+				copied = append(copied, relPath)
+				return err
+			})).To(Succeed())
+			g.Expect(copied).To(ConsistOf(tc.expectedCopied))
+		})
+	}
+}
+
+func TestFilters(t *testing.T) {
+	files := map[string]string{
+		".tapeignore":                  "# not manifests\nci/\n*.test.yaml\n",
+		"deployment.yaml":              "kind: Deployment\n",
+		"service.yaml":                 "kind: Service\n",
+		"deployment.test.yaml":         "kind: Deployment\n",
+		"ci/pipeline.yaml":             "steps: []\n",
+		"overlays/dev/deployment.yaml": "kind: Deployment\n",
+	}
+
+	testCases := []struct {
+		description      string
+		include, exclude []string
+		expectedRelPaths []string
+		expectedExcluded []string
+	}{
+		{
+			description:      "tapeignore",
+			expectedRelPaths: []string{"deployment.yaml", "service.yaml", "overlays/dev/deployment.yaml"},
+			expectedExcluded: []string{"ci/", "deployment.test.yaml"},
+		},
+		{
+			description:      "exclude",
+			exclude:          []string{"overlays/", "service.yaml"},
+			expectedRelPaths: []string{"deployment.yaml"},
+			expectedExcluded: []string{"ci/", "deployment.test.yaml", "overlays/", "service.yaml"},
+		},
+		{
+			description:      "include",
+			include:          []string{"/deployment.yaml", "overlays/**/*.yaml"},
+			expectedRelPaths: []string{"deployment.yaml", "overlays/dev/deployment.yaml"},
+			expectedExcluded: []string{"ci/", "deployment.test.yaml", "service.yaml"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tempDir := t.TempDir()
+			for name, contents := range files {
+				path := filepath.Join(tempDir, name)
+				g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+				g.Expect(os.WriteFile(path, []byte(contents), 0o644)).To(Succeed())
+			}
+
+			workDir, err := os.Getwd()
+			g.Expect(err).NotTo(HaveOccurred())
+			loadPath, err := filepath.Rel(workDir, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			loader := NewRecursiveManifestDirectoryLoader(loadPath)
+			loader.WithFilters(tc.include, tc.exclude)
+			g.Expect(loader.Load()).To(Succeed())
+			defer loader.Cleanup()
+
+			dir, relPaths := loader.RelPaths()
+			g.Expect(relPaths).To(ConsistOf(tc.expectedRelPaths))
+			g.Expect(loader.Excluded()).To(Equal(tc.expectedExcluded))
+			for _, excluded := range tc.expectedExcluded {
+				g.Expect(filepath.Join(dir, excluded)).NotTo(BeAnExistingFile())
+			}
+		})
+	}
+}
+
+func TestMultiPath(t *testing.T) {
+	files := map[string]string{
+		"crds/widget.yaml":               "kind: CustomResourceDefinition\n",
+		"workloads/.tapeignore":          "*.test.yaml\n",
+		"workloads/deployment.yaml":      "kind: Deployment\n",
+		"workloads/deployment.test.yaml": "kind: Deployment\n",
+		"workloads/app/service.yaml":     "kind: Service\n",
+		"namespace.yaml":                 "kind: Namespace\n",
+		"other/crds/gadget.yaml":         "kind: CustomResourceDefinition\n",
+		"notes.txt":                      "notes\n",
+	}
+
+	testCases := []struct {
+		description      string
+		paths            []string
+		expectedRoots    []Root
+		expectedRelPaths []string
+		expectedExcluded []string
+		expectedErr      string
+	}{
+		{
+			description:      "single directory",
+			paths:            []string{"workloads"},
+			expectedRoots:    []Root{{Path: "workloads", IsDir: true}},
+			expectedRelPaths: []string{"deployment.yaml", "app/service.yaml"},
+			expectedExcluded: []string{"deployment.test.yaml"},
+		},
+		{
+			description:      "single file",
+			paths:            []string{"namespace.yaml"},
+			expectedRoots:    []Root{{Path: "namespace.yaml", Prefix: "namespace.yaml"}},
+			expectedRelPaths: []string{"namespace.yaml"},
+			expectedExcluded: []string{},
+		},
+		{
+			description: "directories and files",
+			paths:       []string{"crds", "workloads", "namespace.yaml"},
+			expectedRoots: []Root{
+				{Path: "crds", Prefix: "crds", IsDir: true},
+				{Path: "workloads", Prefix: "workloads", IsDir: true},
+				{Path: "namespace.yaml", Prefix: "namespace.yaml"},
+			},
+			expectedRelPaths: []string{"crds/widget.yaml", "workloads/deployment.yaml", "workloads/app/service.yaml", "namespace.yaml"},
+			expectedExcluded: []string{"workloads/deployment.test.yaml"},
+		},
+		{
+			description: "conflicting base names",
+			paths:       []string{"crds", "other/crds"},
+			expectedErr: `would both be placed at "crds"`,
+		},
+		{
+			description: "file that is not a manifest",
+			paths:       []string{"crds", "notes.txt"},
+			expectedErr: "is not JSON or YAML",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tempDir := t.TempDir()
+			for name, contents := range files {
+				path := filepath.Join(tempDir, name)
+				g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+				g.Expect(os.WriteFile(path, []byte(contents), 0o644)).To(Succeed())
+			}
+
+			workDir, err := os.Getwd()
+			g.Expect(err).NotTo(HaveOccurred())
+			loadDir, err := filepath.Rel(workDir, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			paths := make([]string, len(tc.paths))
+			for i := range tc.paths {
+				paths[i] = filepath.Join(loadDir, tc.paths[i])
+			}
+
+			loader := NewMultiPathLoader(paths...)
+			defer loader.Cleanup()
+			if tc.expectedErr != "" {
+				g.Expect(loader.Load()).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(loader.Load()).To(Succeed())
+
+			roots := loader.Roots()
+			for i := range roots {
+				relPath, err := filepath.Rel(loadDir, roots[i].Path)
+				g.Expect(err).NotTo(HaveOccurred())
+				roots[i].Path = relPath
+			}
+			g.Expect(roots).To(Equal(tc.expectedRoots))
+
+			dir, relPaths := loader.RelPaths()
+			g.Expect(relPaths).To(ConsistOf(tc.expectedRelPaths))
+			for _, relPath := range tc.expectedRelPaths {
+				g.Expect(filepath.Join(dir, relPath)).To(BeAnExistingFile())
+			}
+			g.Expect(loader.Excluded()).To(Equal(tc.expectedExcluded))
+		})
+	}
+}
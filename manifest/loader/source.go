@@ -0,0 +1,187 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// StdinPath denotes a multi-document stream read from stdin
+	StdinPath = "-"
+	// StdinFileName is the name of the file that stdin stream is stored as
+	StdinFileName = "stdin.yaml"
+
+	gitURLPrefix = "git+"
+)
+
+// Source is a local copy of content that needs to be fetched before it
+// can be loaded, i.e. a stream from stdin or a remote git repo
+type Source struct {
+	// Path is where the content can be loaded from
+	Path string
+	// Digest is the digest of the stream read from stdin
+	Digest string
+	// URL is the URL of a remote git repo
+	URL string
+	// Commit is the commit of a remote git repo that was checked out
+	Commit string
+
+	tempDir string
+}
+
+// IsRemoteSource returns true when path is stdin or a remote git repo,
+// i.e. when it needs to be fetched with FetchSource
+func IsRemoteSource(path string) bool {
+	return path == StdinPath || strings.HasPrefix(path, gitURLPrefix)
+}
+
+// FetchSource reads stdin into a temporary file, or clones a remote git repo
+// given as 'git+<url>[//<path>][?ref=<ref>]', where ref is a tag, a branch
+// or a commit, the repo is cloned into a directory named after the repo
+func FetchSource(ctx context.Context, path string, stdin io.Reader) (*Source, error) {
+	tempDir, err := mkdirTemp()
+	if err != nil {
+		return nil, err
+	}
+	source := &Source{tempDir: tempDir}
+
+	if path == StdinPath {
+		err = source.readStream(stdin)
+	} else {
+		err = source.cloneRepo(ctx, path)
+	}
+	if err != nil {
+		_ = source.Close()
+		return nil, err
+	}
+	return source, nil
+}
+
+func (s *Source) readStream(stdin io.Reader) error {
+	s.Path = filepath.Join(s.tempDir, StdinFileName)
+	file, err := os.Create(s.Path)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hash), stdin)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("unable to read stdin: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no manifests were given on stdin")
+	}
+	s.Digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+func (s *Source) cloneRepo(ctx context.Context, remote string) error {
+	repoURL, subPath, ref, err := ParseGitURL(remote)
+	if err != nil {
+		return err
+	}
+	s.URL = repoURL
+
+	checkoutDir := filepath.Join(s.tempDir, strings.TrimSuffix(path.Base(repoURL), ".git"))
+	repo, err := gogit.PlainCloneContext(ctx, checkoutDir, false, &gogit.CloneOptions{URL: repoURL})
+	if err != nil {
+		return fmt.Errorf("unable to clone %q: %w", repoURL, err)
+	}
+
+	if ref != "" {
+		hash, err := resolveRef(repo, ref)
+		if err != nil {
+			return fmt.Errorf("unable to resolve ref %q in %q: %w", ref, repoURL, err)
+		}
+		workTree, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := workTree.Checkout(&gogit.CheckoutOptions{Hash: *hash}); err != nil {
+			return fmt.Errorf("unable to checkout %q in %q: %w", ref, repoURL, err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	s.Commit = head.Hash().String()
+
+	s.Path = filepath.Join(checkoutDir, filepath.FromSlash(subPath))
+	if _, err := os.Stat(s.Path); err != nil {
+		return fmt.Errorf("path %q cannot be accessed in %q: %w", subPath, repoURL, err)
+	}
+	return nil
+}
+
+// resolveRef resolves a tag, a commit or a branch, which are only
+// present as remote branches in a fresh clone
+func resolveRef(repo *gogit.Repository, ref string) (*plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err == nil {
+		return hash, nil
+	}
+	if hash, err := repo.ResolveRevision(plumbing.Revision(gogit.DefaultRemoteName + "/" + ref)); err == nil {
+		return hash, nil
+	}
+	return nil, err
+}
+
+// ParseGitURL splits a remote git source into URL of the repo, path within
+// the repo and a ref, which are empty when not specified
+func ParseGitURL(remote string) (string, string, string, error) {
+	if !strings.HasPrefix(remote, gitURLPrefix) {
+		return "", "", "", fmt.Errorf("git URL %q must start with %q", remote, gitURLPrefix)
+	}
+	u, err := url.Parse(strings.TrimPrefix(remote, gitURLPrefix))
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid git URL %q: %w", remote, err)
+	}
+	if u.Scheme == "" || u.Path == "" {
+		return "", "", "", fmt.Errorf("invalid git URL %q: scheme and path must be specified", remote)
+	}
+
+	ref := u.Query().Get("ref")
+	u.RawQuery = ""
+
+	// path within the repo is separated by a double slash, the one
+	// that may follow an empty host of a file URL is not counted
+	subPath := ""
+	repoPath := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(repoPath, "//"); i >= 0 {
+		subPath = strings.Trim(repoPath[i+2:], "/")
+		repoPath = repoPath[:i]
+	}
+	u.Path = "/" + repoPath
+	u.RawPath = ""
+	if subPath != "" {
+		if clean := path.Clean(subPath); clean != subPath || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", "", "", fmt.Errorf("invalid path %q in git URL %q", subPath, remote)
+		}
+	}
+	return u.String(), subPath, ref, nil
+}
+
+// Close removes the local copy
+func (s *Source) Close() error {
+	if s.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.tempDir)
+}
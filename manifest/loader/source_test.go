@@ -0,0 +1,139 @@
+package loader_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/manifest/loader"
+)
+
+func TestParseGitURL(t *testing.T) {
+	testCases := []struct {
+		remote, expectedURL, expectedPath, expectedRef, expectedErr string
+	}{
+		{
+			remote:      "git+https://github.com/example/app",
+			expectedURL: "https://github.com/example/app",
+		},
+		{
+			remote:       "git+https://github.com/example/app.git//deploy/manifests?ref=v1.2.3",
+			expectedURL:  "https://github.com/example/app.git",
+			expectedPath: "deploy/manifests",
+			expectedRef:  "v1.2.3",
+		},
+		{
+			remote:       "git+file:///srv/repos/app//deploy",
+			expectedURL:  "file:///srv/repos/app",
+			expectedPath: "deploy",
+		},
+		{
+			remote:      "git+https://github.com/example/app//../other",
+			expectedErr: "invalid path",
+		},
+		{
+			remote:      "git+github.com/example/app",
+			expectedErr: "scheme and path must be specified",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.remote, func(t *testing.T) {
+			g := NewWithT(t)
+
+			url, path, ref, err := ParseGitURL(tc.remote)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(url).To(Equal(tc.expectedURL))
+			g.Expect(path).To(Equal(tc.expectedPath))
+			g.Expect(ref).To(Equal(tc.expectedRef))
+		})
+	}
+}
+
+func TestFetchSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stdin", func(t *testing.T) {
+		g := NewWithT(t)
+
+		stream := "kind: Namespace\n---\nkind: Deployment\n"
+		source, err := FetchSource(ctx, StdinPath, strings.NewReader(stream))
+		g.Expect(err).NotTo(HaveOccurred())
+		defer source.Close()
+
+		g.Expect(filepath.Base(source.Path)).To(Equal(StdinFileName))
+		g.Expect(os.ReadFile(source.Path)).To(BeEquivalentTo(stream))
+		g.Expect(source.Digest).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(stream)))))
+
+		g.Expect(source.Close()).To(Succeed())
+		g.Expect(source.Path).NotTo(BeAnExistingFile())
+
+		_, err = FetchSource(ctx, StdinPath, strings.NewReader(""))
+		g.Expect(err).To(MatchError(ContainSubstring("no manifests")))
+	})
+
+	t.Run("git", func(t *testing.T) {
+		g := NewWithT(t)
+
+		repoDir := t.TempDir()
+		repo, err := gogit.PlainInit(repoDir, false)
+		g.Expect(err).NotTo(HaveOccurred())
+		workTree, err := repo.Worktree()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		commit := func(contents string) string {
+			path := filepath.Join(repoDir, "deploy", "deployment.yaml")
+			g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+			g.Expect(os.WriteFile(path, []byte(contents), 0o644)).To(Succeed())
+			_, err := workTree.Add("deploy/deployment.yaml")
+			g.Expect(err).NotTo(HaveOccurred())
+			hash, err := workTree.Commit(contents, &gogit.CommitOptions{
+				Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			return hash.String()
+		}
+
+		tagged := commit("kind: Deployment\n")
+		_, err = repo.CreateTag("v1.2.3", plumbing.NewHash(tagged), nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		latest := commit("kind: Deployment\nmetadata: {name: app}\n")
+
+		for ref, expected := range map[string]struct{ commit, contents string }{
+			"":       {latest, "kind: Deployment\nmetadata: {name: app}\n"},
+			"v1.2.3": {tagged, "kind: Deployment\n"},
+			"master": {latest, "kind: Deployment\nmetadata: {name: app}\n"},
+			tagged:   {tagged, "kind: Deployment\n"},
+		} {
+			remote := "git+file://" + repoDir + "//deploy"
+			if ref != "" {
+				remote += "?ref=" + ref
+			}
+			source, err := FetchSource(ctx, remote, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(source.URL).To(Equal("file://" + repoDir))
+			g.Expect(source.Commit).To(Equal(expected.commit))
+			g.Expect(filepath.Base(source.Path)).To(Equal("deploy"))
+			g.Expect(os.ReadFile(filepath.Join(source.Path, "deployment.yaml"))).To(BeEquivalentTo(expected.contents))
+			g.Expect(source.Close()).To(Succeed())
+		}
+
+		_, err = FetchSource(ctx, "git+file://"+repoDir+"//deploy?ref=v9.9.9", nil)
+		g.Expect(err).To(MatchError(ContainSubstring(`unable to resolve ref "v9.9.9"`)))
+	})
+}
@@ -0,0 +1,264 @@
+package normaliser
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/manifest/documents"
+)
+
+var (
+	// fields that are set by the API server or by tools, and have no effect on the object
+	nonSemanticMetadataFields = []string{
+		"creationTimestamp",
+		"generation",
+		"managedFields",
+		"resourceVersion",
+		"selfLink",
+		"uid",
+	}
+	nonSemanticAnnotations = []string{
+		"kubectl.kubernetes.io/last-applied-configuration",
+		"deployment.kubernetes.io/revision",
+		kioutil.IndexAnnotation,
+		kioutil.PathAnnotation,
+		kioutil.LegacyIndexAnnotation,
+		kioutil.LegacyPathAnnotation,
+		kioutil.LegacyIdAnnotation,
+		kioutil.InternalAnnotationsMigrationResourceIDAnnotation,
+	}
+	// top-level fields that are placed first, the rest are sorted alphabetically
+	topLevelFields = []string{"apiVersion", "kind", "metadata"}
+)
+
+// Object is an object that was written to a file of its own
+type Object struct {
+	// Line and Column are the position of the object in the original manifest
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+
+	// Path and Digest are of the normalised file
+	Path   string        `json:"path"`
+	Digest digest.SHA256 `json:"digest"`
+}
+
+// Result lists objects found in a manifest
+type Result struct {
+	Manifest       string
+	ManifestDigest digest.SHA256
+	Objects        []Object
+}
+
+// Normaliser writes each object found in given manifests to a separate file named
+// '<namespace>/<kind>-<name>.yaml', or '<kind>-<name>.yaml' when namespace is not
+// set, with keys sorted, styles and comments reset and non-semantic fields removed
+type Normaliser struct {
+	tempDir string
+	results []Result
+	paths   map[string]string
+}
+
+func NewNormaliser() *Normaliser {
+	return &Normaliser{}
+}
+
+// Normalise writes normalised files into a temporary directory
+func (n *Normaliser) Normalise(dir string, manifests []string) error {
+	tempDir, err := os.MkdirTemp("", "bpt-manifest-normaliser-*")
+	if err != nil {
+		return err
+	}
+	n.tempDir = tempDir
+
+	manifests = slices.Clone(manifests)
+	slices.Sort(manifests)
+
+	n.results = make([]Result, len(manifests))
+	n.paths = map[string]string{}
+	for i := range manifests {
+		result, err := n.normaliseManifest(dir, manifests[i])
+		if err != nil {
+			return err
+		}
+		n.results[i] = *result
+	}
+	return nil
+}
+
+func (n *Normaliser) normaliseManifest(dir, manifest string) (*Result, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifest))
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Manifest:       manifest,
+		ManifestDigest: digestOf(data),
+		Objects:        []Object{},
+	}
+
+	nodes, err := documents.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q: %w", manifest, err)
+	}
+	for _, node := range nodes {
+		object, err := n.writeObject(manifest, node)
+		if err != nil {
+			return nil, fmt.Errorf("unable to normalise object at %s:%d:%d: %w", manifest, node.YNode().Line, node.YNode().Column, err)
+		}
+		result.Objects = append(result.Objects, *object)
+	}
+	return result, nil
+}
+
+func (n *Normaliser) writeObject(manifest string, node *yaml.RNode) (*Object, error) {
+	object := &Object{
+		Line:       node.YNode().Line,
+		Column:     node.YNode().Column,
+		APIVersion: node.GetApiVersion(),
+		Kind:       node.GetKind(),
+		Namespace:  node.GetNamespace(),
+		Name:       node.GetName(),
+	}
+	fileName := object.Name
+	if fileName == "" {
+		generateName, err := node.GetString("metadata.generateName")
+		if err != nil || generateName == "" {
+			return nil, fmt.Errorf("metadata.name or metadata.generateName must be set")
+		}
+		fileName = generateName
+	}
+	if object.Kind == "" {
+		return nil, fmt.Errorf("kind must be set")
+	}
+	for _, value := range []string{object.Namespace, object.Kind, fileName} {
+		if strings.ContainsAny(value, `/\`) || value == ".." {
+			return nil, fmt.Errorf("invalid characters in %q", value)
+		}
+	}
+	object.Path = filepath.Join(object.Namespace, strings.ToLower(object.Kind)+"-"+fileName+".yaml")
+	if other, ok := n.paths[object.Path]; ok {
+		return nil, fmt.Errorf("object would be written to %q, which already holds object from %s", object.Path, other)
+	}
+	n.paths[object.Path] = fmt.Sprintf("%s:%d:%d", manifest, object.Line, object.Column)
+
+	if err := normaliseObject(node); err != nil {
+		return nil, err
+	}
+	text, err := node.String()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(n.tempDir, object.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		return nil, err
+	}
+	object.Digest = digestOf([]byte(text))
+	return object, nil
+}
+
+func normaliseObject(node *yaml.RNode) error {
+	if err := node.PipeE(yaml.Clear("status")); err != nil {
+		return err
+	}
+	metadata := node.Field(yaml.MetadataField)
+	if metadata != nil {
+		for _, field := range nonSemanticMetadataFields {
+			if err := metadata.Value.PipeE(yaml.Clear(field)); err != nil {
+				return err
+			}
+		}
+		for _, annotation := range nonSemanticAnnotations {
+			if err := node.PipeE(yaml.ClearAnnotation(annotation)); err != nil {
+				return err
+			}
+		}
+		if annotations := metadata.Value.Field(yaml.AnnotationsField); annotations != nil {
+			if len(annotations.Value.Content()) == 0 {
+				if err := metadata.Value.PipeE(yaml.Clear(yaml.AnnotationsField)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	resetNode(node.YNode(), true)
+	return nil
+}
+
+// resetNode sorts keys of mappings, resets styles and removes comments, strings that
+// would be read as a different type are still quoted, as tags are left as they are
+func resetNode(node *yaml.Node, topLevel bool) {
+	node.Style = 0
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	for _, child := range node.Content {
+		resetNode(child, false)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	slices.SortStableFunc(pairs, func(a, b [2]*yaml.Node) int {
+		if topLevel {
+			ai, bi := fieldOrder(a[0].Value), fieldOrder(b[0].Value)
+			if ai != bi {
+				return ai - bi
+			}
+		}
+		return strings.Compare(a[0].Value, b[0].Value)
+	})
+	for i := range pairs {
+		node.Content[2*i], node.Content[2*i+1] = pairs[i][0], pairs[i][1]
+	}
+}
+
+func fieldOrder(key string) int {
+	if i := slices.Index(topLevelFields, key); i >= 0 {
+		return i
+	}
+	return len(topLevelFields)
+}
+
+// Results returns objects found in each of the manifests
+func (n *Normaliser) Results() []Result { return n.results }
+
+// RelPaths returns the directory with normalised files, and paths within it
+func (n *Normaliser) RelPaths() (string, []string) {
+	paths := make([]string, 0, len(n.paths))
+	for path := range n.paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return n.tempDir, paths
+}
+
+func (n *Normaliser) Cleanup() error {
+	if n.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(n.tempDir)
+}
+
+func digestOf(data []byte) digest.SHA256 {
+	hash := sha256.New()
+	_, _ = hash.Write(data)
+	return digest.MakeSHA256(hash)
+}
@@ -0,0 +1,150 @@
+package normaliser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/manifest/normaliser"
+)
+
+func TestNormaliser(t *testing.T) {
+	testCases := []struct {
+		description     string
+		manifests       map[string]string
+		expectedFiles   map[string]string
+		expectedObjects map[string][]Object
+		expectedErr     string
+	}{
+		{
+			description: "multiple documents",
+			manifests: map[string]string{
+				"all.yaml": `# app
+apiVersion: v1
+kind: Namespace
+metadata: {name: app, uid: 5b3c0e42}
+---
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  namespace: app
+  name: web # the web
+  creationTimestamp: null
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+    owner: "team-a"
+spec:
+  template:
+    spec:
+      containers:
+      - {name: web, image: 'nginx:1.25', args: ["true", '123']}
+  replicas: 2
+status: {replicas: 1}
+`,
+			},
+			expectedFiles: map[string]string{
+				"namespace-app.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`,
+				"app/deployment-web.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    owner: team-a
+  name: web
+  namespace: app
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - args:
+        - "true"
+        - "123"
+        image: nginx:1.25
+        name: web
+`,
+			},
+			expectedObjects: map[string][]Object{
+				"all.yaml": {
+					{Line: 2, Column: 1, APIVersion: "v1", Kind: "Namespace", Name: "app", Path: "namespace-app.yaml"},
+					{Line: 6, Column: 1, APIVersion: "apps/v1", Kind: "Deployment", Namespace: "app", Name: "web", Path: "app/deployment-web.yaml"},
+				},
+			},
+		},
+		{
+			description: "lists and generated names",
+			manifests: map[string]string{
+				"list.yaml": "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: ConfigMap\n  metadata: {name: a, namespace: app}\n",
+				"job.yaml":  "apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: migrate-\n",
+			},
+			expectedFiles: map[string]string{
+				"app/configmap-a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: app\n",
+				"job-migrate-.yaml":    "apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: migrate-\n",
+			},
+			expectedObjects: map[string][]Object{
+				"list.yaml": {{Line: 4, Column: 3, APIVersion: "v1", Kind: "ConfigMap", Namespace: "app", Name: "a", Path: "app/configmap-a.yaml"}},
+				"job.yaml":  {{Line: 1, Column: 1, APIVersion: "batch/v1", Kind: "Job", Path: "job-migrate-.yaml"}},
+			},
+		},
+		{
+			description: "conflicting objects",
+			manifests: map[string]string{
+				"a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n",
+				"b.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\n",
+			},
+			expectedErr: `unable to normalise object at b.yaml:1:1: object would be written to "configmap-a.yaml", which already holds object from a.yaml:1:1`,
+		},
+		{
+			description: "objects without name",
+			manifests: map[string]string{
+				"a.yaml": "apiVersion: v1\nkind: ConfigMap\n",
+			},
+			expectedErr: "metadata.name or metadata.generateName must be set",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			manifests := []string{}
+			for name, data := range tc.manifests {
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644)).To(Succeed())
+				manifests = append(manifests, name)
+			}
+
+			normaliser := NewNormaliser()
+			defer normaliser.Cleanup()
+			err := normaliser.Normalise(dir, manifests)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			normalisedDir, paths := normaliser.RelPaths()
+			g.Expect(paths).To(HaveLen(len(tc.expectedFiles)))
+			for path, expected := range tc.expectedFiles {
+				g.Expect(os.ReadFile(filepath.Join(normalisedDir, path))).To(BeEquivalentTo(expected))
+			}
+
+			results := normaliser.Results()
+			g.Expect(results).To(HaveLen(len(tc.expectedObjects)))
+			for _, result := range results {
+				g.Expect(result.ManifestDigest).NotTo(BeEmpty())
+				for i := range result.Objects {
+					g.Expect(result.Objects[i].Digest).NotTo(BeEmpty())
+					result.Objects[i].Digest = ""
+				}
+				g.Expect(result.Objects).To(Equal(tc.expectedObjects[result.Manifest]))
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package packager
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	attestTypes "github.com/errordeveloper/tape/attest/types"
@@ -11,6 +13,9 @@ import (
 type Packager interface {
 	//Pull(string) error
 	Push(context.Context, string) (*oci.PackageRefs, error)
+	Build(context.Context, string) (*oci.Artefact, error)
+	CheckReproducible(context.Context, string) (*oci.Hash, error)
+	WithContentInterpreter(string)
 }
 
 type DefaultPackager struct {
@@ -18,6 +23,7 @@ type DefaultPackager struct {
 	destinationRef       string
 	sourceEpochTimestamp *time.Time
 	sourceAttestations   attestTypes.Statements
+	contentInterpreter   string
 }
 
 func NewDefaultPackager(client *oci.Client, destinationRef string, sourceEpochTimestamp *time.Time, sourceAttestations ...attestTypes.Statement) Packager {
@@ -29,10 +35,43 @@ func NewDefaultPackager(client *oci.Client, destinationRef string, sourceEpochTi
 		destinationRef:       destinationRef,
 		sourceEpochTimestamp: sourceEpochTimestamp,
 		sourceAttestations:   sourceAttestations,
+		contentInterpreter:   oci.ContentInterpreterKubectlApply,
 	}
 }
 
+// WithContentInterpreter sets how content of the artefact should be consumed,
+// by default it's a directory of manifests to apply with kubectl
+func (r *DefaultPackager) WithContentInterpreter(contentInterpreter string) {
+	r.contentInterpreter = contentInterpreter
+}
+
 func (r *DefaultPackager) Push(ctx context.Context, dir string) (*oci.PackageRefs, error) {
-	return r.Client.PushArtefact(ctx, r.destinationRef, dir,
+	return r.Client.PushArtefact(ctx, r.destinationRef, dir, r.contentInterpreter,
+		r.sourceEpochTimestamp, r.sourceAttestations...)
+}
+
+func (r *DefaultPackager) Build(ctx context.Context, dir string) (*oci.Artefact, error) {
+	return r.Client.BuildArtefactIndex(ctx, r.destinationRef, dir, r.contentInterpreter,
 		r.sourceEpochTimestamp, r.sourceAttestations...)
 }
+
+// CheckReproducible builds the artefact twice without pushing it, and
+// returns an error describing what differs if digests don't match
+func (r *DefaultPackager) CheckReproducible(ctx context.Context, dir string) (*oci.Hash, error) {
+	first, err := r.Build(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("first build failed: %w", err)
+	}
+	defer first.Cleanup()
+
+	second, err := r.Build(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("second build failed: %w", err)
+	}
+	defer second.Cleanup()
+
+	if diff := first.Diff(second); len(diff) > 0 {
+		return nil, fmt.Errorf("artefact is not reproducible, digests differ for: %s", strings.Join(diff, ", "))
+	}
+	return &first.Digest, nil
+}
@@ -3,10 +3,15 @@ package packager_test
 import (
 	"context"
 	"crypto/sha256"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	. "github.com/onsi/gomega"
+	"github.com/otiai10/copy"
 
 	"github.com/errordeveloper/tape/attest"
 	"github.com/errordeveloper/tape/attest/manifest"
@@ -109,3 +114,42 @@ func makePackagerTest(tc testdata.TestCase) func(t *testing.T) {
 		// e.g. also as the means to test inspection logic (TBI)
 	}
 }
+
+func TestCheckReproducible(t *testing.T) {
+	ctx := context.Background()
+	client := oci.NewClient(nil)
+
+	cases := testdata.BasicJSONCases()
+	cases.Run(t, ("../../"), func(tc testdata.TestCase) func(t *testing.T) {
+		return func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, attreg, err := attest.DetectVCS(tc.Directory)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			timestamp := time.Unix(1700000000, 0)
+			packager := NewDefaultPackager(client, "example.com/bpt-packager-test", &timestamp, attreg.GetStatements()...)
+
+			digest, err := packager.CheckReproducible(ctx, tc.Directory)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			// permissions and modification times must not affect the digest
+			tempDir := t.TempDir()
+			g.Expect(copy.Copy(tc.Directory, tempDir, copy.Options{
+				PermissionControl: copy.AddPermission(0o020),
+			})).To(Succeed())
+			later := time.Now()
+			g.Expect(filepath.WalkDir(tempDir, func(path string, _ fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				return os.Chtimes(path, later, later)
+			})).To(Succeed())
+
+			artefact, err := packager.Build(ctx, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer artefact.Cleanup()
+			g.Expect(artefact.Digest).To(Equal(*digest))
+		}
+	})
+}
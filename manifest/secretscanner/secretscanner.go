@@ -0,0 +1,275 @@
+package secretscanner
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/manifest/documents"
+)
+
+const (
+	// RuleSecretData is reported for each key in data or stringData of a Secret
+	RuleSecretData = "secret-data"
+	// RuleHighEntropy is reported for strings that look like random tokens
+	RuleHighEntropy = "high-entropy"
+
+	// strings shorter than that are too short to tell if they are random
+	minHighEntropyLength = 24
+	// random base64 strings are well above this, while e.g. hex digests
+	// and words are below it
+	highEntropyThreshold = 4.5
+)
+
+var (
+	tokenPatterns = []struct {
+		rule    string
+		pattern *regexp.Regexp
+	}{
+		{"private-key", regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)},
+		{"aws-access-key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{"gitlab-token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+		{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+		{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+		{"stripe-key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+		{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+		{"url-credentials", regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@]+@`)},
+	}
+	tokenCharset = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+)
+
+// Finding is a value that looks sensitive, the value itself is never
+// recorded, only a redacted version of it
+type Finding struct {
+	Manifest string `json:"-"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Kind     string `json:"kind,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Path     string `json:"path"`
+	Rule     string `json:"rule"`
+	Redacted string `json:"redacted"`
+}
+
+func (f Finding) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", f.Manifest, f.Line, f.Column, f.Path, f.Rule, f.Redacted)
+}
+
+type Findings []Finding
+
+func (f Findings) Error() string {
+	messages := make([]string, len(f))
+	for i := range f {
+		messages[i] = f[i].Error()
+	}
+	return fmt.Sprintf("found %d potential secrets:\n%s", len(f), strings.Join(messages, "\n"))
+}
+
+// Result lists findings in a manifest
+type Result struct {
+	Manifest       string
+	ManifestDigest digest.SHA256
+	Findings       []Finding
+}
+
+// Scanner looks for Secret objects with data, known token patterns and
+// strings with high entropy in values of all objects
+type Scanner struct {
+	results []Result
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{}
+}
+
+// Scan checks all documents in given manifests, findings are returned by
+// Results and Findings; files other than JSON and YAML are skipped, as charts
+// and kustomize bases may contain any files
+func (s *Scanner) Scan(dir string, manifests []string) error {
+	manifests = slices.DeleteFunc(slices.Clone(manifests), func(path string) bool {
+		switch filepath.Ext(path) {
+		case ".json", ".yaml", ".yml":
+			return false
+		default:
+			return true
+		}
+	})
+	slices.Sort(manifests)
+
+	s.results = make([]Result, len(manifests))
+	for i := range manifests {
+		data, err := os.ReadFile(filepath.Join(dir, manifests[i]))
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, _ = hash.Write(data)
+		s.results[i] = Result{
+			Manifest:       manifests[i],
+			ManifestDigest: digest.MakeSHA256(hash),
+			Findings:       []Finding{},
+		}
+
+		nodes, err := documents.Decode(data)
+		if err != nil {
+			return fmt.Errorf("unable to parse %q: %w", manifests[i], err)
+		}
+		for _, node := range nodes {
+			s.results[i].Findings = append(s.results[i].Findings, scanObject(manifests[i], node)...)
+		}
+	}
+	return nil
+}
+
+// Results returns findings for each of the manifests
+func (s *Scanner) Results() []Result { return s.results }
+
+// Findings returns all findings, except for those of the allowed rules
+func (s *Scanner) Findings(allowedRules ...string) Findings {
+	findings := Findings{}
+	for _, result := range s.results {
+		for _, finding := range result.Findings {
+			if !slices.Contains(allowedRules, finding.Rule) {
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// Rules returns names of all rules
+func Rules() []string {
+	rules := []string{RuleSecretData, RuleHighEntropy}
+	for _, token := range tokenPatterns {
+		rules = append(rules, token.rule)
+	}
+	return rules
+}
+
+func scanObject(manifest string, node *yaml.RNode) []Finding {
+	findings := []Finding{}
+	kind, name := node.GetKind(), node.GetName()
+	addFinding := func(node *yaml.Node, path, rule string) {
+		findings = append(findings, Finding{
+			Manifest: manifest,
+			Line:     node.Line,
+			Column:   node.Column,
+			Kind:     kind,
+			Name:     name,
+			Path:     path,
+			Rule:     rule,
+			Redacted: redact(node.Value, rule),
+		})
+	}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				fieldPath := key.Value
+				if path != "" {
+					fieldPath = path + "." + key.Value
+				}
+				if isSecretData(kind, fieldPath) {
+					for j := 0; j+1 < len(value.Content); j += 2 {
+						if value.Content[j+1].Value != "" {
+							addFinding(value.Content[j+1], fieldPath+"."+value.Content[j].Value, RuleSecretData)
+						}
+					}
+					continue
+				}
+				walk(value, fieldPath)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(item, path+"["+strconv.Itoa(i)+"]")
+			}
+		case yaml.ScalarNode:
+			if node.Tag != yaml.NodeTagString {
+				return
+			}
+			if rule := matchToken(node.Value); rule != "" {
+				addFinding(node, path, rule)
+			}
+		}
+	}
+	walk(node.YNode(), "")
+	return findings
+}
+
+func isSecretData(kind string, path string) bool {
+	return kind == "Secret" && (path == "data" || path == "stringData")
+}
+
+func matchToken(value string) string {
+	for _, token := range tokenPatterns {
+		if token.pattern.MatchString(value) {
+			return token.rule
+		}
+	}
+	if len(value) >= minHighEntropyLength && tokenCharset.MatchString(value) && entropy(value) > highEntropyThreshold {
+		if isEncodedPublicPEM(value) {
+			return ""
+		}
+		return RuleHighEntropy
+	}
+	return ""
+}
+
+// isEncodedPublicPEM checks if value is base64-encoded PEM with only certificates
+// and public keys, e.g. caBundle of webhooks and API services, it's not sensitive
+func isEncodedPublicPEM(value string) bool {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return found && len(strings.TrimSpace(string(data))) == 0
+		}
+		if block.Type != "CERTIFICATE" && !strings.HasSuffix(block.Type, "PUBLIC KEY") {
+			return false
+		}
+		found = true
+	}
+}
+
+// entropy returns Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redact keeps a few leading characters of values that match known token patterns,
+// as these are the same for all tokens of the kind, any other values are masked
+func redact(value, rule string) string {
+	if rule == RuleSecretData || rule == RuleHighEntropy || len(value) < 16 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
@@ -71,7 +71,7 @@ func BasicJSONCases() TestCases {
 					ImageSourceLocation: types.ImageSourceLocation{
 						Manifest:       "list.json",
 						ManifestDigest: "577caeee80cfa690caf25bcdd4b1919b99d2860eb351c48e81b46b9e4b52aea5",
-						NodePath:       []string{"items", "spec", "containers", "image"},
+						NodePath:       []string{"spec", "containers", "image"},
 						Line:           106,
 						Column:         42,
 					},
@@ -124,7 +124,7 @@ var baseYAMLCases = []TestCase{
 						Manifest:       "02-job-certgen.yaml",
 						ManifestDigest: "ba03dc02890e0ca080f12f03fd06a1d4f6b76ff75be0346ee27c9aa73c6d1d31",
 						NodePath:       []string{"spec", "template", "spec", "containers", "image"},
-						Line:           14,
+						Line:           49,
 						Column:         16,
 					},
 					OriginalRef: "ghcr.io/projectcontour/contour:v1.24.1",
@@ -205,7 +205,7 @@ var baseYAMLCases = []TestCase{
 						Manifest:       "flux.yaml",
 						ManifestDigest: "39ad63101dbb2ead069ca6185bd44f99f52b8513682d6002109c9b0db23f73b5",
 						NodePath:       []string{"spec", "template", "spec", "containers", "image"},
-						Line:           37,
+						Line:           4142,
 						Column:         16,
 					},
 					OriginalRef: "ghcr.io/fluxcd/kustomize-controller:v0.30.0",
@@ -219,7 +219,7 @@ var baseYAMLCases = []TestCase{
 						Manifest:       "flux.yaml",
 						ManifestDigest: "39ad63101dbb2ead069ca6185bd44f99f52b8513682d6002109c9b0db23f73b5",
 						NodePath:       []string{"spec", "template", "spec", "containers", "image"},
-						Line:           43,
+						Line:           4234,
 						Column:         16,
 					},
 					OriginalRef: "ghcr.io/fluxcd/source-controller:v0.31.0",
@@ -248,7 +248,7 @@ var baseYAMLCases = []TestCase{
 						Manifest:       "base/tekton-base.yaml",
 						ManifestDigest: "c2cbc6d7a3c30f99e2e504d5758d8e0ce140a8f444c4d944d85c3f29800bf8c5",
 						NodePath:       []string{"spec", "template", "spec", "containers", "image"},
-						Line:           64,
+						Line:           1786,
 						Column:         16,
 					},
 					OriginalRef: "gcr.io/tekton-releases/github.com/tektoncd/pipeline/cmd/controller:v0.40.2@sha256:dc7bc7d6607466b502d8dc22ba0598461d7477f608ab68aaff1ff4dedaa04f81",
@@ -263,7 +263,7 @@ var baseYAMLCases = []TestCase{
 						Manifest:       "base/tekton-base.yaml",
 						ManifestDigest: "c2cbc6d7a3c30f99e2e504d5758d8e0ce140a8f444c4d944d85c3f29800bf8c5",
 						NodePath:       []string{"spec", "template", "spec", "containers", "image"},
-						Line:           79,
+						Line:           2025,
 						Column:         16,
 					},
 					OriginalRef: "gcr.io/tekton-releases/github.com/tektoncd/pipeline/cmd/webhook:v0.40.2@sha256:6b8aadbdcede63969ecb719e910b55b7681d87110fc0bf92ca4ee943042f620b",
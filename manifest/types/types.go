@@ -15,6 +15,7 @@ type (
 	Image               = image.Image
 	Source              = image.Source
 	ImageSourceLocation = image.ImageSourceLocation
+	ValueFields         = image.ValueFields
 	ImageList           = image.ImageList
 )
 
@@ -0,0 +1,184 @@
+package updater
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+// editor rewrites scalar values in place, so that formatting, comments
+// and JSON syntax of a file remain as they are
+type editor struct {
+	data  []byte
+	lines []int
+	edits []edit
+}
+
+func newEditor(data []byte) *editor {
+	lines := []int{0}
+	for i := range data {
+		if data[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &editor{data: data, lines: lines}
+}
+
+// offset converts line and column as reported by the parser, both starting at 1
+// and column counting characters, into a byte offset
+func (e *editor) offset(line, column int) (int, error) {
+	if line < 1 || line > len(e.lines) || column < 1 {
+		return 0, fmt.Errorf("position %d:%d is out of range", line, column)
+	}
+	offset := e.lines[line-1]
+	for i := 1; i < column; i++ {
+		if offset >= len(e.data) || e.data[offset] == '\n' {
+			return 0, fmt.Errorf("position %d:%d is out of range", line, column)
+		}
+		_, size := utf8.DecodeRune(e.data[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+// replaceScalar replaces scalar at given position, the current value is checked
+// to make sure the position is correct, and the quoting style is preserved
+func (e *editor) replaceScalar(line, column int, expected, value string) error {
+	start, err := e.offset(line, column)
+	if err != nil {
+		return err
+	}
+	end, current, err := e.scanScalar(start)
+	if err != nil {
+		return fmt.Errorf("unable to read value at %d:%d: %w", line, column, err)
+	}
+	if current != expected {
+		return fmt.Errorf("unexpected value %q at %d:%d, expected %q", current, line, column, expected)
+	}
+	text, style := value, byte(0)
+	if start < end {
+		style = e.data[start]
+	}
+	switch style {
+	case '"':
+		text = strconv.Quote(value)
+	case '\'':
+		text = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	default:
+		if style == 0 {
+			text = strconv.Quote(value)
+		} else {
+			text = formatPlain(value)
+		}
+		if style == 0 && start > 0 && e.data[start-1] == ':' {
+			// empty value right after the key
+			text = " " + text
+		}
+	}
+	e.edits = append(e.edits, edit{start: start, end: end, text: text})
+	return nil
+}
+
+func (e *editor) insert(offset int, text string) {
+	for i := range e.edits {
+		if e.edits[i].start == offset && e.edits[i].end == offset {
+			e.edits[i].text += text
+			return
+		}
+	}
+	e.edits = append(e.edits, edit{start: offset, end: offset, text: text})
+}
+
+// insertLine adds a line after given line
+func (e *editor) insertLine(line int, text string) {
+	if line < len(e.lines) {
+		e.insert(e.lines[line], text+"\n")
+		return
+	}
+	e.insert(len(e.data), "\n"+text)
+}
+
+// formatPlain returns value as a plain scalar, unless it would
+// not be read as a string
+func formatPlain(value string) string {
+	if value == "" || yaml.NewScalarRNode(value).YNode().ShortTag() != yaml.NodeTagString {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// scanScalar returns end offset and value of a single-line scalar
+func (e *editor) scanScalar(start int) (int, string, error) {
+	lineEnd := bytes.IndexByte(e.data[start:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(e.data)
+	} else {
+		lineEnd += start
+	}
+	if start == lineEnd {
+		return start, "", nil
+	}
+	switch quote := e.data[start]; quote {
+	case '"':
+		for i := start + 1; i < lineEnd; i++ {
+			switch e.data[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(string(e.data[start : i+1]))
+				return i + 1, value, err
+			}
+		}
+		return 0, "", fmt.Errorf("multi-line or unterminated double-quoted scalars are not supported")
+	case '\'':
+		for i := start + 1; i < lineEnd; i++ {
+			if e.data[i] != '\'' {
+				continue
+			}
+			if i+1 < lineEnd && e.data[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, strings.ReplaceAll(string(e.data[start+1:i]), "''", "'"), nil
+		}
+		return 0, "", fmt.Errorf("multi-line or unterminated single-quoted scalars are not supported")
+	case '|', '>':
+		return 0, "", fmt.Errorf("block scalars are not supported")
+	}
+	end := lineEnd
+	if i := bytes.Index(e.data[start:lineEnd], []byte(" #")); i != -1 {
+		end = start + i
+	}
+	// plain scalars inside of flow collections end at an indicator
+	if i := bytes.IndexAny(e.data[start:end], ",]}"); i != -1 {
+		end = start + i
+	}
+	value := strings.TrimRight(string(e.data[start:end]), " \t\r")
+	return start + len(value), value, nil
+}
+
+func (e *editor) apply() ([]byte, error) {
+	edits := slices.Clone(e.edits)
+	slices.SortFunc(edits, func(a, b edit) int { return b.start - a.start })
+	data := e.data
+	for i := range edits {
+		if i > 0 && edits[i].end > edits[i-1].start {
+			return nil, fmt.Errorf("overlapping edits at offset %d", edits[i].start)
+		}
+		updated := make([]byte, 0, len(data)+len(edits[i].text))
+		updated = append(updated, data[:edits[i].start]...)
+		updated = append(updated, edits[i].text...)
+		data = append(updated, data[edits[i].end:]...)
+	}
+	return data, nil
+}
@@ -0,0 +1,261 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	kustomize "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/loader"
+	manifestTypes "github.com/errordeveloper/tape/manifest/types"
+)
+
+// KustomizationUpdater pins images by adding them to the images transformer
+// of the kustomization, so that manifests of a kustomize base remain unchanged
+type KustomizationUpdater struct {
+	hash      hash.Hash
+	mutations attestTypes.Mutations
+	// transformers holds location of the transformer that was added or updated for each image,
+	// keyed by the reference that the transformer produces
+	transformers map[string]manifestTypes.ImageSourceLocation
+	// retargeted holds names of transformers that already existed in the kustomization,
+	// keyed by name and tag that images were retargeted to, as digest is resolved later
+	retargeted map[string]string
+}
+
+func NewKustomizationUpdater() *KustomizationUpdater {
+	return &KustomizationUpdater{
+		hash:         sha256.New(),
+		mutations:    attestTypes.Mutations{},
+		transformers: map[string]manifestTypes.ImageSourceLocation{},
+		retargeted:   map[string]string{},
+	}
+}
+
+// ApplyTransformers replaces references of images that are transformed by the kustomization
+// with what the transformer produces, so that digest of the image that kustomize actually
+// uses is resolved; it must be called before digests are resolved and Update is called
+func (u *KustomizationUpdater) ApplyTransformers(images *manifestTypes.ImageList) error {
+	path, err := loader.FindKustomization(images.Dir())
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read kustomization: %w", err)
+	}
+	kustomization := &kustomize.Kustomization{}
+	if err := sigsyaml.Unmarshal(data, kustomization); err != nil {
+		return fmt.Errorf("unable to parse kustomization: %w", err)
+	}
+
+	for i := range images.Items() {
+		image := &images.Items()[i]
+		for _, transformer := range kustomization.Images {
+			if transformer.Name != image.OriginalName {
+				continue
+			}
+			if transformer.NewName != "" {
+				image.OriginalName = transformer.NewName
+			}
+			if transformer.NewTag != "" {
+				image.OriginalTag = transformer.NewTag
+			}
+			image.OriginalTag += transformer.TagSuffix
+			if transformer.Digest != "" {
+				// tag is ignored by kustomize when digest is set
+				image.OriginalTag = ""
+				image.Digest = transformer.Digest
+			}
+			u.retargeted[retargetedKey(image)] = transformer.Name
+			break
+		}
+	}
+	return nil
+}
+
+func (u *KustomizationUpdater) Update(images *manifestTypes.ImageList) error {
+	path, err := loader.FindKustomization(images.Dir())
+	if err != nil {
+		return err
+	}
+	relPath, err := filepath.Rel(images.Dir(), path)
+	if err != nil {
+		return err
+	}
+
+	originalDigest, err := fileDigest(u.hash, path)
+	if err != nil {
+		return err
+	}
+
+	kustomization, err := yaml.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read kustomization: %w", err)
+	}
+	transformers, err := kustomization.Pipe(yaml.LookupCreate(yaml.SequenceNode, "images"))
+	if err != nil {
+		return fmt.Errorf("unable to update kustomization images: %w", err)
+	}
+
+	existing, err := transformers.ElementValues("name")
+	if err != nil {
+		return fmt.Errorf("unable to read kustomization images: %w", err)
+	}
+	pinned := map[string]string{}
+	transformedRefs := map[string]string{}
+	for _, image := range images.Items() {
+		name, isRetargeted := u.retargeted[retargetedKey(&image)]
+		if !isRetargeted {
+			name = image.OriginalName
+			if slices.Contains(existing, name) {
+				// digest that was resolved would not be of the image that
+				// the existing transformer produces
+				return fmt.Errorf("image %q is transformed by %q, but transformers were not applied", name, relPath)
+			}
+		}
+		if ref, ok := pinned[name]; ok {
+			if ref == image.Ref(true) {
+				continue
+			}
+			return fmt.Errorf("image %q is referenced as %q and %q, which cannot be pinned by an images transformer", name, ref, image.Ref(true))
+		}
+		pinned[name] = image.Ref(true)
+		transformedRefs[name] = image.Ref(false)
+
+		transformer := yaml.NewMapRNode(nil)
+		if isRetargeted {
+			// existing transformer is updated in place, so that other fields and comments are kept
+			transformer, err = transformers.Pipe(yaml.MatchElement("name", name))
+			if err != nil {
+				return fmt.Errorf("unable to update kustomization images: %w", err)
+			}
+			// suffix is already part of the tag that the digest was resolved for
+			if err := transformer.PipeE(yaml.Clear("tagSuffix")); err != nil {
+				return fmt.Errorf("unable to update kustomization images: %w", err)
+			}
+		}
+		for _, field := range [][2]string{
+			{"name", name},
+			{"newName", image.NewName},
+			{"newTag", image.NewTag},
+			{"digest", image.Digest},
+		} {
+			if err := transformer.PipeE(yaml.SetField(field[0], yaml.NewStringRNode(field[1]))); err != nil {
+				return fmt.Errorf("unable to update kustomization images: %w", err)
+			}
+		}
+		if !isRetargeted {
+			if err := transformers.PipeE(yaml.Append(transformer.YNode())); err != nil {
+				return fmt.Errorf("unable to update kustomization images: %w", err)
+			}
+		}
+	}
+
+	if err := yaml.WriteFile(kustomization, path); err != nil {
+		return fmt.Errorf("unable to write kustomization: %w", err)
+	}
+
+	newDigest, err := fileDigest(u.hash, path)
+	if err != nil {
+		return err
+	}
+	key := attestTypes.PathCheckerRegistryKey{
+		Path:   relPath,
+		Digest: newDigest,
+	}
+	if _, ok := u.mutations[key]; ok {
+		return fmt.Errorf("mutations with key %#v is already registered", key)
+	}
+	u.mutations[key] = originalDigest
+
+	// kustomization is read again, as nodes that were added have no position
+	updated, err := yaml.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read kustomization: %w", err)
+	}
+	elements, err := updated.Pipe(yaml.Lookup("images"))
+	if err != nil {
+		return fmt.Errorf("unable to read kustomization images: %w", err)
+	}
+	for i, element := range elements.Content() {
+		name, err := yaml.NewRNode(element).GetString("name")
+		if err != nil {
+			return fmt.Errorf("unable to read kustomization images: %w", err)
+		}
+		ref, ok := transformedRefs[name]
+		if !ok {
+			continue
+		}
+		u.transformers[ref] = manifestTypes.ImageSourceLocation{
+			Manifest:       relPath,
+			ManifestDigest: newDigest,
+			Line:           element.Line,
+			Column:         element.Column,
+			NodePath:       []string{"images", strconv.Itoa(i)},
+		}
+	}
+	return nil
+}
+
+func (u *KustomizationUpdater) Mutations() attestTypes.Mutations { return u.mutations }
+
+// TransformedImages is same as the package-level TransformedImages function, except that
+// transformers that were added or updated in the kustomization are included in sources of
+// each image, so that the updated kustomization is covered by statements about replaced images
+func (u *KustomizationUpdater) TransformedImages(images *manifestTypes.ImageList) *manifestTypes.ImageList {
+	transformed := TransformedImages(images)
+	for i := range transformed.Items() {
+		image := &transformed.Items()[i]
+		if location, ok := u.transformers[image.Ref(true)]; ok {
+			image.Sources = append(image.Sources, manifestTypes.Source{
+				ImageSourceLocation: location,
+				OriginalRef:         image.Ref(true),
+			})
+		}
+	}
+	return transformed
+}
+
+func retargetedKey(image *manifestTypes.Image) string {
+	return image.OriginalName + ":" + image.OriginalTag
+}
+
+func fileDigest(hash hash.Hash, path string) (digest.SHA256, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash.Reset()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return digest.MakeSHA256(hash), nil
+}
+
+// TransformedImages returns images as they appear once kustomize applies
+// the images transformer, i.e. with new names and tags
+func TransformedImages(images *manifestTypes.ImageList) *manifestTypes.ImageList {
+	transformed := manifestTypes.NewImageList(images.Dir())
+	for _, image := range images.Items() {
+		transformed.Append(manifestTypes.Image{
+			Sources:      image.Sources,
+			OriginalName: image.NewName,
+			OriginalTag:  image.NewTag,
+			Digest:       image.Digest,
+			Alias:        image.Alias,
+		})
+	}
+	return transformed
+}
@@ -0,0 +1,162 @@
+package updater_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	"github.com/errordeveloper/tape/manifest/imagescanner"
+	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/manifest/types"
+	. "github.com/errordeveloper/tape/manifest/updater"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.25
+      - name: sidecar
+        image: %s
+`
+
+func TestKustomizationUpdater(t *testing.T) {
+	testCases := []struct {
+		description   string
+		kustomization string
+		sidecar       string
+		expectErr     string
+		// expectNginx is the transformer expected for nginx image
+		expectNginx map[string]string
+	}{
+		{
+			description:   "images are added to transformer",
+			kustomization: "resources:\n- deployment.yaml\n",
+			sidecar:       "busybox:1.36",
+		},
+		{
+			description:   "existing transformer is updated",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: nginx\n  newTag: \"1.26\"\n",
+			sidecar:       "busybox:1.36",
+			expectNginx: map[string]string{
+				"name":    "nginx",
+				"newName": "example.com/app",
+				"newTag":  "app.nginx",
+				"digest":  "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			},
+		},
+		{
+			description:   "existing transformer with new name is updated",
+			kustomization: "resources:\n- deployment.yaml\nimages:\n- name: nginx\n  newName: example.org/nginx\n  tagSuffix: -alpine\n",
+			sidecar:       "busybox:1.36",
+			expectNginx: map[string]string{
+				"name":    "nginx",
+				"newName": "example.com/app",
+				"newTag":  "app.example.org/nginx",
+				"digest":  "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			},
+		},
+		{
+			description:   "different tags of the same image cannot be pinned",
+			kustomization: "resources:\n- deployment.yaml\n",
+			sidecar:       "nginx:1.24",
+			expectErr:     `image "nginx" is referenced as`,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tempDir := t.TempDir()
+			deployment := []byte(fmt.Sprintf(testDeployment, tc.sidecar))
+			g.Expect(os.WriteFile(filepath.Join(tempDir, "kustomization.yaml"), []byte(tc.kustomization), 0o644)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(tempDir, "deployment.yaml"), deployment, 0o644)).To(Succeed())
+
+			workDir, err := os.Getwd()
+			g.Expect(err).NotTo(HaveOccurred())
+			loadPath, err := filepath.Rel(workDir, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			loader := loader.NewRecursiveDirectoryLoader(loadPath, loader.ContentKindKustomize)
+			g.Expect(loader.Load()).To(Succeed())
+			defer loader.Cleanup()
+
+			scanner := imagescanner.NewDefaultImageScanner()
+			g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+			images := scanner.GetImages()
+			g.Expect(images.Items()).To(HaveLen(2))
+
+			updater := NewKustomizationUpdater()
+			g.Expect(updater.ApplyTransformers(images)).To(Succeed())
+			if tc.expectNginx != nil {
+				g.Expect(images.Items()[0].Ref(true)).To(BeElementOf("nginx:1.26", "example.org/nginx:1.25-alpine"))
+			}
+
+			for i := range images.Items() {
+				image := &images.Items()[i]
+				image.Digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+				image.NewName = "example.com/app"
+				image.NewTag = types.AppImageTagPrefix + image.OriginalName
+			}
+
+			err = updater.Update(images)
+			if tc.expectErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			dir, _ := loader.RelPaths()
+			g.Expect(os.ReadFile(filepath.Join(dir, "deployment.yaml"))).To(Equal(deployment))
+
+			data, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+			g.Expect(err).NotTo(HaveOccurred())
+			kustomization := struct {
+				Images []map[string]string `json:"images"`
+			}{}
+			g.Expect(yaml.Unmarshal(data, &kustomization)).To(Succeed())
+			expectNginx := tc.expectNginx
+			if expectNginx == nil {
+				expectNginx = map[string]string{
+					"name":    "nginx",
+					"newName": "example.com/app",
+					"newTag":  "app.nginx",
+					"digest":  "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				}
+			}
+			g.Expect(kustomization.Images).To(ConsistOf(
+				expectNginx,
+				map[string]string{
+					"name":    "busybox",
+					"newName": "example.com/app",
+					"newTag":  "app.busybox",
+					"digest":  "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				},
+			))
+
+			g.Expect(updater.Mutations()).To(HaveLen(1))
+
+			transformed := updater.TransformedImages(images)
+			g.Expect(transformed.Items()[0].Ref(true)).To(HavePrefix("example.com/app:app."))
+			for key := range updater.Mutations() {
+				for _, image := range transformed.Items() {
+					source := image.Sources[len(image.Sources)-1]
+					g.Expect(source.Manifest).To(Equal("kustomization.yaml"))
+					g.Expect(source.ManifestDigest).To(Equal(key.Digest))
+					g.Expect(source.Line).To(BeNumerically(">", 2))
+				}
+			}
+		})
+	}
+}
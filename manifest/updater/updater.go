@@ -4,11 +4,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"hash"
-
-	"sigs.k8s.io/kustomize/api/filters/imagetag"
-	kustomize "sigs.k8s.io/kustomize/api/types"
-	"sigs.k8s.io/kustomize/kyaml/kio"
-	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"os"
 
 	"github.com/errordeveloper/tape/attest/digest"
 	attestTypes "github.com/errordeveloper/tape/attest/types"
@@ -36,6 +32,12 @@ type FileUpdater struct {
 func (u *FileUpdater) Update(images *manifestTypes.ImageList) error {
 	groups := images.GroupByManifest()
 	for manifestPath := range groups {
+		if items := groups[manifestPath].Items(); items[0].Sources[0].ValueFields != nil {
+			if err := u.doUpdateValues(manifestPath, items); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := u.doUpdate(manifestPath, groups[manifestPath].Items()); err != nil {
 			return err
 		}
@@ -48,61 +50,69 @@ func (u *FileUpdater) doUpdate(manifestPath string, images []types.Image) error
 		return fmt.Errorf("no images to update")
 	}
 
-	u.hash.Reset()
-
-	pipeline := kio.Pipeline{
-		Inputs: []kio.Reader{
-			kio.LocalPackageReader{
-				PackagePath: manifestPath,
-			},
-		},
-		Filters: make([]kio.Filter, len(images)),
-		Outputs: []kio.Writer{
-			kio.LocalPackageWriter{
-				PackagePath: manifestPath,
-			},
-			kio.ByteWriter{
-				Writer:                u.hash,
-				KeepReaderAnnotations: false,
-				ClearAnnotations: []string{
-					kioutil.PathAnnotation,
-					kioutil.LegacyPathAnnotation,
-				},
-			},
-		},
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
 	}
 
+	// only image scalars are rewritten, so the rest of the file remains as it was
+	editor := newEditor(data)
 	for i := range images {
-		pipeline.Filters[i] = imagetag.Filter{
-			ImageTag: kustomize.Image{
-				Name:    images[i].OriginalName,
-				NewName: images[i].NewName,
-				// NB: docs say NewTag is ignored when digest is set, but it's not true
-				NewTag: images[i].NewTag,
-				Digest: images[i].Digest,
-			},
-			// this is not optimal, however `(*yaml.RNode).FieldPath()` only returns a flat slice
-			// where `contianers[]` is presented as `containers` for some reason; but having
-			// a full list of search paths here shouldn't affect performance too much as it's only
-			// a short list
-			FsSlice: types.ImagePaths(),
+		source := images[i].Sources[0]
+		if err := editor.replaceScalar(source.Line, source.Column, source.OriginalRef, newRef(images[i])); err != nil {
+			return fmt.Errorf("unable to update %q: %w", source.Manifest, err)
 		}
 	}
 
-	if err := pipeline.Execute(); err != nil {
+	return u.writeFile(manifestPath, editor, images[0])
+}
+
+func (u *FileUpdater) writeFile(manifestPath string, editor *editor, image types.Image) error {
+	data, err := editor.apply()
+	if err != nil {
+		return fmt.Errorf("unable to update %q: %w", image.Manifest(), err)
+	}
+	info, err := os.Stat(manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, data, info.Mode()); err != nil {
 		return err
 	}
 
+	u.hash.Reset()
+	if _, err := u.hash.Write(data); err != nil {
+		return err
+	}
 	key := attestTypes.PathCheckerRegistryKey{
-		Path:   images[0].Manifest(),
+		Path:   image.Manifest(),
 		Digest: digest.MakeSHA256(u.hash),
 	}
 	if _, ok := u.mutations[key]; ok {
 		return fmt.Errorf("mutations with key %#v is already registered", key)
 	}
-	u.mutations[key] = images[0].ManifestDigest()
+	u.mutations[key] = image.ManifestDigest()
 
 	return nil
 }
 
+// newRef returns reference in the same way as images transformer of kustomize,
+// i.e. name and tag are only replaced when set, and digest replaces original tag
+func newRef(image types.Image) string {
+	ref := image.NewName
+	if ref == "" {
+		ref = image.OriginalName
+	}
+	switch {
+	case image.NewTag != "":
+		ref += ":" + image.NewTag
+	case image.Digest == "" && image.OriginalTag != "":
+		ref += ":" + image.OriginalTag
+	}
+	if image.Digest != "" {
+		ref += "@" + image.Digest
+	}
+	return ref
+}
+
 func (u *FileUpdater) Mutations() attestTypes.Mutations { return u.mutations }
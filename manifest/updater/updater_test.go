@@ -3,6 +3,9 @@ package updater_test
 import (
 	"context"
 	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -122,3 +125,80 @@ func makeUpdaterTest(tc testdata.TestCase) func(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdaterPreservesFormatting(t *testing.T) {
+	g := NewWithT(t)
+
+	manifests := map[string]string{
+		"deployment.yaml": `# comments and quoting are preserved
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app # name
+spec:
+  template:
+    spec:
+      containers:
+      - name:   app
+        image: "nginx:1.25"    # pinned
+      - {name: sidecar, image: busybox:1.36}
+---
+apiVersion: v1
+kind: Pod
+metadata: {name: job}
+spec:
+  containers:
+  - name: job
+    image: 'alpine:3.18'
+`,
+		"pod.json": `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": "app"},
+  "spec": {
+    "containers": [
+      {"name": "app", "image": "nginx:1.25"}
+    ]
+  }
+}
+`,
+	}
+
+	tempDir := t.TempDir()
+	for name, data := range manifests {
+		g.Expect(os.WriteFile(filepath.Join(tempDir, name), []byte(data), 0o644)).To(Succeed())
+	}
+
+	workDir, err := os.Getwd()
+	g.Expect(err).NotTo(HaveOccurred())
+	loadPath, err := filepath.Rel(workDir, tempDir)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	loader := loader.NewRecursiveManifestDirectoryLoader(loadPath)
+	g.Expect(loader.Load()).To(Succeed())
+	defer loader.Cleanup()
+
+	scanner := imagescanner.NewDefaultImageScanner()
+	g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+	images := scanner.GetImages()
+	g.Expect(images.Items()).To(HaveLen(4))
+	for i := range images.Items() {
+		image := &images.Items()[i]
+		image.Digest = testDigest
+		image.NewName = "example.com/app"
+		image.NewTag = types.AppImageTagPrefix + image.OriginalName
+	}
+
+	updater := NewFileUpdater()
+	g.Expect(updater.Update(images)).To(Succeed())
+	g.Expect(updater.Mutations()).To(HaveLen(2))
+
+	dir, _ := loader.RelPaths()
+	for name, data := range manifests {
+		for _, image := range []string{"nginx:1.25", "busybox:1.36", "alpine:3.18"} {
+			imageName, _, _ := strings.Cut(image, ":")
+			data = strings.ReplaceAll(data, image, "example.com/app:app."+imageName+"@"+testDigest)
+		}
+		g.Expect(os.ReadFile(filepath.Join(dir, name))).To(Equal([]byte(data)), name)
+	}
+}
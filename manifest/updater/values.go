@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/errordeveloper/tape/manifest/types"
+)
+
+// doUpdateValues pins images in a Helm values file, references that are split
+// across fields of a map are updated field by field, and when there is no digest
+// field the digest is appended to the tag
+func (u *FileUpdater) doUpdateValues(manifestPath string, images []types.Image) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	values, err := yaml.Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse values: %w", err)
+	}
+
+	editor := newEditor(data)
+	for i := range images {
+		source := images[i].Sources[0]
+		node, err := lookupValue(values, source.NodePath)
+		if err != nil {
+			return fmt.Errorf("unable to update %q: %w", source.Manifest, err)
+		}
+		if err := setValues(editor, node, images[i], source.ValueFields); err != nil {
+			return fmt.Errorf("unable to update %q at %s: %w", source.Manifest, strings.Join(source.NodePath, "."), err)
+		}
+	}
+
+	return u.writeFile(manifestPath, editor, images[0])
+}
+
+func setValues(editor *editor, node *yaml.RNode, image types.Image, fields *types.ValueFields) error {
+	if fields.Repository == "" {
+		return setScalar(editor, node, newRef(image))
+	}
+
+	repository := image.NewName
+	if fields.Registry != "" {
+		ref, err := name.NewRepository(image.NewName)
+		if err != nil {
+			return err
+		}
+		if err := setField(editor, node, fields.Registry, ref.RegistryStr(), fields.Repository); err != nil {
+			return err
+		}
+		repository = ref.RepositoryStr()
+	}
+	if err := setField(editor, node, fields.Repository, repository, fields.Repository); err != nil {
+		return err
+	}
+	if fields.Digest != "" {
+		if err := setField(editor, node, fields.Digest, image.Digest, fields.Repository); err != nil {
+			return err
+		}
+		return setField(editor, node, fields.Tag, image.NewTag, fields.Repository)
+	}
+	return setField(editor, node, fields.Tag, image.NewTag+"@"+image.Digest, fields.Repository)
+}
+
+func setScalar(editor *editor, node *yaml.RNode, value string) error {
+	if node.YNode().Kind != yaml.ScalarNode {
+		return fmt.Errorf("unexpected non-scalar value at %d:%d", node.YNode().Line, node.YNode().Column)
+	}
+	return editor.replaceScalar(node.YNode().Line, node.YNode().Column, node.YNode().Value, value)
+}
+
+// setField updates value of a field, or adds the field after the field
+// that precedes it when it's missing
+func setField(editor *editor, node *yaml.RNode, key, value, after string) error {
+	if field := node.Field(key); field != nil {
+		return setScalar(editor, field.Value, value)
+	}
+	if node.YNode().Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("unable to add %q field to a flow mapping", key)
+	}
+	field := node.Field(after)
+	if field == nil {
+		return fmt.Errorf("unable to add %q field, as %q field is missing", key, after)
+	}
+	indent := strings.Repeat(" ", field.Key.YNode().Column-1)
+	editor.insertLine(field.Value.YNode().Line, indent+key+": "+formatPlain(value))
+	return nil
+}
+
+// lookupValue finds a node by path recorded by the scanner, where
+// elements of a list are addressed as `[<index>]`
+func lookupValue(node *yaml.RNode, path []string) (*yaml.RNode, error) {
+	for _, key := range path {
+		if strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]") {
+			index, err := strconv.Atoi(key[1 : len(key)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path element %q: %w", key, err)
+			}
+			elements, err := node.Elements()
+			if err != nil {
+				return nil, err
+			}
+			if index >= len(elements) {
+				return nil, fmt.Errorf("path element %q is out of range", key)
+			}
+			node = elements[index]
+			continue
+		}
+		field := node.Field(key)
+		if field == nil {
+			return nil, fmt.Errorf("path element %q not found", key)
+		}
+		node = field.Value
+	}
+	return node, nil
+}
@@ -0,0 +1,163 @@
+package updater_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	"github.com/errordeveloper/tape/manifest/imagescanner"
+	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/manifest/types"
+	. "github.com/errordeveloper/tape/manifest/updater"
+)
+
+const testDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+const testValues = `image:
+  repository: nginx
+  pullPolicy: IfNotPresent
+sidecars:
+- name: busybox
+  image: busybox:1.36
+controller:
+  image:
+    registry: quay.io
+    repository: example/controller
+    tag: v1.0.0
+    digest: ""
+metrics:
+  container:
+    repo: example/metrics
+    version: v2.0.0
+`
+
+func TestValuesUpdater(t *testing.T) {
+	testCases := []struct {
+		description    string
+		paths          []string
+		expectedImages []string
+		expectedValues map[string]interface{}
+	}{
+		{
+			description: "default paths",
+			paths:       []string{"**.image"},
+			expectedImages: []string{
+				"nginx:1.25",
+				"busybox:1.36",
+				"quay.io/example/controller:v1.0.0",
+			},
+			expectedValues: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "example.com/app",
+					"tag":        "app.nginx@" + testDigest,
+					"pullPolicy": "IfNotPresent",
+				},
+				"sidecars": []interface{}{map[string]interface{}{
+					"name":  "busybox",
+					"image": "example.com/app:app.busybox@" + testDigest,
+				}},
+				"controller": map[string]interface{}{
+					"image": map[string]interface{}{
+						"registry":   "example.com",
+						"repository": "app",
+						"tag":        "app.controller",
+						"digest":     testDigest,
+					},
+				},
+				"metrics": map[string]interface{}{
+					"container": map[string]interface{}{
+						"repo":    "example/metrics",
+						"version": "v2.0.0",
+					},
+				},
+			},
+		},
+		{
+			description:    "custom field names",
+			paths:          []string{"metrics.container:repository=repo,tag=version"},
+			expectedImages: []string{"example/metrics:v2.0.0"},
+			expectedValues: map[string]interface{}{
+				"image": map[string]interface{}{
+					"repository": "nginx",
+					"pullPolicy": "IfNotPresent",
+				},
+				"sidecars": []interface{}{map[string]interface{}{
+					"name":  "busybox",
+					"image": "busybox:1.36",
+				}},
+				"controller": map[string]interface{}{
+					"image": map[string]interface{}{
+						"registry":   "quay.io",
+						"repository": "example/controller",
+						"tag":        "v1.0.0",
+						"digest":     "",
+					},
+				},
+				"metrics": map[string]interface{}{
+					"container": map[string]interface{}{
+						"repo":    "example.com/app",
+						"version": "app.metrics@" + testDigest,
+					},
+				},
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tempDir := t.TempDir()
+			g.Expect(os.WriteFile(filepath.Join(tempDir, "Chart.yaml"), []byte("name: app\nappVersion: \"1.25\"\n"), 0o644)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(tempDir, "values.yaml"), []byte(testValues), 0o644)).To(Succeed())
+
+			workDir, err := os.Getwd()
+			g.Expect(err).NotTo(HaveOccurred())
+			loadPath, err := filepath.Rel(workDir, tempDir)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			loader := loader.NewRecursiveDirectoryLoader(loadPath, loader.ContentKindHelmChart)
+			g.Expect(loader.Load()).To(Succeed())
+			defer loader.Cleanup()
+
+			paths := []imagescanner.ValuesImagePath{}
+			for _, path := range tc.paths {
+				valuesImagePath, err := imagescanner.ParseValuesImagePath(path)
+				g.Expect(err).NotTo(HaveOccurred())
+				paths = append(paths, valuesImagePath)
+			}
+
+			scanner := imagescanner.NewDefaultImageScanner()
+			scanner.WithHelmValues(paths...)
+			g.Expect(scanner.Scan(loader.RelPaths())).To(Succeed())
+			images := scanner.GetImages()
+
+			refs := []string{}
+			for i := range images.Items() {
+				image := &images.Items()[i]
+				g.Expect(image.Manifest()).To(Equal("values.yaml"))
+				g.Expect(image.Sources[0].ValueFields).NotTo(BeNil())
+				refs = append(refs, image.Ref(true))
+				image.Digest = testDigest
+				image.NewName = "example.com/app"
+				image.NewTag = types.AppImageTagPrefix + filepath.Base(image.OriginalName)
+			}
+			g.Expect(refs).To(ConsistOf(tc.expectedImages))
+
+			updater := NewFileUpdater()
+			g.Expect(updater.Update(images)).To(Succeed())
+			g.Expect(updater.Mutations()).To(HaveLen(1))
+
+			dir, _ := loader.RelPaths()
+			data, err := os.ReadFile(filepath.Join(dir, "values.yaml"))
+			g.Expect(err).NotTo(HaveOccurred())
+			values := map[string]interface{}{}
+			g.Expect(yaml.Unmarshal(data, &values)).To(Succeed())
+			g.Expect(values).To(Equal(tc.expectedValues))
+		})
+	}
+}
@@ -0,0 +1,570 @@
+package validator
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/openapi/kubernetesapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/errordeveloper/tape/attest/digest"
+	"github.com/errordeveloper/tape/manifest/documents"
+)
+
+const (
+	// SchemaKubernetes is set for resources validated against bundled schemas
+	SchemaKubernetes = "kubernetes"
+	// SchemaCRD is set for resources validated against schemas of custom resource definitions
+	SchemaCRD = "crd"
+	// SchemaCustom is set for resources validated against schema file given by the user
+	SchemaCustom = "custom"
+
+	quantityRefSuffix = "resource.Quantity"
+
+	gvkExtensionKey = "x-kubernetes-group-version-kind"
+)
+
+// KubernetesVersions returns versions of bundled Kubernetes OpenAPI schemas, only
+// older versions are bundled, so a schema file can be used for newer versions
+func KubernetesVersions() []string {
+	versions := make([]string, 0, len(kubernetesapi.OpenAPIMustAsset))
+	for version := range kubernetesapi.OpenAPIMustAsset {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+func DefaultKubernetesVersion() string { return kubernetesapi.DefaultOpenAPI }
+
+// Problem is an issue found in a manifest
+type Problem struct {
+	Manifest string `json:"manifest"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) Error() string {
+	location := fmt.Sprintf("%s:%d:%d", p.Manifest, p.Line, p.Column)
+	if p.Path != "" {
+		return fmt.Sprintf("%s: %s: %s", location, p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, p.Message)
+}
+
+// Problems is returned by Validate when any problems were found, it's
+// also used for warnings about fields that are not in the schema
+type Problems []Problem
+
+func (p Problems) Error() string {
+	messages := make([]string, len(p))
+	for i := range p {
+		messages[i] = p[i].Error()
+	}
+	return fmt.Sprintf("%d validation problem(s) found:\n%s", len(p), strings.Join(messages, "\n"))
+}
+
+// Resource is a resource that was checked, Schema is empty
+// when there was no schema for it
+type Resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Schema     string `json:"schema,omitempty"`
+}
+
+// Result contains all resources found in a manifest
+type Result struct {
+	Manifest       string
+	ManifestDigest digest.SHA256
+	Resources      []Resource
+}
+
+// Validator holds its own copy of the Kubernetes schema, as kyaml schema is global
+// and it's used by kustomize as well, references are resolved against root schema
+type Validator struct {
+	kubernetesVersion   string
+	kubernetesSchema    string
+	rejectUnknownFields bool
+	rootSchema          *spec.Schema
+	resourceSchemas     map[yaml.TypeMeta]*spec.Schema
+	crdSchemas          map[yaml.TypeMeta]*spec.Schema
+	hash                hash.Hash
+	results             []Result
+	warnings            Problems
+}
+
+func NewValidator(kubernetesVersion string) (*Validator, error) {
+	if kubernetesVersion == "" {
+		kubernetesVersion = DefaultKubernetesVersion()
+	}
+	if !slices.Contains(KubernetesVersions(), kubernetesVersion) {
+		return nil, fmt.Errorf("unsupported Kubernetes version %q, supported versions: %s",
+			kubernetesVersion, strings.Join(KubernetesVersions(), ", "))
+	}
+	// bundled schemas can only be parsed by setting global schema, definitions
+	// are copied and default schema is restored, as kustomize relies on it
+	openapi.ResetOpenAPI()
+	defer openapi.ResetOpenAPI()
+	if err := openapi.SetSchema(map[string]string{"version": kubernetesVersion}, nil, true); err != nil {
+		return nil, err
+	}
+	return newValidator(kubernetesVersion, SchemaKubernetes, openapi.Schema().Definitions), nil
+}
+
+func newValidator(kubernetesVersion, kubernetesSchema string, definitions spec.Definitions) *Validator {
+	v := &Validator{
+		kubernetesVersion: kubernetesVersion,
+		kubernetesSchema:  kubernetesSchema,
+		rootSchema:        &spec.Schema{SchemaProps: spec.SchemaProps{Definitions: definitions}},
+		resourceSchemas:   map[yaml.TypeMeta]*spec.Schema{},
+		crdSchemas:        map[yaml.TypeMeta]*spec.Schema{},
+		hash:              sha256.New(),
+	}
+	for name := range definitions {
+		schema := definitions[name]
+		for _, typeMeta := range typeMetaOf(schema.Extensions) {
+			v.resourceSchemas[typeMeta] = &schema
+		}
+	}
+	return v
+}
+
+// typeMetaOf returns types that a definition is the schema of, definitions
+// of fields of resources have none
+func typeMetaOf(extensions spec.Extensions) []yaml.TypeMeta {
+	gvks, ok := extensions[gvkExtensionKey].([]interface{})
+	if !ok {
+		return nil
+	}
+	typeMetas := []yaml.TypeMeta{}
+	for i := range gvks {
+		gvk, ok := gvks[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _ := gvk["group"].(string)
+		version, _ := gvk["version"].(string)
+		kind, _ := gvk["kind"].(string)
+		if version == "" || kind == "" {
+			continue
+		}
+		apiVersion := version
+		if group != "" {
+			apiVersion = group + "/" + version
+		}
+		typeMetas = append(typeMetas, yaml.TypeMeta{APIVersion: apiVersion, Kind: kind})
+	}
+	return typeMetas
+}
+
+// NewValidatorWithSchemaFile uses Kubernetes OpenAPI v2 schema from a file (e.g. swagger.json
+// from Kubernetes repo) instead of bundled schemas, version is taken from the schema info
+func NewValidatorWithSchemaFile(path string) (*Validator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema file: %w", err)
+	}
+	data, err = sigsyaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse schema file %q: %w", path, err)
+	}
+	swagger := &spec.Swagger{}
+	if err := swagger.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("unable to parse schema file %q: %w", path, err)
+	}
+	if len(swagger.Definitions) == 0 {
+		return nil, fmt.Errorf("schema file %q has no definitions", path)
+	}
+	kubernetesVersion := SchemaCustom
+	if swagger.Info != nil && swagger.Info.Version != "" {
+		kubernetesVersion = swagger.Info.Version
+	}
+	return newValidator(kubernetesVersion, SchemaCustom, swagger.Definitions), nil
+}
+
+func (v *Validator) KubernetesVersion() string { return v.kubernetesVersion }
+
+// SetRejectUnknownFields makes fields that are not in the schema a problem, by default
+// these are only warnings, as schemas may be older than the cluster manifests target
+func (v *Validator) SetRejectUnknownFields(reject bool) { v.rejectUnknownFields = reject }
+
+func (v *Validator) Results() []Result { return v.results }
+
+// Warnings returns unknown fields found by Validate, unless these are rejected
+func (v *Validator) Warnings() Problems { return v.warnings }
+
+// AddCRDSchemasFromFile loads schemas from custom resource definitions in a file
+func (v *Validator) AddCRDSchemasFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	nodes, err := documents.Decode(data)
+	if err != nil {
+		return fmt.Errorf("unable to parse %q: %w", path, err)
+	}
+	for i := range nodes {
+		if err := v.addCRDSchema(nodes[i]); err != nil {
+			return fmt.Errorf("unable to load schema from %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (v *Validator) addCRDSchema(node *yaml.RNode) error {
+	if node.GetKind() != "CustomResourceDefinition" {
+		return nil
+	}
+	group, err := node.GetString("spec.group")
+	if err != nil {
+		return err
+	}
+	kind, err := node.GetString("spec.names.kind")
+	if err != nil {
+		return err
+	}
+	versions, err := node.Pipe(yaml.Lookup("spec", "versions"))
+	if err != nil || versions == nil {
+		return err
+	}
+	elements, err := versions.Elements()
+	if err != nil {
+		return err
+	}
+	for _, version := range elements {
+		name, err := version.GetString("name")
+		if err != nil {
+			return err
+		}
+		// v1beta1 has a single schema for all versions
+		schemaNode, err := version.Pipe(yaml.Lookup("schema", "openAPIV3Schema"))
+		if err != nil {
+			return err
+		}
+		if schemaNode == nil {
+			if schemaNode, err = node.Pipe(yaml.Lookup("spec", "validation", "openAPIV3Schema")); err != nil {
+				return err
+			}
+		}
+		if schemaNode == nil {
+			continue
+		}
+		data, err := schemaNode.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		schema := &spec.Schema{}
+		if err := json.Unmarshal(data, schema); err != nil {
+			return fmt.Errorf("invalid schema of %s in %s/%s: %w", kind, group, name, err)
+		}
+		// the API server always accepts these fields, CRD schemas often omit them
+		if len(schema.Properties) != 0 {
+			for _, field := range []string{"apiVersion", "kind", "metadata"} {
+				if _, ok := schema.Properties[field]; !ok {
+					schema.Properties[field] = spec.Schema{}
+				}
+			}
+		}
+		v.crdSchemas[yaml.TypeMeta{APIVersion: group + "/" + name, Kind: kind}] = schema
+	}
+	return nil
+}
+
+// Validate checks all documents in given manifests, custom resource definitions
+// found in the manifests are used for validating custom resources
+func (v *Validator) Validate(dir string, manifests []string) error {
+	manifests = slices.DeleteFunc(slices.Clone(manifests), isKustomization)
+	slices.Sort(manifests)
+
+	manifestNodes := make([][]*yaml.RNode, len(manifests))
+	problems := Problems{}
+	v.results = make([]Result, len(manifests))
+	v.warnings = Problems{}
+	for i := range manifests {
+		data, err := os.ReadFile(filepath.Join(dir, manifests[i]))
+		if err != nil {
+			return err
+		}
+		v.hash.Reset()
+		v.hash.Write(data)
+		v.results[i] = Result{
+			Manifest:       manifests[i],
+			ManifestDigest: digest.MakeSHA256(v.hash),
+			Resources:      []Resource{},
+		}
+
+		nodes, err := documents.Decode(data)
+		if err != nil {
+			problems = append(problems, parseProblem(manifests[i], err))
+			continue
+		}
+		manifestNodes[i] = nodes
+		for _, node := range nodes {
+			if err := v.addCRDSchema(node); err != nil {
+				problems = append(problems, Problem{
+					Manifest: manifests[i],
+					Line:     node.YNode().Line,
+					Column:   node.YNode().Column,
+					Message:  err.Error(),
+				})
+			}
+		}
+	}
+
+	for i := range manifestNodes {
+		for _, node := range manifestNodes[i] {
+			resource, found := v.validateResource(manifests[i], node.YNode(), &problems)
+			if found {
+				v.results[i].Resources = append(v.results[i].Resources, resource)
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		return problems
+	}
+	return nil
+}
+
+func (v *Validator) validateResource(manifest string, node *yaml.Node, problems *Problems) (Resource, bool) {
+	makeAddProblem := func(problems *Problems) func(*yaml.Node, string, string) {
+		return func(node *yaml.Node, path, message string) {
+			*problems = append(*problems, Problem{
+				Manifest: manifest,
+				Line:     node.Line,
+				Column:   node.Column,
+				Path:     path,
+				Message:  message,
+			})
+		}
+	}
+	addProblem := makeAddProblem(problems)
+	addUnknownField := makeAddProblem(&v.warnings)
+	if v.rejectUnknownFields {
+		addUnknownField = addProblem
+	}
+
+	if node.Kind != yaml.MappingNode {
+		addProblem(node, "", "document is not an object")
+		return Resource{}, false
+	}
+
+	resource := Resource{
+		Line:   node.Line,
+		Column: node.Column,
+	}
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"apiVersion", &resource.APIVersion},
+		{"kind", &resource.Kind},
+	} {
+		value := mappingValue(node, field.key)
+		if value == nil || value.Kind != yaml.ScalarNode || value.Value == "" {
+			addProblem(node, field.key, "missing required field")
+			continue
+		}
+		*field.value = value.Value
+	}
+	metadata := mappingValue(node, "metadata")
+	if metadata == nil || metadata.Kind != yaml.MappingNode {
+		addProblem(node, "metadata", "missing required field")
+	} else {
+		name := mappingValue(metadata, "name")
+		if name == nil {
+			name = mappingValue(metadata, "generateName")
+		}
+		if name == nil || name.Value == "" {
+			addProblem(metadata, "metadata.name", "missing required field")
+		} else {
+			resource.Name = name.Value
+		}
+	}
+	if resource.APIVersion == "" || resource.Kind == "" {
+		return resource, true
+	}
+
+	typeMeta := yaml.TypeMeta{APIVersion: resource.APIVersion, Kind: resource.Kind}
+	var schema *spec.Schema
+	if s, ok := v.crdSchemas[typeMeta]; ok {
+		schema, resource.Schema = s, SchemaCRD
+	} else if s, ok := v.resourceSchemas[typeMeta]; ok {
+		schema, resource.Schema = s, v.kubernetesSchema
+	}
+	if schema == nil {
+		return resource, true
+	}
+
+	(&schemaValidator{rootSchema: v.rootSchema, addProblem: addProblem, addUnknownField: addUnknownField}).validate(node, schema, "")
+	return resource, true
+}
+
+type schemaValidator struct {
+	rootSchema      *spec.Schema
+	addProblem      func(node *yaml.Node, path, message string)
+	addUnknownField func(node *yaml.Node, path, message string)
+}
+
+func (v *schemaValidator) validate(node *yaml.Node, schema *spec.Schema, path string) {
+	isQuantity := false
+	for schema.Ref.String() != "" {
+		isQuantity = strings.HasSuffix(schema.Ref.String(), quantityRefSuffix)
+		resolved, err := openapi.Resolve(&schema.Ref, v.rootSchema)
+		if err != nil || resolved == nil {
+			return
+		}
+		schema = resolved
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == yaml.NodeTagNull {
+		// null is the same as field being omitted
+		return
+	}
+	if isIntOrString(schema) {
+		if node.Kind != yaml.ScalarNode || (node.Tag != yaml.NodeTagString && node.Tag != yaml.NodeTagInt) {
+			v.addProblem(node, path, "expected integer or string")
+		}
+		return
+	}
+
+	schemaType := ""
+	if len(schema.Type) != 0 {
+		schemaType = schema.Type[0]
+	} else if len(schema.Properties) != 0 {
+		schemaType = "object"
+	}
+
+	switch schemaType {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			v.addProblem(node, path, "expected object")
+			return
+		}
+		v.validateObject(node, schema, path)
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			v.addProblem(node, path, "expected array")
+			return
+		}
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return
+		}
+		for i := range node.Content {
+			v.validate(node.Content[i], schema.Items.Schema, path+"["+strconv.Itoa(i)+"]")
+		}
+	case "string":
+		switch {
+		case node.Kind == yaml.ScalarNode && (node.Tag == yaml.NodeTagString || node.Tag == "!!timestamp"):
+		case node.Kind == yaml.ScalarNode && isQuantity && (node.Tag == yaml.NodeTagInt || node.Tag == yaml.NodeTagFloat):
+		default:
+			v.addProblem(node, path, "expected string")
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.Tag != yaml.NodeTagInt {
+			v.addProblem(node, path, "expected integer")
+		}
+	case "number":
+		if node.Kind != yaml.ScalarNode || (node.Tag != yaml.NodeTagInt && node.Tag != yaml.NodeTagFloat) {
+			v.addProblem(node, path, "expected number")
+		}
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.Tag != yaml.NodeTagBool {
+			v.addProblem(node, path, "expected boolean")
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(node *yaml.Node, schema *spec.Schema, path string) {
+	preserveUnknown := isTrue(schema.Extensions, "x-kubernetes-preserve-unknown-fields")
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fieldPath := key.Value
+		if path != "" {
+			fieldPath = path + "." + key.Value
+		}
+		if fieldSchema, ok := schema.Properties[key.Value]; ok {
+			v.validate(value, &fieldSchema, fieldPath)
+			continue
+		}
+		if schema.AdditionalProperties != nil {
+			if schema.AdditionalProperties.Schema != nil {
+				v.validate(value, schema.AdditionalProperties.Schema, fieldPath)
+			}
+			continue
+		}
+		if preserveUnknown || len(schema.Properties) == 0 {
+			continue
+		}
+		v.addUnknownField(key, fieldPath, "unknown field")
+	}
+	for _, required := range schema.Required {
+		if mappingValue(node, required) == nil {
+			fieldPath := required
+			if path != "" {
+				fieldPath = path + "." + required
+			}
+			v.addProblem(node, fieldPath, "missing required field")
+		}
+	}
+}
+
+// isKustomization checks if path is a kustomization file, which is
+// not a resource and won't be validated
+func isKustomization(path string) bool {
+	switch filepath.Base(path) {
+	case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		return true
+	}
+	return false
+}
+
+func isIntOrString(schema *spec.Schema) bool {
+	return schema.Format == "int-or-string" || isTrue(schema.Extensions, "x-kubernetes-int-or-string")
+}
+
+func isTrue(extensions spec.Extensions, key string) bool {
+	value, ok := extensions.GetBool(key)
+	return ok && value
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// parseProblem extracts line from errors like "yaml: line 3: mapping values are not allowed in this context"
+func parseProblem(manifest string, err error) Problem {
+	problem := Problem{Manifest: manifest, Message: err.Error()}
+	if _, rest, ok := strings.Cut(err.Error(), "line "); ok {
+		if n, _, ok := strings.Cut(rest, ":"); ok {
+			if line, err := strconv.Atoi(n); err == nil {
+				problem.Line = line
+				problem.Message = strings.TrimSpace(strings.TrimPrefix(rest, n+":"))
+			}
+		}
+	}
+	return problem
+}
@@ -0,0 +1,246 @@
+package validator_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	. "github.com/errordeveloper/tape/manifest/validator"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels: {app: app}
+  template:
+    metadata:
+      labels: {app: app}
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.25
+        ports:
+        - containerPort: 80
+        resources:
+          limits: {cpu: 1, memory: 128Mi}
+        readinessProbe:
+          httpGet: {port: http}
+`
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names: {kind: Widget, plural: widgets}
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [size]
+            properties:
+              size: {type: integer}
+`
+
+func TestValidator(t *testing.T) {
+	testCases := []struct {
+		description         string
+		manifests           map[string]string
+		rejectUnknownFields bool
+		expectedProblems    []string
+		expectedWarnings    []string
+		expectedResources   int
+		expectedSchemas     []string
+	}{
+		{
+			description:       "valid deployment",
+			manifests:         map[string]string{"deployment.yaml": testDeployment},
+			expectedResources: 1,
+			expectedSchemas:   []string{SchemaKubernetes},
+		},
+		{
+			description: "invalid fields",
+			manifests: map[string]string{
+				"deployment.yaml": testDeployment + "  replicas: \"3\"\n  paused: yes\n  strategy: {typo: 1}\n",
+			},
+			rejectUnknownFields: true,
+			expectedProblems: []string{
+				"deployment.yaml:21:13: spec.replicas: expected integer",
+				"deployment.yaml:22:11: spec.paused: expected boolean",
+				"deployment.yaml:23:14: spec.strategy.typo: unknown field",
+			},
+		},
+		{
+			description: "unknown fields are warnings",
+			manifests: map[string]string{
+				"deployment.yaml": strings.Replace(testDeployment, "      containers:", "      os: {name: linux}\n      containers:", 1),
+			},
+			expectedWarnings: []string{
+				"deployment.yaml:12:7: spec.template.spec.os: unknown field",
+			},
+			expectedResources: 1,
+			expectedSchemas:   []string{SchemaKubernetes},
+		},
+		{
+			description: "missing metadata and positions in later documents",
+			manifests: map[string]string{
+				"service.yaml": "apiVersion: v1\nkind: Namespace\nmetadata: {name: test}\n---\napiVersion: v1\nkind: Service\nmetadata: {}\n",
+			},
+			expectedProblems: []string{
+				"service.yaml:7:11: metadata.name: missing required field",
+			},
+		},
+		{
+			description: "custom resources",
+			manifests: map[string]string{
+				"crd.yaml":    testCRD,
+				"widget.yaml": "apiVersion: example.com/v1\nkind: Widget\nmetadata: {name: a}\nspec: {size: 1}\n---\napiVersion: example.com/v1\nkind: Widget\nmetadata: {name: b}\nspec: {}\n",
+			},
+			expectedProblems: []string{
+				"widget.yaml:9:7: spec.size: missing required field",
+			},
+		},
+		{
+			description: "resources without schema",
+			manifests: map[string]string{
+				"gadget.yaml": "apiVersion: example.com/v1\nkind: Gadget\nmetadata: {name: a}\nspec: {anything: true}\n",
+			},
+			expectedResources: 1,
+			expectedSchemas:   []string{""},
+		},
+		{
+			description: "invalid YAML",
+			manifests: map[string]string{
+				"broken.yaml": "apiVersion: v1\nkind: ConfigMap\n  metadata: {name: a}\n",
+			},
+			expectedProblems: []string{
+				"broken.yaml:3:0: mapping values are not allowed in this context",
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			dir := t.TempDir()
+			manifests := []string{}
+			for name, data := range tc.manifests {
+				g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644)).To(Succeed())
+				manifests = append(manifests, name)
+			}
+
+			validator, err := NewValidator("")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(validator.KubernetesVersion()).To(Equal(DefaultKubernetesVersion()))
+			validator.SetRejectUnknownFields(tc.rejectUnknownFields)
+
+			err = validator.Validate(dir, manifests)
+			if len(tc.expectedProblems) != 0 {
+				g.Expect(err).To(HaveOccurred())
+				problems, ok := err.(Problems)
+				g.Expect(ok).To(BeTrue())
+				messages := []string{}
+				for _, problem := range problems {
+					messages = append(messages, problem.Error())
+				}
+				g.Expect(messages).To(ConsistOf(tc.expectedProblems))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			warnings := []string{}
+			for _, warning := range validator.Warnings() {
+				warnings = append(warnings, warning.Error())
+			}
+			g.Expect(warnings).To(ConsistOf(tc.expectedWarnings))
+
+			schemas := []string{}
+			for _, result := range validator.Results() {
+				g.Expect(result.ManifestDigest).NotTo(BeEmpty())
+				for _, resource := range result.Resources {
+					schemas = append(schemas, resource.Schema)
+				}
+			}
+			g.Expect(schemas).To(HaveLen(tc.expectedResources))
+			g.Expect(schemas).To(Equal(tc.expectedSchemas))
+		})
+	}
+
+	_, err := NewValidator("v1.0.0")
+	g := NewWithT(t)
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported Kubernetes version")))
+}
+
+const testSchema = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.99.0"},
+  "paths": {},
+  "definitions": {
+    "io.k8s.api.core.v1.ConfigMap": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}},
+        "immutable": {"type": "boolean"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "", "kind": "ConfigMap", "version": "v1"}]
+    }
+  }
+}`
+
+func TestValidatorWithSchemaFile(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "swagger.json")
+	g.Expect(os.WriteFile(schemaFile, []byte(testSchema), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "configmap.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata: {name: a}\nimmutable: \"yes\"\n"), 0o644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "deployment.yaml"), []byte(testDeployment), 0o644)).To(Succeed())
+
+	bundledValidator, err := NewValidator("")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	validator, err := NewValidatorWithSchemaFile(schemaFile)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(validator.KubernetesVersion()).To(Equal("v1.99.0"))
+
+	// global kyaml schema is left as default, and validators don't share schemas
+	g.Expect(openapi.GetSchemaVersion()).To(Equal(DefaultKubernetesVersion()))
+	g.Expect(openapi.SchemaForResourceType(yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"})).NotTo(BeNil())
+	g.Expect(bundledValidator.Validate(dir, []string{"deployment.yaml"})).To(Succeed())
+	g.Expect(bundledValidator.Results()[0].Resources[0].Schema).To(Equal(SchemaKubernetes))
+
+	err = validator.Validate(dir, []string{"configmap.yaml"})
+	g.Expect(err).To(MatchError(ContainSubstring("configmap.yaml:4:12: immutable: expected boolean")))
+
+	// bundled schemas are not used, so deployment has no schema
+	g.Expect(validator.Validate(dir, []string{"deployment.yaml"})).To(Succeed())
+	g.Expect(validator.Results()).To(HaveLen(1))
+	g.Expect(validator.Results()[0].Resources).To(HaveLen(1))
+	g.Expect(validator.Results()[0].Resources[0].Schema).To(BeEmpty())
+
+	g.Expect(os.WriteFile(schemaFile, []byte(`{"swagger": "2.0"}`), 0o644)).To(Succeed())
+	_, err = NewValidatorWithSchemaFile(schemaFile)
+	g.Expect(err).To(MatchError(ContainSubstring("has no definitions")))
+}
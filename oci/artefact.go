@@ -11,6 +11,7 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	typesv1 "github.com/google/go-containerregistry/pkg/v1/types"
@@ -29,6 +31,7 @@ import (
 	"github.com/errordeveloper/tape/attest/manifest"
 	attestTypes "github.com/errordeveloper/tape/attest/types"
 	"github.com/errordeveloper/tape/attest/vcs/git"
+	"github.com/errordeveloper/tape/events"
 	manifestTypes "github.com/errordeveloper/tape/manifest/types"
 )
 
@@ -38,10 +41,19 @@ const (
 	ContentMediaType MediaType = mediaTypePrefix + ".content.v1alpha1.tar+gzip"
 	AttestMediaType  MediaType = mediaTypePrefix + ".attest.v1alpha1.jsonl+gzip"
 
+	// HelmChartContentMediaType and KustomizeContentMediaType are used instead of
+	// ContentMediaType for unrendered content, so that tools which only know how
+	// to apply plain manifests don't attempt to consume it
+	HelmChartContentMediaType MediaType = mediaTypePrefix + ".content.helm-chart.v1alpha1.tar+gzip"
+	KustomizeContentMediaType MediaType = mediaTypePrefix + ".content.kustomize.v1alpha1.tar+gzip"
+
 	ContentInterpreterAnnotation   = mediaTypePrefix + ".content-interpreter.v1alpha1"
 	ContentInterpreterKubectlApply = mediaTypePrefix + ".kubectl-apply.v1alpha1.tar+gzip"
+	ContentInterpreterHelmChart    = mediaTypePrefix + ".helm-chart.v1alpha1.tar+gzip"
+	ContentInterpreterKustomize    = mediaTypePrefix + ".kustomize.v1alpha1.tar+gzip"
 
-	AttestationsSummaryAnnotation = mediaTypePrefix + ".attestations-summary.v1alpha1"
+	AttestationsSummaryAnnotation       = mediaTypePrefix + ".attestations-summary.v1alpha1"
+	AttestationsStatementTypeAnnotation = mediaTypePrefix + ".attestations-statement-type.v1alpha1"
 
 	// TODO: content interpreter invocation with an image
 
@@ -50,6 +62,29 @@ const (
 	OCIManifestSchema1 = typesv1.OCIManifestSchema1
 )
 
+// ContentMediaTypes returns media types of all supported kinds of content
+func ContentMediaTypes() []MediaType {
+	return []MediaType{ContentMediaType, HelmChartContentMediaType, KustomizeContentMediaType}
+}
+
+func IsContentMediaType(mediaType MediaType) bool {
+	return slices.Contains(ContentMediaTypes(), mediaType)
+}
+
+// ContentMediaTypeFor returns media type of content layer for the given interpreter
+func ContentMediaTypeFor(contentInterpreter string) (MediaType, error) {
+	switch contentInterpreter {
+	case ContentInterpreterKubectlApply:
+		return ContentMediaType, nil
+	case ContentInterpreterHelmChart:
+		return HelmChartContentMediaType, nil
+	case ContentInterpreterKustomize:
+		return KustomizeContentMediaType, nil
+	default:
+		return "", fmt.Errorf("unsupported content interpreter %q", contentInterpreter)
+	}
+}
+
 type ArtefactInfo struct {
 	io.ReadCloser
 
@@ -154,6 +189,101 @@ func (c *Client) FetchFromIndexOrImage(ctx context.Context, imageIndex ImageInde
 	return artefacts, manifests, nil
 }
 
+// FetchLatestReferrer fetches artefacts of given media types from the manifest that refers to the
+// given digest, when there are multiple referrers, only artefacts of the one that was created last
+// are fetched, see LatestReferrer; registries that don't support referrers API are handled via
+// fallback tag, and the digest is nil when there are no referrers
+func (c *Client) FetchLatestReferrer(ctx context.Context, ref string, digest Hash, mediaTypes ...MediaType) ([]*ArtefactInfo, *Hash, *Manifest, error) {
+	repo, descriptors, err := c.listReferrers(ctx, ref, digest, mediaTypes...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(descriptors) == 0 {
+		return nil, nil, nil, nil
+	}
+	images := make(map[Hash]Image, len(descriptors))
+	manifests := make(map[Hash]*Manifest, len(descriptors))
+	for _, descriptor := range descriptors {
+		image, manifest, err := c.getReferrer(ctx, repo, descriptor)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		images[descriptor.Digest] = image
+		manifests[descriptor.Digest] = manifest
+	}
+	latest := LatestReferrer(manifests)
+	artefacts, _, err := c.FetchFromIndexOrImage(ctx, nil, nil, images[latest], mediaTypes...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return artefacts, &latest, manifests[latest], nil
+}
+
+// LatestReferrer returns digest of the manifest with the most recent creation time, as set in
+// 'org.opencontainers.image.created' annotation; manifests without a valid time are considered
+// the oldest, and the greatest digest is returned when times are the same, so that the choice
+// doesn't depend on the order in which referrers are listed
+func LatestReferrer(manifests map[Hash]*Manifest) Hash {
+	created := func(manifest *Manifest) time.Time {
+		t, err := time.Parse(time.RFC3339, manifest.Annotations[ociclient.CreatedAnnotation])
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+	var (
+		latest        Hash
+		latestCreated time.Time
+	)
+	for digest, manifest := range manifests {
+		t := created(manifest)
+		switch {
+		case latest == (Hash{}), t.After(latestCreated):
+		case t.Equal(latestCreated) && digest.String() > latest.String():
+		default:
+			continue
+		}
+		latest, latestCreated = digest, t
+	}
+	return latest
+}
+
+func (c *Client) listReferrers(ctx context.Context, ref string, digest Hash, mediaTypes ...MediaType) (name.Repository, []Descriptor, error) {
+	repo, err := name.ParseReference(ref)
+	if err != nil {
+		return name.Repository{}, nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	referrers, err := remote.Referrers(repo.Context().Digest(digest.String()), c.remoteWithContext(ctx)...)
+	if err != nil {
+		return name.Repository{}, nil, fmt.Errorf("failed to list referrers of %q: %w", digest.String(), err)
+	}
+	indexManifest, err := referrers.IndexManifest()
+	if err != nil {
+		return name.Repository{}, nil, err
+	}
+	descriptors := []Descriptor{}
+	for _, descriptor := range indexManifest.Manifests {
+		if slices.Contains(mediaTypes, MediaType(descriptor.ArtifactType)) {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+	return repo.Context(), descriptors, nil
+}
+
+// getReferrer fetches an image that refers to another one, referrers index is not
+// associated with a repository, so images cannot be obtained from it directly
+func (c *Client) getReferrer(ctx context.Context, repo name.Repository, descriptor Descriptor) (Image, *Manifest, error) {
+	image, err := remote.Image(repo.Digest(descriptor.Digest.String()), c.remoteWithContext(ctx)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch referrer %q: %w", descriptor.Digest.String(), err)
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	return image, manifest, nil
+}
+
 func (c *Client) GetSingleArtefact(ctx context.Context, ref string) (*ArtefactInfo, error) {
 	image, layers, annotations, err := c.getFlatArtefactLayers(ctx, ref)
 	if err != nil {
@@ -227,13 +357,73 @@ func (c *Client) getImage(ctx context.Context, imageIndex ImageIndex, digest Has
 	return image, manifest, nil
 }
 
+// Artefact is an index that was built, but not pushed yet, it holds a temporary
+// file with contents, so Cleanup must be called once it's no longer needed
+type Artefact struct {
+	Index         ImageIndex
+	Digest        Hash
+	ContentDigest Hash
+	AttestDigest  *Hash
+
+	tag        name.Tag
+	shortTag   name.Tag
+	semVerTags []name.Tag
+	tmpDir     string
+}
+
+func (a *Artefact) Cleanup() error {
+	if a.tmpDir == "" {
+		return nil
+	}
+	return os.RemoveAll(a.tmpDir)
+}
+
+// Diff returns a list of parts that differ between two artefacts
+func (a *Artefact) Diff(b *Artefact) []string {
+	diff := []string{}
+	if a.ContentDigest != b.ContentDigest {
+		diff = append(diff, fmt.Sprintf("content (%s != %s)", a.ContentDigest, b.ContentDigest))
+	}
+	switch {
+	case a.AttestDigest == nil && b.AttestDigest == nil:
+	case a.AttestDigest == nil || b.AttestDigest == nil:
+		diff = append(diff, "attestations (only one of the artefacts has attestations)")
+	case *a.AttestDigest != *b.AttestDigest:
+		diff = append(diff, fmt.Sprintf("attestations (%s != %s)", a.AttestDigest, b.AttestDigest))
+	}
+	if a.Digest != b.Digest && len(diff) == 0 {
+		diff = append(diff, fmt.Sprintf("index (%s != %s)", a.Digest, b.Digest))
+	}
+	return diff
+}
+
 // based on https://github.com/fluxcd/pkg/blob/2a323d771e17af02dee2ccbbb9b445b78ab048e5/oci/client/push.go
-func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir string, timestamp *time.Time, sourceAttestations ...attestTypes.Statement) (*PackageRefs, error) {
+func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir, contentInterpreter string, timestamp *time.Time, sourceAttestations ...attestTypes.Statement) (*PackageRefs, error) {
+	artefact, err := c.BuildArtefactIndex(ctx, destinationRef, sourceDir, contentInterpreter, timestamp, sourceAttestations...)
+	if err != nil {
+		return nil, err
+	}
+	defer artefact.Cleanup()
+
+	return c.PushArtefactIndex(ctx, artefact)
+}
+
+func (c *Client) BuildArtefactIndex(ctx context.Context, destinationRef, sourceDir, contentInterpreter string, timestamp *time.Time, sourceAttestations ...attestTypes.Statement) (_ *Artefact, err error) {
+	contentMediaType, err := ContentMediaTypeFor(contentInterpreter)
+	if err != nil {
+		return nil, err
+	}
+
 	tmpDir, err := os.MkdirTemp("", "bpt-oci-artefact-*")
 	if err != nil {
 		return nil, err
 	}
-	defer os.RemoveAll(tmpDir)
+	artefact := &Artefact{tmpDir: tmpDir}
+	defer func() {
+		if err != nil {
+			_ = artefact.Cleanup()
+		}
+	}()
 
 	tmpFile := filepath.Join(tmpDir, "artefact.tgz")
 
@@ -261,9 +451,9 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 	hash := hex.EncodeToString(c.hash.Sum(nil))
-	tag := repo.Tag(manifestTypes.ConfigImageTagPrefix + hash)
-	shortTag := tag.Context().Tag(manifestTypes.ConfigImageTagPrefix + hash[:7])
-	semVerTags := SemVerTagsFromAttestations(ctx, tag, sourceAttestations...)
+	artefact.tag = repo.Tag(manifestTypes.ConfigImageTagPrefix + hash)
+	artefact.shortTag = artefact.tag.Context().Tag(manifestTypes.ConfigImageTagPrefix + hash[:7])
+	artefact.semVerTags = SemVerTagsFromAttestations(ctx, artefact.tag, sourceAttestations...)
 
 	if timestamp == nil {
 		timestamp = new(time.Time)
@@ -271,7 +461,8 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 	}
 
 	indexAnnotations := map[string]string{
-		ociclient.CreatedAnnotation: timestamp.Format(time.RFC3339),
+		// timezone must not depend on where the artefact is built
+		ociclient.CreatedAnnotation: timestamp.UTC().Format(time.RFC3339),
 	}
 
 	index := mutate.Annotations(
@@ -281,12 +472,12 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 
 	configAnnotations := maps.Clone(indexAnnotations)
 
-	configAnnotations[ContentInterpreterAnnotation] = ContentInterpreterKubectlApply
+	configAnnotations[ContentInterpreterAnnotation] = contentInterpreter
 
 	config := mutate.Annotations(
 		mutate.ConfigMediaType(
 			mutate.MediaType(empty.Image, OCIManifestSchema1),
-			ContentMediaType,
+			contentMediaType,
 		),
 		configAnnotations,
 	).(Image)
@@ -295,7 +486,7 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 	// albeit it might impact memory usage and there is no strict security requirement, and
 	// manifests do get written out already anyway.
 	configLayer, err := tarball.LayerFromFile(tmpFile,
-		tarball.WithMediaType(ContentMediaType),
+		tarball.WithMediaType(contentMediaType),
 		tarball.WithCompression(compression.GZip),
 		tarball.WithCompressedCaching,
 	)
@@ -308,6 +499,12 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 		return nil, fmt.Errorf("appeding content to artifact failed: %w", err)
 	}
 
+	contentDigest, err := config.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing content digest failed: %w", err)
+	}
+	artefact.ContentDigest = contentDigest
+
 	index = mutate.AppendManifests(index,
 		mutate.IndexAddendum{
 			Descriptor: makeDescriptorWithPlatform(),
@@ -323,6 +520,7 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 			return nil, err
 		}
 		attestAnnotations[AttestationsSummaryAnnotation] = summary
+		attestAnnotations[AttestationsStatementTypeAnnotation] = attestTypes.CurrentStatementType
 
 		attest := mutate.Annotations(
 			mutate.ConfigMediaType(
@@ -337,6 +535,12 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 			return nil, fmt.Errorf("appeding attestations to artifact failed: %w", err)
 		}
 
+		attestDigest, err := attest.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("parsing attestations digest failed: %w", err)
+		}
+		artefact.AttestDigest = &attestDigest
+
 		index = mutate.AppendManifests(index,
 			mutate.IndexAddendum{
 				Descriptor: makeDescriptorWithPlatform(),
@@ -350,25 +554,36 @@ func (c *Client) PushArtefact(ctx context.Context, destinationRef, sourceDir str
 		return nil, fmt.Errorf("parsing index digest failed: %w", err)
 	}
 
-	if err := remote.WriteIndex(tag, index, c.remoteWithContext(ctx)...); err != nil {
+	artefact.Index = index
+	artefact.Digest = digest
+	return artefact, nil
+}
+
+func (c *Client) PushArtefactIndex(ctx context.Context, artefact *Artefact) (*PackageRefs, error) {
+	if err := remote.WriteIndex(artefact.tag, artefact.Index, c.remoteWithContext(ctx)...); err != nil {
 		return nil, fmt.Errorf("pushing index failed: %w", err)
 	}
 
 	refs := &PackageRefs{
-		Digest:  digest.String(),
-		Primary: tag.String(),
-		Short:   shortTag.String(),
-		SemVer:  make([]string, len(semVerTags)),
+		Digest:  artefact.Digest.String(),
+		Primary: artefact.tag.String(),
+		Short:   artefact.shortTag.String(),
+		SemVer:  make([]string, len(artefact.semVerTags)),
 	}
 
-	for i, tagAlias := range append(semVerTags, shortTag) {
-		if err := remote.Tag(tagAlias, index, c.remoteWithContext(ctx)...); err != nil {
+	for i, tagAlias := range append(artefact.semVerTags, artefact.shortTag) {
+		if err := remote.Tag(tagAlias, artefact.Index, c.remoteWithContext(ctx)...); err != nil {
 			return nil, fmt.Errorf("adding alias tagging failed: %w", err)
 		}
-		if i < len(semVerTags) {
-			refs.SemVer[i] = tagAlias.String() + "@" + digest.String()
+		if i < len(artefact.semVerTags) {
+			refs.SemVer[i] = tagAlias.String() + "@" + artefact.Digest.String()
 		}
 	}
+	c.events.Emit(events.PackagePushed{
+		Ref:    refs.Primary,
+		Digest: refs.Digest,
+		Tags:   append([]string{refs.Short}, refs.SemVer...),
+	})
 	return refs, nil
 }
 
@@ -500,7 +715,8 @@ func (c *Client) BuildArtefact(artifactPath,
 			header.Name = filepath.ToSlash(relFilePath)
 		}
 
-		// Remove any environment specific data.
+		// Remove any environment specific data, permissions also depend on umask
+		header.Mode = normaliseMode(fi.Mode())
 		header.Gid = 0
 		header.Uid = 0
 		header.Uname = ""
@@ -544,14 +760,25 @@ func (c *Client) BuildArtefact(artifactPath,
 	return nil
 }
 
+func normaliseMode(mode os.FileMode) int64 {
+	if mode.IsDir() || mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
 func (c *Client) BuildAttestations(statements []attestTypes.Statement) (Layer, error) {
 	if len(statements) == 0 {
 		return nil, nil
 	}
+	return newAttestationsLayer(attestTypes.Statements(statements).Encode)
+}
+
+func newAttestationsLayer(encode func(io.Writer) error) (Layer, error) {
 	output := bytes.NewBuffer(nil)
 	gw := gzip.NewWriter(output)
 
-	if err := attestTypes.Statements(statements).Encode(gw); err != nil {
+	if err := encode(gw); err != nil {
 		return nil, err
 	}
 
@@ -574,3 +801,85 @@ func (c *Client) BuildAttestations(statements []attestTypes.Statement) (Layer, e
 
 	return layer, nil
 }
+
+// DecodeAttestations reads statements from compressed attestations layer,
+// statements of all supported in-toto versions are accepted
+func DecodeAttestations(r io.Reader) ([]attestTypes.ExportedStatement, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := attestTypes.DecodeStatements(gr)
+	if err != nil {
+		return nil, err
+	}
+	if err := gr.Close(); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// PushAttestationsReferrer pushes attestations as a manifest that refers to the given
+// index via subject field, so that attestations can be added to an existing artefact
+// without changing its digest
+func (c *Client) PushAttestationsReferrer(ctx context.Context, ref string, subject ImageIndex,
+	statements []attestTypes.ExportedStatement, annotations map[string]string) (*Hash, error) {
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no attestations to push")
+	}
+
+	repo, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	subjectDescriptor, err := partial.Descriptor(subject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make descriptor for subject: %w", err)
+	}
+
+	attestLayer, err := newAttestationsLayer(func(w io.Writer) error {
+		return attestTypes.EncodeStatements(w, statements)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialise attestations: %w", err)
+	}
+
+	attestAnnotations := maps.Clone(annotations)
+	if attestAnnotations == nil {
+		attestAnnotations = map[string]string{}
+	}
+	attestAnnotations[AttestationsStatementTypeAnnotation] = attestTypes.CurrentStatementType
+	// creation time of the artefact is copied from the original attestations, while
+	// referrer needs its own, so that the latest referrer can be found
+	attestAnnotations[ociclient.CreatedAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	attest := mutate.Annotations(
+		mutate.ConfigMediaType(
+			mutate.MediaType(empty.Image, OCIManifestSchema1),
+			AttestMediaType,
+		),
+		attestAnnotations,
+	).(Image)
+
+	attest, err = mutate.Append(attest, mutate.Addendum{Layer: attestLayer})
+	if err != nil {
+		return nil, fmt.Errorf("appeding attestations to referrer failed: %w", err)
+	}
+
+	attest = mutate.Subject(attest, Descriptor{
+		MediaType: subjectDescriptor.MediaType,
+		Digest:    subjectDescriptor.Digest,
+		Size:      subjectDescriptor.Size,
+	}).(Image)
+
+	digest, err := attest.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing referrer digest failed: %w", err)
+	}
+
+	if err := remote.Write(repo.Context().Digest(digest.String()), attest, c.remoteWithContext(ctx)...); err != nil {
+		return nil, fmt.Errorf("pushing referrer failed: %w", err)
+	}
+	return &digest, nil
+}
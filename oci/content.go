@@ -0,0 +1,161 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// DefaultMaxContentSize is the default limit of total size of files in content layer
+const DefaultMaxContentSize = 100 << 20
+
+// ContentOptions control which files are read from content layer
+type ContentOptions struct {
+	// Only lists patterns in gitignore syntax, when set only files that match any of these are read
+	Only []string
+	// MaxSize is the limit of total size of files that are read, there is no limit when it's negative
+	MaxSize int64
+}
+
+// ContentFile is a regular file read from content layer
+type ContentFile struct {
+	Path string
+	Mode os.FileMode
+	Data []byte
+}
+
+// ReadContent reads files from compressed content layer into memory, so that nothing
+// gets written before the whole layer is checked; paths are checked to be relative
+// and not to refer outside of the layer, and only regular files and directories are
+// accepted, as these are the only types of entries BuildArtefact writes
+func ReadContent(r io.Reader, opts ContentOptions) ([]ContentFile, error) {
+	var only gitignore.Matcher
+	if len(opts.Only) != 0 {
+		patterns := make([]gitignore.Pattern, len(opts.Only))
+		for i := range opts.Only {
+			patterns[i] = gitignore.ParsePattern(opts.Only[i], nil)
+		}
+		only = gitignore.NewMatcher(patterns)
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress content: %w", err)
+	}
+	defer gr.Close()
+
+	files := []ContentFile{}
+	size := int64(0)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read content: %w", err)
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid path %q in content", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("unsupported type of entry %q in content", header.Name)
+		}
+		if only != nil && !only.Match(strings.Split(name, "/"), false) {
+			continue
+		}
+
+		size += header.Size
+		if opts.MaxSize >= 0 && size > opts.MaxSize {
+			return nil, fmt.Errorf("size of content exceeds maximum of %d bytes", opts.MaxSize)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q from content: %w", header.Name, err)
+		}
+		files = append(files, ContentFile{
+			Path: name,
+			Mode: header.FileInfo().Mode().Perm(),
+			Data: data,
+		})
+	}
+}
+
+// WriteContentFiles writes files into dir, existing files are only overwritten
+// when overwrite is set, and this is checked before any of the files are written
+func WriteContentFiles(dir string, files []ContentFile, overwrite bool) error {
+	if !overwrite {
+		for _, file := range files {
+			path := filepath.Join(dir, filepath.FromSlash(file.Path))
+			if _, err := os.Lstat(path); err == nil {
+				return fmt.Errorf("unable to write %q: %w", path, os.ErrExist)
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		mode := os.FileMode(regularFileMode)
+		if file.Mode&0o111 != 0 {
+			mode = 0o750
+		}
+		if err := os.WriteFile(path, file.Data, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteManifestStream writes JSON and YAML files as a single multi-document
+// YAML stream, any other files are skipped
+func WriteManifestStream(w io.Writer, files []ContentFile) error {
+	first := true
+	for _, file := range files {
+		switch path.Ext(file.Path) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		data := bytes.TrimPrefix(file.Data, []byte("---\n"))
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if !first {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		if _, err := fmt.Fprintf(w, "# Source: %s\n", file.Path); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
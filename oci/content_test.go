@@ -0,0 +1,134 @@
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/oci"
+)
+
+func TestContent(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"namespace.yaml":           "kind: Namespace\n",
+		"app/deployment.yaml":      "---\nkind: Deployment\n",
+		"app/service.json":         `{"kind": "Service"}`,
+		"app/README.md":            "# app\n",
+		"app/config/settings.yaml": "kind: ConfigMap\n",
+	} {
+		g.Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, path), []byte(data), 0o644)).To(Succeed())
+	}
+	content := &bytes.Buffer{}
+	g.Expect(NewClient(nil).BuildArtefact("", dir, content)).To(Succeed())
+
+	testCases := []struct {
+		description   string
+		opts          ContentOptions
+		expectedPaths []string
+		expectedErr   string
+	}{
+		{
+			description:   "all files",
+			opts:          ContentOptions{MaxSize: DefaultMaxContentSize},
+			expectedPaths: []string{"app/README.md", "app/config/settings.yaml", "app/deployment.yaml", "app/service.json", "namespace.yaml"},
+		},
+		{
+			description:   "only some files",
+			opts:          ContentOptions{Only: []string{"*.yaml", "!config/"}, MaxSize: -1},
+			expectedPaths: []string{"app/deployment.yaml", "namespace.yaml"},
+		},
+		{
+			description: "content too large",
+			opts:        ContentOptions{MaxSize: 20},
+			expectedErr: "size of content exceeds maximum of 20 bytes",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			files, err := ReadContent(bytes.NewReader(content.Bytes()), tc.opts)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectedErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			paths := []string{}
+			for _, file := range files {
+				paths = append(paths, file.Path)
+			}
+			g.Expect(paths).To(Equal(tc.expectedPaths))
+		})
+	}
+
+	t.Run("write files", func(t *testing.T) {
+		g := NewWithT(t)
+
+		files, err := ReadContent(bytes.NewReader(content.Bytes()), ContentOptions{MaxSize: -1})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		outputDir := t.TempDir()
+		g.Expect(WriteContentFiles(outputDir, files, false)).To(Succeed())
+		g.Expect(os.ReadFile(filepath.Join(outputDir, "app", "deployment.yaml"))).To(BeEquivalentTo("---\nkind: Deployment\n"))
+
+		g.Expect(os.WriteFile(filepath.Join(outputDir, "namespace.yaml"), []byte("changed"), 0o644)).To(Succeed())
+		g.Expect(WriteContentFiles(outputDir, files, false)).To(MatchError(os.ErrExist))
+		g.Expect(os.ReadFile(filepath.Join(outputDir, "namespace.yaml"))).To(BeEquivalentTo("changed"))
+
+		g.Expect(WriteContentFiles(outputDir, files, true)).To(Succeed())
+		g.Expect(os.ReadFile(filepath.Join(outputDir, "namespace.yaml"))).To(BeEquivalentTo("kind: Namespace\n"))
+	})
+
+	t.Run("manifest stream", func(t *testing.T) {
+		g := NewWithT(t)
+
+		files, err := ReadContent(bytes.NewReader(content.Bytes()), ContentOptions{MaxSize: -1})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		stream := &bytes.Buffer{}
+		g.Expect(WriteManifestStream(stream, files)).To(Succeed())
+		g.Expect(stream.String()).To(Equal(`# Source: app/config/settings.yaml
+kind: ConfigMap
+---
+# Source: app/deployment.yaml
+kind: Deployment
+---
+# Source: app/service.json
+{"kind": "Service"}
+---
+# Source: namespace.yaml
+kind: Namespace
+`))
+	})
+
+	t.Run("invalid paths", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, header := range []*tar.Header{
+			{Name: "../escape.yaml", Typeflag: tar.TypeReg},
+			{Name: "/etc/escape.yaml", Typeflag: tar.TypeReg},
+			{Name: "link.yaml", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		} {
+			archive := &bytes.Buffer{}
+			gw := gzip.NewWriter(archive)
+			tw := tar.NewWriter(gw)
+			g.Expect(tw.WriteHeader(header)).To(Succeed())
+			g.Expect(tw.Close()).To(Succeed())
+			g.Expect(gw.Close()).To(Succeed())
+
+			_, err := ReadContent(archive, ContentOptions{MaxSize: -1})
+			g.Expect(err).To(HaveOccurred())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReferenceError records why an operation on a particular reference failed
+type ReferenceError struct {
+	Ref string
+	Err error
+}
+
+func (e ReferenceError) Error() string { return fmt.Sprintf("%s: %s", e.Ref, e.Err) }
+
+func (e ReferenceError) Unwrap() error { return e.Err }
+
+// ReferenceErrors is used to report all references that an operation failed
+// on, instead of stopping at the first one
+type ReferenceErrors []ReferenceError
+
+// Append records an error for a reference, only the first error of each reference
+// is kept, as the same reference is often found in many places
+func (e *ReferenceErrors) Append(ref string, err error) {
+	for i := range *e {
+		if (*e)[i].Ref == ref {
+			return
+		}
+	}
+	*e = append(*e, ReferenceError{Ref: ref, Err: err})
+}
+
+// ErrorOrNil returns nil when there were no errors, so it can be returned directly
+func (e ReferenceErrors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e ReferenceErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "failed on %d references:", len(e))
+	for i := range e {
+		fmt.Fprintf(b, "\n  %s", e[i].Error())
+	}
+	return b.String()
+}
+
+func (e ReferenceErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = e[i]
+	}
+	return errs
+}
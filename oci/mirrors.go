@@ -0,0 +1,180 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// CapabilityResolve allows resolving tags to digests and listing tags
+	CapabilityResolve = "resolve"
+	// CapabilityPull allows fetching manifests and blobs
+	CapabilityPull = "pull"
+)
+
+type (
+	// MirrorConfig is modelled on containerd's hosts.toml, hosts of each registry
+	// are tried in order before falling back to the registry itself, e.g.:
+	//
+	//	registries:
+	//	  docker.io:
+	//	    hosts:
+	//	    - host: mirror.example.com/dockerhub
+	//	      capabilities: [pull, resolve]
+	MirrorConfig struct {
+		Registries map[string]RegistryHosts `json:"registries"`
+	}
+
+	RegistryHosts struct {
+		Hosts []MirrorHost `json:"hosts"`
+	}
+
+	MirrorHost struct {
+		// Host is a registry address, optionally followed by a path
+		// that repositories are nested under
+		Host string `json:"host"`
+		// Capabilities of the host, both are assumed when not set
+		Capabilities []string `json:"capabilities,omitempty"`
+	}
+)
+
+func LoadMirrorConfig(path string) (*MirrorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mirror config: %w", err)
+	}
+	config := &MirrorConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse mirror config %q: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mirror config %q: %w", path, err)
+	}
+	return config, nil
+}
+
+func (c *MirrorConfig) Validate() error {
+	for registry, hosts := range c.Registries {
+		if _, err := name.NewRegistry(registry); err != nil {
+			return fmt.Errorf("invalid registry %q: %w", registry, err)
+		}
+		for _, host := range hosts.Hosts {
+			if _, err := name.NewRepository(host.Host + "/test"); err != nil {
+				return fmt.Errorf("invalid host %q for registry %q: %w", host.Host, registry, err)
+			}
+			for _, capability := range host.Capabilities {
+				switch capability {
+				case CapabilityResolve, CapabilityPull:
+				default:
+					return fmt.Errorf("invalid capability %q of host %q", capability, host.Host)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MirrorRefs returns references that point to mirrors with the given capability,
+// in the order they should be tried, upstream reference is not included
+func (c *MirrorConfig) MirrorRefs(ref, capability string) ([]string, error) {
+	if c == nil || len(c.Registries) == 0 {
+		return nil, nil
+	}
+	parsedRef, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", ref, err)
+	}
+	identifier := ":" + parsedRef.Identifier()
+	if _, ok := parsedRef.(name.Digest); ok {
+		identifier = "@" + parsedRef.Identifier()
+	}
+	repos := c.mirrorRepositories(parsedRef.Context(), capability)
+	for i := range repos {
+		repos[i] += identifier
+	}
+	return repos, nil
+}
+
+// MirrorRepositories is like MirrorRefs, but it takes a repository without a tag or digest
+func (c *MirrorConfig) MirrorRepositories(repo, capability string) ([]string, error) {
+	if c == nil || len(c.Registries) == 0 {
+		return nil, nil
+	}
+	parsedRepo, err := name.NewRepository(repo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository %q: %w", repo, err)
+	}
+	return c.mirrorRepositories(parsedRepo, capability), nil
+}
+
+func (c *MirrorConfig) mirrorRepositories(repo name.Repository, capability string) []string {
+	repos := []string{}
+	for _, host := range c.hostsFor(repo.Registry) {
+		if len(host.Capabilities) > 0 && !slices.Contains(host.Capabilities, capability) {
+			continue
+		}
+		repos = append(repos, strings.TrimSuffix(host.Host, "/")+"/"+repo.RepositoryStr())
+	}
+	return repos
+}
+
+func (c *MirrorConfig) hostsFor(registry name.Registry) []MirrorHost {
+	for key, hosts := range c.Registries {
+		// keys are normalised the same way as references, so that
+		// docker.io and index.docker.io are equivalent
+		if r, err := name.NewRegistry(key); err == nil && r.RegistryStr() == registry.RegistryStr() {
+			return hosts.Hosts
+		}
+	}
+	return nil
+}
+
+// SetMirrors configures the client to read from mirrors first
+func (c *Client) SetMirrors(mirrors *MirrorConfig) { c.mirrors = mirrors }
+
+// withMirrors calls do with mirror references first and falls back to the
+// upstream reference, it returns the reference that do succeeded with
+func (c *Client) withMirrors(ctx context.Context, ref string, mirrorRefs []string, do func(string) error) (string, error) {
+	mirrorErrs := ReferenceErrors{}
+	for _, mirrorRef := range mirrorRefs {
+		err := do(mirrorRef)
+		if err == nil {
+			return mirrorRef, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		mirrorErrs.Append(mirrorRef, err)
+	}
+	if err := do(ref); err != nil {
+		if len(mirrorErrs) > 0 {
+			return "", errors.Join(err, fmt.Errorf("all mirrors failed: %w", mirrorErrs))
+		}
+		return "", err
+	}
+	return ref, nil
+}
+
+// capabilityFor returns what capability is required to read the reference,
+// references with a tag need to be resolved first
+func capabilityFor(ref string) string {
+	if strings.Contains(ref, "@") {
+		return CapabilityPull
+	}
+	return CapabilityResolve
+}
+
+func (c *Client) withMirrorRefs(ctx context.Context, ref string, do func(string) error) (string, error) {
+	mirrorRefs, err := c.mirrors.MirrorRefs(ref, capabilityFor(ref))
+	if err != nil {
+		return "", err
+	}
+	return c.withMirrors(ctx, ref, mirrorRefs, do)
+}
@@ -0,0 +1,131 @@
+package oci_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+func TestMirrorRefs(t *testing.T) {
+	config := &MirrorConfig{
+		Registries: map[string]RegistryHosts{
+			"docker.io": {Hosts: []MirrorHost{
+				{Host: "mirror.example.com/dockerhub/"},
+				{Host: "pull-only.example.com", Capabilities: []string{CapabilityPull}},
+			}},
+			"quay.io": {Hosts: []MirrorHost{
+				{Host: "quay-mirror.example.com", Capabilities: []string{CapabilityResolve}},
+			}},
+		},
+	}
+
+	testCases := []struct {
+		ref        string
+		capability string
+		expected   []string
+	}{
+		{
+			ref:        "nginx:1.25",
+			capability: CapabilityResolve,
+			expected:   []string{"mirror.example.com/dockerhub/library/nginx:1.25"},
+		},
+		{
+			ref:        "index.docker.io/library/nginx@sha256:a0f8c7c3ba3b7ed5a2c4ae2e33a6d7d4e8d5b1f5e3f8ad7a5c8b5d5b5e5f5a5b",
+			capability: CapabilityPull,
+			expected: []string{
+				"mirror.example.com/dockerhub/library/nginx@sha256:a0f8c7c3ba3b7ed5a2c4ae2e33a6d7d4e8d5b1f5e3f8ad7a5c8b5d5b5e5f5a5b",
+				"pull-only.example.com/library/nginx@sha256:a0f8c7c3ba3b7ed5a2c4ae2e33a6d7d4e8d5b1f5e3f8ad7a5c8b5d5b5e5f5a5b",
+			},
+		},
+		{
+			ref:        "quay.io/cilium/cilium:v1.14.0",
+			capability: CapabilityPull,
+			expected:   []string{},
+		},
+		{
+			ref:        "ghcr.io/errordeveloper/tape:latest",
+			capability: CapabilityResolve,
+			expected:   []string{},
+		},
+	}
+
+	g := NewWithT(t)
+	g.Expect(config.Validate()).To(Succeed())
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.ref, func(t *testing.T) {
+			g := NewWithT(t)
+
+			refs, err := config.MirrorRefs(tc.ref, tc.capability)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(refs).To(Equal(tc.expected))
+		})
+	}
+
+	g.Expect((&MirrorConfig{Registries: map[string]RegistryHosts{
+		"docker.io": {Hosts: []MirrorHost{{Host: "mirror.example.com", Capabilities: []string{"push"}}}},
+	}}).Validate()).To(MatchError(ContainSubstring(`invalid capability "push"`)))
+}
+
+func TestMirrorFallback(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	push := func(ref string) string {
+		image, err := crane.Image(map[string][]byte{"test": []byte(ref)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(crane.Push(image, ref, registry.CraneOptions()...)).To(Succeed())
+		digest, err := image.Digest()
+		g.Expect(err).NotTo(HaveOccurred())
+		return digest.String()
+	}
+
+	// the registry acts as both the upstream and the mirror,
+	// mirrored repositories are nested under a path prefix
+	upstream := registry.Addr()
+	mirror := registry.Addr() + "/mirror"
+
+	client := NewClient(registry.CraneOptions())
+	client.SetMirrors(&MirrorConfig{
+		Registries: map[string]RegistryHosts{
+			upstream: {Hosts: []MirrorHost{{Host: mirror}}},
+		},
+	})
+
+	mirroredDigest := push(mirror + "/app/mirrored:v1")
+	upstreamDigest := push(upstream + "/app/upstream-only:v1")
+
+	digest, source, err := client.DigestFrom(context.Background(), upstream+"/app/mirrored:v1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(digest).To(Equal(mirroredDigest))
+	g.Expect(source).To(Equal(mirror + "/app/mirrored:v1"))
+
+	digest, source, err = client.DigestFrom(context.Background(), upstream+"/app/upstream-only:v1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(digest).To(Equal(upstreamDigest))
+	g.Expect(source).To(Equal(upstream + "/app/upstream-only:v1"))
+
+	_, err = client.Pull(context.Background(), upstream+"/app/mirrored@"+mirroredDigest)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	source, err = client.CopyFrom(context.Background(), upstream+"/app/mirrored@"+mirroredDigest, upstream+"/app/copied:v1", mirroredDigest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(source).To(Equal(mirror + "/app/mirrored@" + mirroredDigest))
+
+	source, err = client.CopyFrom(context.Background(), upstream+"/app/upstream-only:v1", upstream+"/app/copied:v2", upstreamDigest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(source).To(Equal(upstream + "/app/upstream-only:v1"))
+
+	_, _, err = client.DigestFrom(context.Background(), upstream+"/app/missing:v1")
+	g.Expect(err).To(MatchError(ContainSubstring("all mirrors failed")))
+	g.Expect(err).To(MatchError(ContainSubstring(mirror + "/app/missing:v1")))
+}
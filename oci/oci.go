@@ -7,6 +7,7 @@ import (
 	"hash"
 	"io"
 	"strings"
+	"time"
 
 	ociclient "github.com/fluxcd/pkg/oci/client"
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -20,6 +21,8 @@ import (
 	// OCIv1 "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/google/go-containerregistry/pkg/logs"
+
+	"github.com/errordeveloper/tape/events"
 )
 
 const (
@@ -39,13 +42,18 @@ type (
 	Platform      = v1.Platform
 	Client        struct {
 		*ociclient.Client
-		hash hash.Hash
+		hash    hash.Hash
+		mirrors *MirrorConfig
+		events  *events.Emitter
 	}
 )
 
+// NewClient creates a client with default retry policy, when opts set another
+// transport, WithRetry should be used to retain retries
 func NewClient(opts []crane.Option) *Client {
 	options := []crane.Option{
 		crane.WithUserAgent(UserAgent),
+		WithRetry(remote.DefaultTransport, DefaultRetryPolicy(), DefaultMaxConcurrencyPerRegistry),
 	}
 	options = append(options, opts...)
 
@@ -59,10 +67,16 @@ func NewDebugClient(debugWriter io.Writer, opts []crane.Option) *Client {
 	logs.Debug.SetOutput(debugWriter)
 
 	return NewClient([]crane.Option{
-		crane.WithTransport(transport.NewLogger(remote.DefaultTransport)),
+		WithRetry(transport.NewLogger(remote.DefaultTransport), DefaultRetryPolicy(), DefaultMaxConcurrencyPerRegistry),
 	})
 }
 
+// SetEvents configures the client to emit events about digests, copying and pushing
+func (c *Client) SetEvents(emitter *events.Emitter) { c.events = emitter }
+
+// Events returns the emitter set via SetEvents, it may be nil
+func (c *Client) Events() *events.Emitter { return c.events }
+
 func (c *Client) withContext(ctx context.Context) []crane.Option {
 	return append([]crane.Option{
 		crane.WithContext(ctx),
@@ -76,24 +90,102 @@ func (c *Client) remoteWithContext(ctx context.Context) []remote.Option {
 }
 
 func (c *Client) Digest(ctx context.Context, ref string) (string, error) {
-	return crane.Digest(ref, c.withContext(ctx)...)
+	digest, _, err := c.DigestFrom(ctx, ref)
+	return digest, err
 }
 
-func (c *Client) Copy(ctx context.Context, srcRef, dstRef, digest string) error {
-	if err := crane.Copy(srcRef, dstRef, c.withContext(ctx)...); err != nil {
+// DigestFrom is like Digest, but it also returns the reference digest was
+// obtained from, which is different from ref when a mirror was used
+func (c *Client) DigestFrom(ctx context.Context, ref string) (string, string, error) {
+	digest := ""
+	source, err := c.withMirrorRefs(ctx, ref, func(ref string) (err error) {
+		digest, err = crane.Digest(ref, c.withContext(ctx)...)
 		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return digest, source, nil
+}
+
+// Copy copies srcRef to dstRef, source is read from mirrors when available
+func (c *Client) Copy(ctx context.Context, srcRef, dstRef, digest string) error {
+	_, err := c.CopyFrom(ctx, srcRef, dstRef, digest)
+	return err
+}
+
+// CopyFrom is like Copy, but it also returns the reference image was
+// copied from, which is different from srcRef when a mirror was used
+func (c *Client) CopyFrom(ctx context.Context, srcRef, dstRef, digest string) (string, error) {
+	source, err := c.withMirrorRefs(ctx, srcRef, func(srcRef string) error {
+		return c.copyWithProgress(ctx, srcRef, dstRef)
+	})
+	if err != nil {
+		return "", err
 	}
 	newDigest, err := crane.Digest(dstRef, c.withContext(ctx)...)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if digest != newDigest {
-		return fmt.Errorf("unexpected digest mismatch after copying: %s (from destination registry) != %s (from source registry)", digest, newDigest)
+		return "", fmt.Errorf("unexpected digest mismatch after copying: %s (from destination registry) != %s (from source registry)", digest, newDigest)
+	}
+	return source, nil
+}
+
+// blobCopyProgressInterval limits how often progress events are emitted,
+// the final update is always emitted
+const blobCopyProgressInterval = 500 * time.Millisecond
+
+func (c *Client) copyWithProgress(ctx context.Context, srcRef, dstRef string) error {
+	if c.events == nil {
+		return crane.Copy(srcRef, dstRef, c.withContext(ctx)...)
 	}
-	return nil
+
+	updates := make(chan v1.Update, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		last, emitted := v1.Update{}, time.Time{}
+		emit := func() {
+			c.events.Emit(events.BlobCopyProgress{
+				Source:      srcRef,
+				Destination: dstRef,
+				Complete:    last.Complete,
+				Total:       last.Total,
+			})
+			emitted = time.Now()
+		}
+		for update := range updates {
+			if update.Error != nil {
+				continue
+			}
+			last = update
+			if time.Since(emitted) >= blobCopyProgressInterval {
+				emit()
+			}
+		}
+		emit()
+	}()
+
+	err := crane.Copy(srcRef, dstRef, append(c.withContext(ctx), func(o *crane.Options) {
+		o.Remote = append(o.Remote, remote.WithProgress(updates))
+	})...)
+	// pusher doesn't close the channel, unlike remote.Write
+	close(updates)
+	<-done
+	return err
+}
+
+func (c *Client) GetIndexOrImage(ctx context.Context, ref string) (imageIndex v1.ImageIndex, indexManifest *v1.IndexManifest, image v1.Image, err error) {
+	_, err = c.withMirrorRefs(ctx, ref, func(ref string) (err error) {
+		imageIndex, indexManifest, image, err = c.getIndexOrImage(ctx, ref)
+		return err
+	})
+	return
 }
 
-func (c *Client) GetIndexOrImage(ctx context.Context, ref string) (v1.ImageIndex, *v1.IndexManifest, v1.Image, error) {
+func (c *Client) getIndexOrImage(ctx context.Context, ref string) (v1.ImageIndex, *v1.IndexManifest, v1.Image, error) {
 	parsedRef, err := name.ParseReference(ref)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("invalid URL %q: %w", ref, err)
@@ -137,8 +229,12 @@ func (c *Client) GetIndexOrImage(ctx context.Context, ref string) (v1.ImageIndex
 	}
 }
 
-func (c *Client) Pull(ctx context.Context, ref string) (v1.Image, error) {
-	return crane.Pull(ref, c.withContext(ctx)...)
+func (c *Client) Pull(ctx context.Context, ref string) (image v1.Image, err error) {
+	_, err = c.withMirrorRefs(ctx, ref, func(ref string) (err error) {
+		image, err = crane.Pull(ref, c.withContext(ctx)...)
+		return err
+	})
+	return
 }
 
 func (c *Client) ListRelated(ctx context.Context, ref, digest string) ([]Metadata, error) {
@@ -147,10 +243,24 @@ func (c *Client) ListRelated(ctx context.Context, ref, digest string) ([]Metadat
 		RegexFilter:            fmt.Sprintf("^%s.*", tagPrefix),
 		IncludeCosignArtifacts: true,
 	}
-	tags, err := c.List(ctx, ref, listOptions)
+	mirrorRepos, err := c.mirrors.MirrorRepositories(ref, CapabilityResolve)
 	if err != nil {
 		return nil, err
 	}
+	tags := []Metadata{}
+	source, err := c.withMirrors(ctx, ref, mirrorRepos, func(repo string) (err error) {
+		tags, err = c.List(ctx, repo, listOptions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if source != ref {
+		// tags that were listed from a mirror are reported as upstream ones
+		for i := range tags {
+			tags[i].URL = ref + strings.TrimPrefix(tags[i].URL, source)
+		}
+	}
 	return tags, nil
 }
 
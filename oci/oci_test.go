@@ -0,0 +1,93 @@
+package oci_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	. "github.com/onsi/gomega"
+
+	"github.com/errordeveloper/tape/events"
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+func TestCopyEvents(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	image, err := crane.Image(map[string][]byte{"test": []byte(t.Name())})
+	g.Expect(err).NotTo(HaveOccurred())
+	digest, err := image.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	namer := registry.NewUniqueRepoNamer("oci-copy-events-test")
+	srcRef, dstRef := namer("source")+":test", namer("destination")+":test"
+	g.Expect(crane.Push(image, srcRef, registry.CraneOptions()...)).To(Succeed())
+
+	received := []events.Event{}
+	client := NewClient(registry.CraneOptions())
+	client.SetEvents(events.NewEmitter(func(event events.Event) {
+		received = append(received, event)
+	}))
+
+	g.Expect(client.Copy(context.Background(), srcRef, dstRef, digest.String())).To(Succeed())
+
+	g.Expect(received).NotTo(BeEmpty())
+	last := received[len(received)-1]
+	g.Expect(last.Type).To(Equal(events.TypeBlobCopyProgress))
+	progress := last.Data.(events.BlobCopyProgress)
+	g.Expect(progress.Source).To(Equal(srcRef))
+	g.Expect(progress.Destination).To(Equal(dstRef))
+	g.Expect(progress.Total).To(BeNumerically(">", 0))
+	g.Expect(progress.Complete).To(Equal(progress.Total))
+}
+
+func TestBuildArtefactContentInterpreter(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: app\n"), 0o644)).To(Succeed())
+	timestamp := time.Unix(0, 0)
+
+	client := NewClient(nil)
+
+	testCases := []struct {
+		contentInterpreter string
+		expectedMediaType  MediaType
+	}{
+		{ContentInterpreterKubectlApply, ContentMediaType},
+		{ContentInterpreterHelmChart, HelmChartContentMediaType},
+		{ContentInterpreterKustomize, KustomizeContentMediaType},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.contentInterpreter, func(t *testing.T) {
+			g := NewWithT(t)
+
+			artefact, err := client.BuildArtefactIndex(context.Background(), "example.com/app", dir, tc.contentInterpreter, &timestamp)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer artefact.Cleanup()
+
+			content, err := artefact.Index.Image(artefact.ContentDigest)
+			g.Expect(err).NotTo(HaveOccurred())
+			manifest, err := content.Manifest()
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(manifest.Config.MediaType).To(Equal(tc.expectedMediaType))
+			g.Expect(manifest.Layers).To(HaveLen(1))
+			g.Expect(manifest.Layers[0].MediaType).To(Equal(tc.expectedMediaType))
+			g.Expect(manifest.Annotations).To(HaveKeyWithValue(ContentInterpreterAnnotation, tc.contentInterpreter))
+			g.Expect(IsContentMediaType(manifest.Config.MediaType)).To(BeTrue())
+		})
+	}
+
+	_, err := client.BuildArtefactIndex(context.Background(), "example.com/app", dir, "unknown", &timestamp)
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported content interpreter")))
+}
@@ -0,0 +1,153 @@
+package oci_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ociclient "github.com/fluxcd/pkg/oci"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/gomega"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+func TestAttestationsReferrers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(ctx)).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	image, err := crane.Image(map[string][]byte{"test": []byte(t.Name())})
+	g.Expect(err).NotTo(HaveOccurred())
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: image})
+	indexDigest, err := index.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ref := registry.NewUniqueRepoNamer("oci-referrers-test")("app") + ":test"
+	parsedRef, err := name.ParseReference(ref)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(remote.WriteIndex(parsedRef, index, crane.GetOptions(registry.CraneOptions()...).Remote...)).To(Succeed())
+
+	client := NewClient(registry.CraneOptions())
+
+	artefacts, latestDigest, latestManifest, err := client.FetchLatestReferrer(ctx, ref, indexDigest, AttestMediaType)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(artefacts).To(BeEmpty())
+	g.Expect(latestDigest).To(BeNil())
+	g.Expect(latestManifest).To(BeNil())
+
+	makeStatements := func(name string) []attestTypes.ExportedStatement {
+		return []attestTypes.ExportedStatement{{
+			Type:          attestTypes.CurrentStatementType,
+			PredicateType: "docker.com/tape/Test/v0.1",
+			Subject: []attestTypes.ResourceDescriptor{{
+				Name:   name,
+				Digest: map[string]string{"sha256": fmt.Sprintf("%064d", 1)},
+			}},
+			Predicate: map[string]any{"test": name},
+		}}
+	}
+
+	firstDigest, err := client.PushAttestationsReferrer(ctx, ref, index, makeStatements("first.yaml"), map[string]string{"example.com/test": "first"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	artefacts, latestDigest, latestManifest, err = client.FetchLatestReferrer(ctx, ref, indexDigest, AttestMediaType)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(artefacts).To(HaveLen(1))
+	g.Expect(latestDigest).To(Equal(firstDigest))
+
+	g.Expect(latestManifest.Subject).NotTo(BeNil())
+	g.Expect(latestManifest.Subject.Digest).To(Equal(indexDigest))
+	g.Expect(latestManifest.Annotations).To(HaveKeyWithValue("example.com/test", "first"))
+	g.Expect(latestManifest.Annotations).To(HaveKeyWithValue(AttestationsStatementTypeAnnotation, attestTypes.CurrentStatementType))
+	g.Expect(latestManifest.Annotations).To(HaveKey(ociclient.CreatedAnnotation))
+
+	statements, err := DecodeAttestations(artefacts[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statements).To(HaveLen(1))
+	g.Expect(statements[0].Subject[0].Name).To(Equal("first.yaml"))
+
+	secondDigest, err := client.PushAttestationsReferrer(ctx, ref, index, makeStatements("second.yaml"), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*secondDigest).NotTo(Equal(*firstDigest))
+
+	referrers, err := remote.Referrers(parsedRef.Context().Digest(indexDigest.String()), crane.GetOptions(registry.CraneOptions()...).Remote...)
+	g.Expect(err).NotTo(HaveOccurred())
+	referrersManifest, err := referrers.IndexManifest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(referrersManifest.Manifests).To(HaveLen(2))
+
+	artefacts, latestDigest, latestManifest, err = client.FetchLatestReferrer(ctx, ref, indexDigest, AttestMediaType)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(latestDigest).To(Equal(secondDigest))
+	g.Expect(latestManifest.Annotations).NotTo(HaveKey("example.com/test"))
+	g.Expect(artefacts).To(HaveLen(1))
+	statements, err = DecodeAttestations(artefacts[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statements[0].Subject[0].Name).To(Equal("second.yaml"))
+}
+
+func TestLatestReferrer(t *testing.T) {
+	hash := func(i int) v1.Hash {
+		return v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%064d", i)}
+	}
+	created := func(t string) *Manifest {
+		return &Manifest{Annotations: map[string]string{ociclient.CreatedAnnotation: t}}
+	}
+
+	testCases := []struct {
+		desc      string
+		manifests map[Hash]*Manifest
+		expected  Hash
+	}{
+		{
+			desc: "newest",
+			manifests: map[Hash]*Manifest{
+				hash(1): created("2024-01-02T00:00:00Z"),
+				hash(2): created("2024-01-01T00:00:00Z"),
+				hash(3): created("2024-01-01T12:00:00.5Z"),
+			},
+			expected: hash(1),
+		},
+		{
+			desc: "same time",
+			manifests: map[Hash]*Manifest{
+				hash(1): created("2024-01-01T00:00:00Z"),
+				hash(3): created("2024-01-01T00:00:00Z"),
+				hash(2): created("2024-01-01T00:00:00Z"),
+			},
+			expected: hash(3),
+		},
+		{
+			desc: "without annotation",
+			manifests: map[Hash]*Manifest{
+				hash(1): created("2024-01-01T00:00:00Z"),
+				hash(2): {},
+				hash(3): created("invalid"),
+			},
+			expected: hash(1),
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.desc, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// map iteration order is random, so it's checked a few times
+			for j := 0; j < 10; j++ {
+				g.Expect(LatestReferrer(tc.manifests)).To(Equal(tc.expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	DefaultMaxConcurrencyPerRegistry = 8
+)
+
+// RetryPolicy defines how requests that fail with one of the status codes
+// are retried, Retry-After header takes precedence over exponential backoff
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxRetryAfter is the longest Retry-After that is honoured,
+	// response is returned to the caller when it asks for longer
+	MaxRetryAfter time.Duration
+	StatusCodes   []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxRetryAfter:  time.Minute,
+		StatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (p RetryPolicy) retryable(statusCode int) bool {
+	for _, code := range p.StatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the next attempt, it returns false
+// when server asks to wait longer than the policy allows
+func (p RetryPolicy) backoff(attempt int, retryAfter string) (time.Duration, bool) {
+	if retryAfter != "" {
+		if delay, ok := parseRetryAfter(retryAfter); ok {
+			return delay, delay <= p.MaxRetryAfter
+		}
+	}
+	delay := p.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	// add up to 10% of jitter, so that concurrent requests don't retry at the same time
+	if jitter := int64(delay) / 10; jitter > 0 {
+		delay += time.Duration(rand.Int63n(jitter))
+	}
+	return delay, true
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// WithRetry sets transport that retries requests according to the policy and limits
+// number of concurrent requests to each registry, apart from uploads, a request takes
+// up a slot until its response body is read; as the transport can only be set once,
+// it will be overridden by any other crane.WithTransport option that follows it
+func WithRetry(base http.RoundTripper, policy RetryPolicy, maxConcurrencyPerRegistry int) crane.Option {
+	return func(o *crane.Options) {
+		crane.WithTransport(NewRetryTransport(base, policy, maxConcurrencyPerRegistry))(o)
+		// status codes are retried by the transport, so retries built into remote
+		// are limited to network errors, blob uploads that cannot be retried by the
+		// transport are retried by remote with the same backoff
+		o.Remote = append(o.Remote,
+			remote.WithRetryStatusCodes(),
+			remote.WithRetryBackoff(remote.Backoff{
+				Duration: policy.InitialBackoff,
+				Factor:   2.0,
+				Jitter:   0.1,
+				Steps:    policy.MaxAttempts,
+				Cap:      policy.MaxBackoff,
+			}),
+		)
+	}
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+
+	maxConcurrency int
+	lock           sync.Mutex
+	semaphores     map[string]chan struct{}
+}
+
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy, maxConcurrencyPerRegistry int) http.RoundTripper {
+	if base == nil {
+		base = remote.DefaultTransport
+	}
+	return &retryTransport{
+		base:           base,
+		policy:         policy,
+		maxConcurrency: maxConcurrencyPerRegistry,
+		semaphores:     map[string]chan struct{}{},
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// requests that send a body don't wait for a slot, as the body may be a response
+	// body of another request to the same registry that holds a slot until it's read,
+	// i.e. when a blob is copied within the registry, which would deadlock otherwise
+	if req.Body != nil && req.Body != http.NoBody {
+		return t.roundTrip(req)
+	}
+	release, err := t.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.roundTrip(req)
+	if err != nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	// slot is held until the body is read, as that's what most of the load is
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases the slot once the body is read to the end or closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	// requests with a body that cannot be rewound are only attempted once
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !canRetry || attempt >= t.policy.MaxAttempts || !t.policy.retryable(resp.StatusCode) {
+			return resp, nil
+		}
+		delay, ok := t.policy.backoff(attempt, resp.Header.Get("Retry-After"))
+		if !ok {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		_ = resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (t *retryTransport) acquire(ctx context.Context, host string) (func(), error) {
+	if t.maxConcurrency <= 0 {
+		return func() {}, nil
+	}
+
+	t.lock.Lock()
+	semaphore, ok := t.semaphores[host]
+	if !ok {
+		semaphore = make(chan struct{}, t.maxConcurrency)
+		t.semaphores[host] = semaphore
+	}
+	t.lock.Unlock()
+
+	select {
+	case semaphore <- struct{}{}:
+		return func() { <-semaphore }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
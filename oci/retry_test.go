@@ -0,0 +1,238 @@
+package oci_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+func TestRetry(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	image, err := crane.Image(map[string][]byte{"test": []byte(t.Name())})
+	g.Expect(err).NotTo(HaveOccurred())
+	ref := registry.NewUniqueRepoNamer("oci-retry-test")("image") + ":test"
+	g.Expect(crane.Push(image, ref, registry.CraneOptions()...)).To(Succeed())
+
+	manifests := regexp.MustCompile(`^/v2/.*/manifests/.*$`)
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+	policy.InitialBackoff = 10 * time.Millisecond
+	policy.MaxRetryAfter = 2 * time.Second
+
+	testCases := []struct {
+		description    string
+		fault          trex.Fault
+		expectErr      bool
+		expectInjected int
+		expectMinDelay time.Duration
+	}{
+		{
+			description:    "retry after is honoured",
+			fault:          trex.Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second, Times: 1},
+			expectInjected: 1,
+			expectMinDelay: time.Second,
+		},
+		{
+			description:    "server errors are retried with backoff",
+			fault:          trex.Fault{StatusCode: http.StatusServiceUnavailable, Times: 2},
+			expectInjected: 2,
+		},
+		{
+			description:    "gives up after max attempts",
+			fault:          trex.Fault{StatusCode: http.StatusBadGateway},
+			expectErr:      true,
+			expectInjected: 3,
+		},
+		{
+			description:    "gives up when asked to wait for too long",
+			fault:          trex.Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour},
+			expectErr:      true,
+			expectInjected: 1,
+		},
+		{
+			description:    "other status codes are not retried",
+			fault:          trex.Fault{StatusCode: http.StatusForbidden},
+			expectErr:      true,
+			expectInjected: 1,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tc.fault.Path = manifests
+			tc.fault.Method = http.MethodGet
+			registry.Faults().SetFaults(tc.fault)
+			t.Cleanup(func() { registry.Faults().SetFaults() })
+
+			client := NewClient([]crane.Option{WithRetry(registry.Transport(), policy, 2)})
+
+			start := time.Now()
+			_, _, _, err := client.GetIndexOrImage(context.Background(), ref)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(time.Since(start)).To(BeNumerically(">=", tc.expectMinDelay))
+			g.Expect(registry.Faults().Injected()).To(Equal([]int{tc.expectInjected}))
+		})
+	}
+}
+
+func TestRetryTransportConcurrency(t *testing.T) {
+	g := NewWithT(t)
+
+	active, maxActive := atomic.Int32{}, atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, DefaultRetryPolicy(), 2)}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	g.Expect(maxActive.Load()).To(BeNumerically("==", 2))
+}
+
+func TestRetryTransportHoldsSlotUntilBodyIsRead(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, DefaultRetryPolicy(), 1)}
+
+	get := func() <-chan *http.Response {
+		result := make(chan *http.Response, 1)
+		go func() {
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				result <- resp
+			}
+		}()
+		return result
+	}
+
+	var first *http.Response
+	g.Eventually(get()).Should(Receive(&first))
+
+	second := get()
+	g.Consistently(second, 100*time.Millisecond).ShouldNot(Receive())
+	g.Expect(first.Body.Close()).To(Succeed())
+	var resp *http.Response
+	g.Eventually(second).Should(Receive(&resp))
+
+	// reading the body to the end releases the slot too
+	_, err := io.ReadAll(resp.Body)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Eventually(get()).Should(Receive(&resp))
+	g.Expect(resp.Body.Close()).To(Succeed())
+}
+
+func TestReferenceErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	errs := ReferenceErrors{}
+	g.Expect(errs.ErrorOrNil()).To(BeNil())
+
+	notFound := errors.New("not found")
+	errs.Append("example.com/a:v1", notFound)
+	g.Expect(errs.ErrorOrNil()).To(MatchError("example.com/a:v1: not found"))
+
+	errs.Append("example.com/b:v1", fmt.Errorf("denied"))
+	errs.Append("example.com/a:v1", notFound)
+	g.Expect(errs.ErrorOrNil()).To(MatchError("failed on 2 references:\n  example.com/a:v1: not found\n  example.com/b:v1: denied"))
+	g.Expect(errors.Is(errs.ErrorOrNil(), notFound)).To(BeTrue())
+}
+
+func TestRetryTransportSameRegistryCopy(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	image, err := crane.Image(map[string][]byte{
+		"a": []byte(t.Name() + "a"),
+		"b": []byte(t.Name() + "b"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	digest, err := image.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	namer := registry.NewUniqueRepoNamer("oci-retry-copy-test")
+	srcRef, dstRef := namer("source")+":test", namer("destination")+":test"
+	g.Expect(crane.Push(image, srcRef, registry.CraneOptions()...)).To(Succeed())
+
+	// source blobs are read while being written to the destination, so with one slot
+	// per registry the copy can only complete when the slot is not held by response body;
+	// cross-repository mounts are disabled, as otherwise blobs are not read at all
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if query := req.URL.Query(); query.Has("mount") {
+			query.Del("mount")
+			query.Del("from")
+			req = req.Clone(req.Context())
+			req.URL.RawQuery = query.Encode()
+		}
+		return registry.Transport().RoundTrip(req)
+	})
+	client := NewClient([]crane.Option{WithRetry(transport, DefaultRetryPolicy(), 1)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	g.Expect(client.Copy(ctx, srcRef, dstRef, digest.String())).To(Succeed())
+
+	copied, err := crane.Digest(dstRef, registry.CraneOptions()...)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(copied).To(Equal(digest.String()))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,134 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	// SimpleSigningMediaType and SignatureAnnotation are used by cosign for layers
+	// of signature images, which are tagged as '<algorithm>-<hex>.sig'
+	SimpleSigningMediaType MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	SignatureAnnotation              = "dev.cosignproject.cosign/signature"
+)
+
+// SignatureTag returns the tag that cosign signatures of the given digest are stored at
+func SignatureTag(ref string, digest Hash) (string, error) {
+	parsedRef, err := name.ParseReference(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", ref, err)
+	}
+	return parsedRef.Context().Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex)).String(), nil
+}
+
+// VerifySignatures checks cosign signatures of the given digest with a public key,
+// only the payload signature is checked, there are no checks of certificates or
+// transparency logs; it returns the number of valid signatures, and an error when
+// there are none
+func (c *Client) VerifySignatures(ctx context.Context, ref string, digest Hash, key crypto.PublicKey) (int, error) {
+	tag, err := SignatureTag(ref, digest)
+	if err != nil {
+		return 0, err
+	}
+	image, err := c.Pull(ctx, tag)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get signatures from %q: %w", tag, err)
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("unable to get manifest of %q: %w", tag, err)
+	}
+
+	errs := []error{}
+	valid := 0
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != SimpleSigningMediaType {
+			continue
+		}
+		if err := c.verifySignatureLayer(image, layer, digest, key); err != nil {
+			errs = append(errs, fmt.Errorf("signature in layer %s: %w", layer.Digest, err))
+			continue
+		}
+		valid++
+	}
+	if valid == 0 {
+		if len(errs) == 0 {
+			return 0, fmt.Errorf("no signatures found in %q", tag)
+		}
+		return 0, fmt.Errorf("no valid signatures found in %q: %w", tag, errors.Join(errs...))
+	}
+	return valid, nil
+}
+
+func (c *Client) verifySignatureLayer(image Image, layer Descriptor, digest Hash, key crypto.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(layer.Annotations[SignatureAnnotation])
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("missing or invalid %q annotation", SignatureAnnotation)
+	}
+	blob, err := image.LayerByDigest(layer.Digest)
+	if err != nil {
+		return err
+	}
+	r, err := blob.Compressed()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignature(key, payload, signature); err != nil {
+		return err
+	}
+
+	simpleSigning := struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}{}
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	if signed := simpleSigning.Critical.Image.DockerManifestDigest; signed != digest.String() {
+		return fmt.Errorf("payload is signed for %q, not %q", signed, digest.String())
+	}
+	return nil
+}
+
+func verifySignature(key crypto.PublicKey, payload, signature []byte) error {
+	hash := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type of key %T", key)
+	}
+}
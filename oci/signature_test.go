@@ -0,0 +1,111 @@
+package oci_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+// payloadLayer holds payload as is, like cosign does
+type payloadLayer []byte
+
+func (l payloadLayer) Digest() (v1.Hash, error) {
+	hash, _, err := v1.SHA256(bytes.NewReader(l))
+	return hash, err
+}
+func (l payloadLayer) DiffID() (v1.Hash, error) { return l.Digest() }
+func (l payloadLayer) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(l)), nil
+}
+func (l payloadLayer) Uncompressed() (io.ReadCloser, error) { return l.Compressed() }
+func (l payloadLayer) Size() (int64, error)                 { return int64(len(l)), nil }
+func (l payloadLayer) MediaType() (types.MediaType, error)  { return SimpleSigningMediaType, nil }
+
+func TestVerifySignatures(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	registry := trex.NewWithOptions(trex.Options{})
+	g.Expect(registry.Start(ctx)).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	image, err := crane.Image(map[string][]byte{"test": []byte(t.Name())})
+	g.Expect(err).NotTo(HaveOccurred())
+	digest, err := image.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+	otherDigest, err := v1.NewHash("sha256:" + fmt.Sprintf("%064d", 0))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ref := registry.NewUniqueRepoNamer("oci-signatures-test")("app") + ":test"
+	g.Expect(crane.Push(image, ref, registry.CraneOptions()...)).To(Succeed())
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sign := func(signedDigest v1.Hash, key crypto.Signer) {
+		payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"app"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"}}`, signedDigest))
+		hash := sha256.Sum256(payload)
+		var signature []byte
+		var err error
+		switch key.(type) {
+		case ed25519.PrivateKey:
+			signature, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+		default:
+			signature, err = key.Sign(rand.Reader, hash[:], crypto.SHA256)
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+
+		signatures, err := mutate.Append(empty.Image, mutate.Addendum{
+			Layer:       payloadLayer(payload),
+			MediaType:   SimpleSigningMediaType,
+			Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		tag, err := SignatureTag(ref, digest)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(crane.Push(signatures, tag, registry.CraneOptions()...)).To(Succeed())
+	}
+
+	client := NewClient(registry.CraneOptions())
+
+	_, err = client.VerifySignatures(ctx, ref, digest, ecdsaKey.Public())
+	g.Expect(err).To(MatchError(ContainSubstring("unable to get signatures")))
+
+	sign(digest, ecdsaKey)
+	numSignatures, err := client.VerifySignatures(ctx, ref, digest, ecdsaKey.Public())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(numSignatures).To(Equal(1))
+
+	_, err = client.VerifySignatures(ctx, ref, digest, ed25519Key.Public())
+	g.Expect(err).To(MatchError(ContainSubstring("invalid signature")))
+
+	sign(digest, ed25519Key)
+	numSignatures, err = client.VerifySignatures(ctx, ref, digest, ed25519Key.Public())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(numSignatures).To(Equal(1))
+
+	sign(otherDigest, ecdsaKey)
+	_, err = client.VerifySignatures(ctx, ref, digest, ecdsaKey.Public())
+	g.Expect(err).To(MatchError(ContainSubstring("payload is signed for")))
+}
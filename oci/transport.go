@@ -0,0 +1,108 @@
+package oci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// TransportOptions configure how registries are reached, registries are
+// given as host with an optional port, e.g. registry.example.com:5000
+type TransportOptions struct {
+	// CAFiles contain PEM-encoded certificates that are trusted
+	// in addition to the system pool
+	CAFiles []string
+	// InsecureRegistries are reached over TLS without verifying certificates
+	InsecureRegistries []string
+	// PlainHTTPRegistries are reached over plain HTTP
+	PlainHTTPRegistries []string
+}
+
+// NewTransport returns a transport that applies the options on top of base,
+// remote.DefaultTransport is used when base is nil
+func NewTransport(base *http.Transport, options TransportOptions) (http.RoundTripper, error) {
+	if base == nil {
+		base = remote.DefaultTransport.(*http.Transport)
+	}
+
+	secure := base.Clone()
+	if len(options.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range options.CAFiles {
+			data, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in CA file %q", caFile)
+			}
+		}
+		if secure.TLSClientConfig == nil {
+			secure.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		secure.TLSClientConfig.RootCAs = pool
+	}
+
+	insecure := secure.Clone()
+	if insecure.TLSClientConfig == nil {
+		insecure.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	insecure.TLSClientConfig.InsecureSkipVerify = true
+
+	t := &registryTransport{
+		secure:    secure,
+		insecure:  insecure,
+		skipTLS:   map[string]struct{}{},
+		plainHTTP: map[string]struct{}{},
+	}
+	for _, registry := range options.InsecureRegistries {
+		host, err := registryHost(registry)
+		if err != nil {
+			return nil, err
+		}
+		t.skipTLS[host] = struct{}{}
+	}
+	for _, registry := range options.PlainHTTPRegistries {
+		host, err := registryHost(registry)
+		if err != nil {
+			return nil, err
+		}
+		t.plainHTTP[host] = struct{}{}
+	}
+	return t, nil
+}
+
+func registryHost(registry string) (string, error) {
+	r, err := name.NewRegistry(registry)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	return r.RegistryStr(), nil
+}
+
+type registryTransport struct {
+	secure, insecure   http.RoundTripper
+	skipTLS, plainHTTP map[string]struct{}
+}
+
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := t.plainHTTP[req.URL.Host]; ok && req.URL.Scheme == "https" {
+		// registry client always tries HTTPS first, unless the registry is on
+		// a local network, so the scheme is rewritten for registries that don't
+		// serve TLS at all
+		req = req.Clone(req.Context())
+		req.URL.Scheme = "http"
+	}
+	if _, ok := t.skipTLS[req.URL.Host]; ok {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
@@ -0,0 +1,119 @@
+package oci_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/trex"
+)
+
+func TestTransport(t *testing.T) {
+	g := NewWithT(t)
+
+	tlsRegistry := trex.NewWithOptions(trex.Options{})
+	g.Expect(tlsRegistry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = tlsRegistry.Close() })
+
+	plainRegistry := trex.NewWithOptions(trex.Options{PlainHTTP: true})
+	g.Expect(plainRegistry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = plainRegistry.Close() })
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	g.Expect(os.WriteFile(caFile, tlsRegistry.CACert(), 0o600)).To(Succeed())
+
+	push := func(registry *trex.Trex) string {
+		image, err := crane.Image(map[string][]byte{"test": []byte(t.Name())})
+		g.Expect(err).NotTo(HaveOccurred())
+		ref := registry.NewUniqueRepoNamer("oci-transport-test")("image") + ":test"
+		g.Expect(crane.Push(image, ref, registry.CraneOptions()...)).To(Succeed())
+		return ref
+	}
+
+	tlsRef := push(tlsRegistry)
+	plainRef := push(plainRegistry)
+
+	// registries on loopback addresses are reached over plain HTTP as a fallback,
+	// so a name that resolves to the plain registry is used instead
+	plainHost := "plain.registry.test:" + strings.Split(plainRegistry.Addr(), ":")[1]
+	plainRef = plainHost + strings.TrimPrefix(plainRef, plainRegistry.Addr())
+	base := remote.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == plainHost {
+			addr = plainRegistry.Addr()
+		}
+		return remote.DefaultTransport.(*http.Transport).DialContext(ctx, network, addr)
+	}
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 1
+
+	testCases := []struct {
+		description string
+		ref         string
+		options     TransportOptions
+		expectErr   string
+	}{
+		{
+			description: "untrusted CA",
+			ref:         tlsRef,
+			expectErr:   "certificate signed by unknown authority",
+		},
+		{
+			description: "custom CA",
+			ref:         tlsRef,
+			options:     TransportOptions{CAFiles: []string{caFile}},
+		},
+		{
+			description: "insecure registry",
+			ref:         tlsRef,
+			options:     TransportOptions{InsecureRegistries: []string{tlsRegistry.Addr()}},
+		},
+		{
+			description: "insecure registry doesn't affect other registries",
+			ref:         tlsRef,
+			options:     TransportOptions{InsecureRegistries: []string{"registry.example.com"}},
+			expectErr:   "certificate signed by unknown authority",
+		},
+		{
+			description: "plain HTTP registry",
+			ref:         plainRef,
+			options:     TransportOptions{PlainHTTPRegistries: []string{plainHost}},
+		},
+		{
+			description: "plain HTTP registry without the option",
+			ref:         plainRef,
+			expectErr:   "http: server gave HTTP response to HTTPS client",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			transport, err := NewTransport(base, tc.options)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			client := NewClient([]crane.Option{WithRetry(transport, policy, 1)})
+			_, err = client.Digest(context.Background(), tc.ref)
+			if tc.expectErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.expectErr)))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	_, err := NewTransport(nil, TransportOptions{CAFiles: []string{filepath.Join(t.TempDir(), "missing.crt")}})
+	g.Expect(err).To(MatchError(ContainSubstring("unable to read CA file")))
+}
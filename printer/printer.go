@@ -0,0 +1,160 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+type Format string
+
+const (
+	FormatDetailedText Format = "detailed-text"
+	FormatText         Format = "text"
+	FormatDirectJSON   Format = "direct-json"
+	FormatJSON         Format = "json"
+	FormatYAML         Format = "yaml"
+	FormatTable        Format = "table"
+	FormatTemplate     Format = "template"
+)
+
+// Formats lists all supported formats, template format takes
+// the template as an argument, e.g. 'template={{.Ref}}'
+var Formats = []Format{
+	FormatDetailedText,
+	FormatText,
+	FormatDirectJSON,
+	FormatJSON,
+	FormatYAML,
+	FormatTable,
+	FormatTemplate,
+}
+
+type (
+	// Schema identifies the structure of items of an output, version changes
+	// when fields are removed or renamed, new fields may be added at any time
+	Schema struct {
+		Kind    string
+		Version string
+	}
+
+	// Output is implemented by results of each of the commands
+	Output interface {
+		Schema() Schema
+		// Items returns items that are encoded in JSON and YAML formats,
+		// and that templates are executed with
+		Items() []any
+		// Table returns column names and a row for each item
+		Table() ([]string, [][]string)
+		// Text writes human-readable output, which may have more details
+		Text(w io.Writer, detailed bool) error
+	}
+
+	// List is what JSON and YAML formats consist of
+	List struct {
+		Kind    string `json:"kind"`
+		Version string `json:"version"`
+		Items   []any  `json:"items"`
+	}
+
+	Printer struct {
+		format   Format
+		template *template.Template
+	}
+)
+
+// NewPrinter parses the format, which is one of Formats, and in case of
+// template format must include the template after '='
+func NewPrinter(format string) (*Printer, error) {
+	name, arg, hasArg := strings.Cut(format, "=")
+	p := &Printer{format: Format(name)}
+	switch p.format {
+	case FormatTemplate:
+		if !hasArg || arg == "" {
+			return nil, fmt.Errorf("template format requires a template, e.g. 'template={{.Ref}}'")
+		}
+		tmpl, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		p.template = tmpl
+	case FormatDetailedText, FormatText, FormatDirectJSON, FormatJSON, FormatYAML, FormatTable:
+		if hasArg {
+			return nil, fmt.Errorf("format %q doesn't take arguments", name)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported output format %q, must be one of %v", format, Formats)
+	}
+	return p, nil
+}
+
+func (p *Printer) Format() Format { return p.format }
+
+func (p *Printer) Print(w io.Writer, output Output) error {
+	switch p.format {
+	case FormatDetailedText, FormatText:
+		return output.Text(w, p.format == FormatDetailedText)
+	case FormatDirectJSON:
+		// items are encoded as they are, without a list, this is what
+		// older versions printed, so it's kept for compatibility
+		enc := json.NewEncoder(w)
+		for _, item := range output.Items() {
+			if err := enc.Encode(item); err != nil {
+				return fmt.Errorf("failed to marshal output: %w", err)
+			}
+		}
+		return nil
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(NewList(output)); err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		return nil
+	case FormatYAML:
+		data, err := yaml.Marshal(NewList(output))
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatTable:
+		columns, rows := output.Table()
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case FormatTemplate:
+		for _, item := range output.Items() {
+			if err := p.template.Execute(w, item); err != nil {
+				return fmt.Errorf("failed to execute template: %w", err)
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", p.format)
+	}
+}
+
+func NewList(output Output) List {
+	schema := output.Schema()
+	items := output.Items()
+	if items == nil {
+		items = []any{}
+	}
+	return List{
+		Kind:    schema.Kind,
+		Version: schema.Version,
+		Items:   items,
+	}
+}
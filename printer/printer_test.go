@@ -0,0 +1,129 @@
+package printer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	. "github.com/errordeveloper/tape/printer"
+)
+
+type testItem struct {
+	Ref   string `json:"ref"`
+	Count int    `json:"count"`
+}
+
+type testOutput []testItem
+
+func (o testOutput) Schema() Schema { return Schema{Kind: "Test", Version: "v1"} }
+
+func (o testOutput) Items() []any {
+	items := make([]any, len(o))
+	for i := range o {
+		items[i] = o[i]
+	}
+	return items
+}
+
+func (o testOutput) Table() ([]string, [][]string) {
+	rows := [][]string{}
+	for _, item := range o {
+		rows = append(rows, []string{item.Ref, fmt.Sprint(item.Count)})
+	}
+	return []string{"REF", "COUNT"}, rows
+}
+
+func (o testOutput) Text(w io.Writer, detailed bool) error {
+	for _, item := range o {
+		fmt.Fprintf(w, "%s\n", item.Ref)
+		if detailed {
+			fmt.Fprintf(w, "  Count: %d\n", item.Count)
+		}
+	}
+	return nil
+}
+
+func TestPrinter(t *testing.T) {
+	output := testOutput{
+		{Ref: "example.com/app:v1", Count: 1},
+		{Ref: "example.com/app:v2", Count: 20},
+	}
+	expectedList := map[string]any{
+		"kind":    "Test",
+		"version": "v1",
+		"items": []any{
+			map[string]any{"ref": "example.com/app:v1", "count": float64(1)},
+			map[string]any{"ref": "example.com/app:v2", "count": float64(20)},
+		},
+	}
+
+	testCases := []struct {
+		format   string
+		expected string
+		decode   func([]byte, any) error
+	}{
+		{
+			format:   "detailed-text",
+			expected: "example.com/app:v1\n  Count: 1\nexample.com/app:v2\n  Count: 20\n",
+		},
+		{
+			format:   "text",
+			expected: "example.com/app:v1\nexample.com/app:v2\n",
+		},
+		{
+			format:   "direct-json",
+			expected: "{\"ref\":\"example.com/app:v1\",\"count\":1}\n{\"ref\":\"example.com/app:v2\",\"count\":20}\n",
+		},
+		{
+			format: "json",
+			decode: json.Unmarshal,
+		},
+		{
+			format: "yaml",
+			decode: func(data []byte, obj any) error { return yaml.Unmarshal(data, obj) },
+		},
+		{
+			format:   "table",
+			expected: "REF                 COUNT\nexample.com/app:v1  1\nexample.com/app:v2  20\n",
+		},
+		{
+			format:   "template={{.Ref}} {{.Count}}",
+			expected: "example.com/app:v1 1\nexample.com/app:v2 20\n",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.format, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p, err := NewPrinter(tc.format)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			buf := &bytes.Buffer{}
+			g.Expect(p.Print(buf, output)).To(Succeed())
+
+			if tc.decode == nil {
+				g.Expect(buf.String()).To(Equal(tc.expected))
+				return
+			}
+			list := map[string]any{}
+			g.Expect(tc.decode(buf.Bytes(), &list)).To(Succeed())
+			g.Expect(list).To(Equal(expectedList))
+		})
+	}
+
+	t.Run("invalid formats", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, format := range []string{"xml", "template", "template=", "template={{.Ref", "yaml=x"} {
+			_, err := NewPrinter(format)
+			g.Expect(err).To(HaveOccurred(), format)
+		}
+	})
+}
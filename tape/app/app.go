@@ -2,40 +2,202 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 
+	"github.com/google/go-containerregistry/pkg/crane"
 	flags "github.com/thought-machine/go-flags"
 
+	"github.com/errordeveloper/tape/attest"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
+	"github.com/errordeveloper/tape/events"
 	"github.com/errordeveloper/tape/logger"
+	"github.com/errordeveloper/tape/manifest/imagescanner"
+	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/printer"
 )
 
-type OutputFormat string
+type TapeCommand struct {
+	LogLevel  string `short:"l" long:"log-level" description:"Log level" default:"info"`
+	LogFormat string `long:"log-format" description:"Format of log messages" choice:"text" choice:"json" default:"text"`
 
-const (
-	OutputFormatDetailedText OutputFormat = "detailed-text"
-	OutputFormatText         OutputFormat = "text"
-	OutputFormatDirectJSON   OutputFormat = "direct-json"
-)
+	EventsFile string `long:"events-file" description:"Path to a file to write progress events to as JSON lines"`
 
-type TapeCommand struct {
-	LogLevel string `short:"l" long:"log-level" description:"Log level" default:"info"`
+	RegistryMaxAttempts    int `long:"registry-max-attempts" description:"Number of attempts for registry requests that fail with a retryable status code" default:"5"`
+	RegistryMaxConcurrency int `long:"registry-max-concurrency" description:"Maximum number of concurrent requests to each registry" default:"8"`
+
+	RegistryMirrors string `long:"registry-mirrors" description:"Path to a YAML file with registry mirror configuration"`
+
+	RegistryCAFiles     []string `long:"registry-ca-file" description:"Path to a PEM-encoded CA certificate to trust in addition to system ones, can be repeated"`
+	InsecureRegistries  []string `long:"insecure-registry" description:"Registry host to connect to without verifying its TLS certificate, can be repeated"`
+	PlainHTTPRegistries []string `long:"plain-http-registry" description:"Registry host to connect to over plain HTTP, can be repeated"`
 
-	log *logger.Logger
-	ctx context.Context
+	log       *logger.Logger
+	ctx       context.Context
+	mirrors   *oci.MirrorConfig
+	transport http.RoundTripper
+	events    *events.Emitter
+	closers   []io.Closer
 }
 
 type OutputFormatOptions struct {
-	OutputFormat OutputFormat `short:"o" long:"output-format" description:"Format of the output to use" default:"detailed-text"`
+	OutputFormat string `short:"o" long:"output-format" description:"Format of the output to use, one of detailed-text, text, direct-json, json, yaml, table or template='<go-template>'" default:"detailed-text"`
+}
+
+func (o *OutputFormatOptions) newPrinter() (*printer.Printer, error) {
+	return printer.NewPrinter(o.OutputFormat)
 }
 
 type InputManifestDirOptions struct {
-	ManifestDir string `short:"D" long:"manifest-dir" description:"Intput directory to read manifests from" required:"true"`
+	ManifestDir []string `short:"D" long:"manifest-dir" description:"Input directory or file to read manifests from, '-' for stdin, or a remote git repo as 'git+https://<repo>//<path>?ref=<ref>'; can be repeated, in which case each is placed in the artefact under its base name" required:"true"`
+	ContentKind string   `long:"content-kind" description:"Kind of content in the manifest directory, Helm charts and kustomize bases are packaged unrendered" choice:"auto" choice:"manifests" choice:"helm-chart" choice:"kustomize" default:"auto"`
+
+	Include []string `long:"include" description:"Only load files matching given glob (gitignore syntax), can be repeated"`
+	Exclude []string `long:"exclude" description:"Exclude files matching given glob (gitignore syntax) in addition to those listed in .tapeignore, can be repeated"`
+
+	HelmValuesImagePaths []string `long:"helm-values-image-path" description:"Path to images in Helm values, optionally with names of fields the reference is split across, e.g. 'controller.image:repository=repo,tag=version' (default: '**.image')"`
+
+	// sources holds local copies of each manifest dir that had to be fetched,
+	// it's nil for local paths
+	sources []*loader.Source
+}
+
+const contentKindAuto = "auto"
+
+// newLoader returns a loader for the kind of content in manifest directory,
+// which is detected unless it was set explicitly; charts and kustomize bases
+// can only be loaded from a single directory
+func (o *InputManifestDirOptions) newLoader(ctx context.Context, tape *TapeCommand) (loader.Loader, loader.ContentKind, error) {
+	paths, err := o.fetchSources(ctx, tape)
+	if err != nil {
+		return nil, "", err
+	}
+	kind := loader.ContentKind(o.ContentKind)
+	if len(paths) > 1 {
+		if o.ContentKind != contentKindAuto && kind != loader.ContentKindManifests {
+			return nil, "", fmt.Errorf("content of kind %s must be loaded from a single directory", kind)
+		}
+		manifestLoader := loader.NewMultiPathLoader(paths...)
+		manifestLoader.WithFilters(o.Include, o.Exclude)
+		return manifestLoader, loader.ContentKindManifests, nil
+	}
+	if o.ContentKind == contentKindAuto || o.ContentKind == "" {
+		kind = loader.ContentKindManifests
+		if fi, err := os.Stat(paths[0]); err == nil && fi.IsDir() {
+			detectedKind, err := loader.DetectContentKind(paths[0])
+			if err != nil {
+				return nil, "", err
+			}
+			kind = detectedKind
+		}
+	}
+	manifestLoader := loader.NewRecursiveDirectoryLoader(paths[0], kind)
+	manifestLoader.WithFilters(o.Include, o.Exclude)
+	return manifestLoader, kind, nil
+}
+
+// fetchSources reads stdin and clones remote git repos, and returns local paths
+// for all manifest dirs, local copies are removed when the command exits
+func (o *InputManifestDirOptions) fetchSources(ctx context.Context, tape *TapeCommand) ([]string, error) {
+	paths := make([]string, len(o.ManifestDir))
+	o.sources = make([]*loader.Source, len(o.ManifestDir))
+	readStdin := false
+	for i, path := range o.ManifestDir {
+		if !loader.IsRemoteSource(path) {
+			paths[i] = path
+			continue
+		}
+		if path == loader.StdinPath {
+			if readStdin {
+				return nil, fmt.Errorf("stdin can only be read once")
+			}
+			readStdin = true
+		}
+		source, err := loader.FetchSource(ctx, path, os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		tape.closers = append(tape.closers, source)
+		if source.Digest != "" {
+			tape.log.Infof("read manifests from stdin with digest %s", source.Digest)
+		} else {
+			tape.log.Infof("cloned %q at commit %s", source.URL, source.Commit)
+		}
+		o.sources[i] = source
+		paths[i] = source.Path
+	}
+	return paths, nil
+}
+
+// attestRoots returns roots for provenance, roots that were read from stdin or cloned
+// are recorded with where these came from, since a local copy has no such information;
+// roots of the loader correspond to manifest dirs
+func (o *InputManifestDirOptions) attestRoots(roots []loader.Root) []attest.Root {
+	attestRoots := make([]attest.Root, len(roots))
+	for i := range roots {
+		attestRoots[i] = attest.Root{
+			Path:   roots[i].Path,
+			Prefix: roots[i].Prefix,
+			IsDir:  roots[i].IsDir,
+		}
+		if i >= len(o.sources) || o.sources[i] == nil {
+			continue
+		}
+		source := o.sources[i]
+		if source.Digest != "" {
+			attestRoots[i].Stdin = true
+			attestRoots[i].Source = dir.Source{URL: "stdin", Revision: source.Digest}
+		} else {
+			attestRoots[i].Source = dir.Source{URL: source.URL, Revision: source.Commit}
+		}
+	}
+	return attestRoots
+}
+
+// manifestDirs returns manifest paths as a string for logging
+func (o *InputManifestDirOptions) manifestDirs() string {
+	return strings.Join(o.ManifestDir, ", ")
+}
+
+// configureScanner enables scanning of Helm values when content is a chart
+func (o *InputManifestDirOptions) configureScanner(scanner imagescanner.ImageScanner, kind loader.ContentKind) error {
+	if kind != loader.ContentKindHelmChart {
+		return nil
+	}
+	if len(o.HelmValuesImagePaths) == 0 {
+		scanner.WithHelmValues(imagescanner.DefaultValuesImagePaths()...)
+		return nil
+	}
+	paths := make([]imagescanner.ValuesImagePath, len(o.HelmValuesImagePaths))
+	for i := range o.HelmValuesImagePaths {
+		path, err := imagescanner.ParseValuesImagePath(o.HelmValuesImagePaths[i])
+		if err != nil {
+			return err
+		}
+		paths[i] = path
+	}
+	scanner.WithHelmValues(paths...)
+	return nil
+}
+
+func contentInterpreterFor(kind loader.ContentKind) string {
+	switch kind {
+	case loader.ContentKindHelmChart:
+		return oci.ContentInterpreterHelmChart
+	case loader.ContentKindKustomize:
+		return oci.ContentInterpreterKustomize
+	default:
+		return oci.ContentInterpreterKubectlApply
+	}
 }
 
 type OutputManifestDirOptions struct {
-	ManifestDir string `short:"D" long:"manifest-dir" description:"Output directory to exact manifests" required:"true"`
+	ManifestDir string `short:"D" long:"manifest-dir" description:"Output directory to exact manifests"`
 }
 
 func Run() int {
@@ -47,6 +209,8 @@ func Run() int {
 		ctx: ctx,
 	}
 
+	defer tape.Close()
+
 	fp := flags.NewParser(tape, flags.HelpFlag)
 
 	commands := []struct {
@@ -92,14 +256,40 @@ func Run() int {
 				tape: tape,
 			},
 		},
+		{
+			name:  "registry",
+			short: "Manage a local registry",
+			long: []string{
+				"This command runs a registry with self-signed TLS certificate, which is intended",
+				"to be used for local development and testing",
+			},
+			options: &TapeRegistryCommand{
+				Serve: TapeRegistryServeCommand{
+					tape: tape,
+				},
+			},
+		},
+		{
+			name:  "upgrade",
+			short: "Upgrade attestations of an artefact",
+			long: []string{
+				"This command re-emits attestations of an artefact that was built with an older version",
+				"of in-toto statement format, upgraded attestations are pushed as a referrer, so that",
+				"digest of the artefact remains the same",
+			},
+			options: &TapeUpgradeCommand{
+				tape: tape,
+			},
+		},
 	}
 
 	for _, c := range commands {
-		_, err := fp.AddCommand(c.name, c.short, strings.Join(c.long, "\n"), c.options)
+		command, err := fp.AddCommand(c.name, c.short, strings.Join(c.long, "\n"), c.options)
 		if err != nil {
 			tape.log.Errorf("failed to add %s command: %s", c.name, err)
 			return 1
 		}
+		setValidationDefaults(command)
 	}
 
 	if _, err := fp.Parse(); err != nil {
@@ -119,6 +309,17 @@ func Run() int {
 	return 0
 }
 
+func (c *TapeCommand) NewClient() *oci.Client {
+	retryPolicy := oci.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = c.RegistryMaxAttempts
+	client := oci.NewClient([]crane.Option{
+		oci.WithRetry(c.transport, retryPolicy, c.RegistryMaxConcurrency),
+	})
+	client.SetMirrors(c.mirrors)
+	client.SetEvents(c.events)
+	return client
+}
+
 func (c *TapeCommand) Init() error {
 	if c.log == nil {
 		c.log = logger.New()
@@ -126,5 +327,58 @@ func (c *TapeCommand) Init() error {
 	if err := c.log.SetLevel(c.LogLevel); err != nil {
 		return err
 	}
+	if c.LogFormat != "" {
+		if err := c.log.SetFormat(c.LogFormat); err != nil {
+			return err
+		}
+	}
+	if c.RegistryMaxAttempts < 1 {
+		return fmt.Errorf("--registry-max-attempts must be at least 1")
+	}
+	transport, err := oci.NewTransport(nil, oci.TransportOptions{
+		CAFiles:             c.RegistryCAFiles,
+		InsecureRegistries:  c.InsecureRegistries,
+		PlainHTTPRegistries: c.PlainHTTPRegistries,
+	})
+	if err != nil {
+		return err
+	}
+	c.transport = transport
+	if c.RegistryMirrors != "" {
+		mirrors, err := oci.LoadMirrorConfig(c.RegistryMirrors)
+		if err != nil {
+			return err
+		}
+		c.mirrors = mirrors
+	}
+	if c.EventsFile != "" {
+		eventsFile, err := os.Create(c.EventsFile)
+		if err != nil {
+			return fmt.Errorf("unable to create events file: %w", err)
+		}
+		c.closers = append(c.closers, eventsFile)
+		c.events = events.NewEmitter(events.NewJSONHandler(eventsFile, func(err error) {
+			c.log.Warnf("failed to write event: %s", err)
+		}))
+	}
 	return nil
 }
+
+// Events returns emitter for progress events, it's nil unless --events-file is set
+func (c *TapeCommand) Events() *events.Emitter { return c.events }
+
+func (c *TapeCommand) emitManifestsLoaded(loader loader.Loader) {
+	_, paths := loader.RelPaths()
+	for _, path := range paths {
+		c.events.Emit(events.ManifestLoaded{Path: path})
+	}
+}
+
+func (c *TapeCommand) Close() {
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil {
+			c.log.Warnf("failed to close: %s", err)
+		}
+	}
+	c.closers = nil
+}
@@ -0,0 +1,468 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/errordeveloper/tape/attest/manifest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
+	"github.com/errordeveloper/tape/attest/vcs/git"
+	"github.com/errordeveloper/tape/attest/vcs/hg"
+	"github.com/errordeveloper/tape/attest/vcs/jj"
+	"github.com/errordeveloper/tape/manifest/normaliser"
+)
+
+// vcsEntry is a summary of a path as it's encoded in ManifestDir predicate,
+// only one of the provider-specific fields is set
+type vcsEntry struct {
+	attestTypes.PathCheckSummaryCommon `json:",inline"`
+
+	Git *git.GitSummary `json:"git,omitempty"`
+	Hg  *hg.HgSummary   `json:"hg,omitempty"`
+	JJ  *jj.JJSummary   `json:"jj,omitempty"`
+	Dir *dir.DirSummary `json:"dir,omitempty"`
+}
+
+type sourceDirectory struct {
+	Path       string                `json:"path"`
+	Stdin      bool                  `json:"stdin,omitempty"`
+	Roots      []manifest.SourceRoot `json:"roots,omitempty"`
+	VCSEntries struct {
+		EntryGroups [][]vcsEntry `json:"entryGroups"`
+	} `json:"vcsEntries"`
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// provider returns name of the provider of whichever field is set, providers listed
+// in the collection are a sorted set, so these don't correspond to entry groups
+func (e vcsEntry) provider() string {
+	switch {
+	case e.Git != nil:
+		return git.ProviderName
+	case e.Hg != nil:
+		return hg.ProviderName
+	case e.JJ != nil:
+		return jj.ProviderName
+	case e.Dir != nil:
+		return dir.ProviderName
+	default:
+		return "unknown"
+	}
+}
+
+// modifiedFiles returns paths that were modified according to the VCS, paths
+// that are not tracked by any VCS have nothing to compare to, so these are not
+// considered modified
+func (e vcsEntry) modifiedFiles() []string {
+	switch {
+	case e.Git != nil && len(e.Git.ModifiedFiles) != 0:
+		return e.Git.ModifiedFiles
+	case e.Hg != nil && len(e.Hg.ModifiedFiles) != 0:
+		return e.Hg.ModifiedFiles
+	case e.JJ != nil && len(e.JJ.ModifiedFiles) != 0:
+		return e.JJ.ModifiedFiles
+	case e.Dir != nil || e.Unmodified:
+		return nil
+	default:
+		return []string{e.Path}
+	}
+}
+
+type imageRefRow struct {
+	file                         string
+	line, column                 int
+	original, resolved, replaced string
+}
+
+type imageRefKey struct {
+	file         string
+	line, column int
+}
+
+// objectScope is the range of lines of an object in the original manifest
+type objectScope struct {
+	file       string
+	start, end int
+}
+
+func decodePredicate(statement attestTypes.ExportedStatement, predicate any) error {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(statement.Predicate); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(buf).Decode(predicate); err != nil {
+		return fmt.Errorf("unable to decode predicate of type %q: %w", statement.PredicateType, err)
+	}
+	return nil
+}
+
+func decodeSourceDirectories(statements []attestTypes.ExportedStatement) ([]sourceDirectory, error) {
+	dirs := []sourceDirectory{}
+	for _, statement := range statements {
+		// older artefacts have the same layout, apart from names of subjects
+		if statement.PredicateType != manifest.ManifestDirPredicateType && statement.PredicateType != manifest.ManifestDirV02PredicateType {
+			continue
+		}
+		predicate := struct {
+			sourceDirectory `json:"containedInDirectory"`
+		}{}
+		if err := decodePredicate(statement, &predicate); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, predicate.sourceDirectory)
+	}
+	return dirs, nil
+}
+
+// decodeImageRefs joins original, resolved and replaced references by location; replaced
+// references may be in a different file, i.e. when manifests were normalised or when these
+// are set by kustomization, such references are joined by digest, and normalised files are
+// mapped to the objects of the original manifests they were written from
+func decodeImageRefs(statements []attestTypes.ExportedStatement) ([]imageRefRow, error) {
+	fields := map[string]string{
+		manifest.OriginalImageRefPredicateType: "foundImageReference",
+		manifest.ResolvedImageRefPredicateType: "resolvedImageReference",
+		manifest.ReplacedImageRefPredicateType: "replacedImageReference",
+	}
+	rows := map[imageRefKey]*imageRefRow{}
+	replaced := []*imageRefRow{}
+	scopes := map[string]objectScope{}
+	for _, statement := range statements {
+		if len(statement.Subject) == 0 {
+			continue
+		}
+		if statement.PredicateType == manifest.ManifestNormalisationPredicateType {
+			predicate := struct {
+				manifest.NormalisedObjects `json:"normalisedObjects"`
+			}{}
+			if err := decodePredicate(statement, &predicate); err != nil {
+				return nil, err
+			}
+			addObjectScopes(scopes, statement.Subject[0].Name, predicate.Objects)
+			continue
+		}
+		field, ok := fields[statement.PredicateType]
+		if !ok {
+			continue
+		}
+		predicate := map[string]manifest.ImageRefenceWithLocation{}
+		if err := decodePredicate(statement, &predicate); err != nil {
+			return nil, err
+		}
+		ref, ok := predicate[field]
+		if !ok {
+			continue
+		}
+		key := imageRefKey{file: statement.Subject[0].Name, line: ref.Line, column: ref.Column}
+		if statement.PredicateType == manifest.ReplacedImageRefPredicateType {
+			replaced = append(replaced, &imageRefRow{file: key.file, line: key.line, column: key.column, replaced: ref.Reference})
+			continue
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &imageRefRow{file: key.file, line: key.line, column: key.column}
+			rows[key] = row
+		}
+		switch statement.PredicateType {
+		case manifest.OriginalImageRefPredicateType:
+			row.original = ref.Reference
+		case manifest.ResolvedImageRefPredicateType:
+			row.resolved = ref.Reference
+		}
+	}
+
+	result := make([]*imageRefRow, 0, len(rows)+len(replaced))
+	for _, row := range rows {
+		result = append(result, row)
+	}
+	sortImageRefRows(result)
+	sortImageRefRows(replaced)
+
+	for _, ref := range replaced {
+		if row, ok := rows[imageRefKey{file: ref.file, line: ref.line, column: ref.column}]; ok {
+			row.replaced = ref.replaced
+			continue
+		}
+		if !joinByDigest(result, ref, scopes) {
+			result = append(result, ref)
+		}
+	}
+	sortImageRefRows(result)
+
+	values := make([]imageRefRow, len(result))
+	for i := range result {
+		values[i] = *result[i]
+	}
+	return values, nil
+}
+
+// addObjectScopes records range of lines of each of the objects, it ends
+// where the next object in the same manifest starts
+func addObjectScopes(scopes map[string]objectScope, file string, objects []normaliser.Object) {
+	objects = slices.Clone(objects)
+	slices.SortFunc(objects, func(a, b normaliser.Object) int { return a.Line - b.Line })
+	for i, object := range objects {
+		end := math.MaxInt
+		if i+1 < len(objects) {
+			end = objects[i+1].Line
+		}
+		scopes[object.Path] = objectScope{file: file, start: object.Line, end: end}
+	}
+}
+
+// joinByDigest sets replaced reference of rows that were resolved to the same digest, for a normalised
+// file only the first of the rows of the object that it was written from is set, as each reference in
+// the object has a counterpart, otherwise all rows are set, as a kustomization entry applies to all of
+// the matching references; it returns false when no rows were resolved to the digest
+func joinByDigest(rows []*imageRefRow, replaced *imageRefRow, scopes map[string]objectScope) bool {
+	digest := imageRefDigest(replaced.replaced)
+	if digest == "" {
+		return false
+	}
+	scope, scoped := scopes[replaced.file]
+	matched := false
+	for _, row := range rows {
+		if imageRefDigest(row.resolved) != digest {
+			continue
+		}
+		if scoped && (row.file != scope.file || row.line < scope.start || row.line >= scope.end) {
+			continue
+		}
+		matched = true
+		if row.replaced == "" {
+			row.replaced = replaced.replaced
+			if scoped {
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func imageRefDigest(ref string) string {
+	if _, digest, ok := strings.Cut(ref, "@"); ok {
+		return digest
+	}
+	return ""
+}
+
+func sortImageRefRows(rows []*imageRefRow) {
+	slices.SortFunc(rows, func(a, b *imageRefRow) int {
+		if c := strings.Compare(a.file, b.file); c != 0 {
+			return c
+		}
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return a.column - b.column
+	})
+}
+
+func printDetails(w io.Writer, statements []attestTypes.ExportedStatement) error {
+	dirs, err := decodeSourceDirectories(statements)
+	if err != nil {
+		return err
+	}
+	images, err := decodeImageRefs(statements)
+	if err != nil {
+		return err
+	}
+
+	modified := []string{}
+	for _, sourceDir := range dirs {
+		for _, group := range sourceDir.VCSEntries.EntryGroups {
+			for _, entry := range group {
+				modified = append(modified, entry.modifiedFiles()...)
+			}
+		}
+	}
+	slices.Sort(modified)
+	modified = slices.Compact(modified)
+	if len(modified) != 0 {
+		banner := strings.Repeat("!", 72)
+		fmt.Fprintf(w, "  %s\n", banner)
+		fmt.Fprintf(w, "  WARNING: source was modified, manifests may not match any revision in VCS\n")
+		fmt.Fprintf(w, "  Modified Files:\n")
+		for _, path := range modified {
+			fmt.Fprintf(w, "    %s\n", path)
+		}
+		fmt.Fprintf(w, "  %s\n", banner)
+	}
+
+	for _, sourceDir := range dirs {
+		printSourceDirectory(w, sourceDir)
+	}
+
+	if len(images) != 0 {
+		fmt.Fprintf(w, "  Image References:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "    FILE\tLINE\tORIGINAL\tRESOLVED\tREPLACED\n")
+		for _, row := range images {
+			fmt.Fprintf(tw, "    %s\t%d:%d\t%s\t%s\t%s\n", row.file, row.line, row.column,
+				orDash(row.original), orDash(row.resolved), orDash(row.replaced))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSourceDirectory(w io.Writer, sourceDir sourceDirectory) {
+	fmt.Fprintf(w, "  Source:\n")
+	fmt.Fprintf(w, "    Path: %s%s\n", sourceDir.Path, stdinMarker(sourceDir.Stdin))
+	if len(sourceDir.Roots) != 0 {
+		fmt.Fprintf(w, "    Roots:\n")
+		for _, root := range sourceDir.Roots {
+			fmt.Fprintf(w, "      %s%s -> %s\n", root.Path, stdinMarker(root.Stdin), orDash(root.Prefix))
+		}
+	}
+	if len(sourceDir.Excluded) != 0 {
+		fmt.Fprintf(w, "    Excluded: %s\n", strings.Join(sourceDir.Excluded, ", "))
+	}
+	for _, group := range sourceDir.VCSEntries.EntryGroups {
+		if len(group) == 0 {
+			continue
+		}
+		// all entries in a group belong to the same repo, so repo details
+		// are the same in each of them
+		entry := group[0]
+		fmt.Fprintf(w, "    Repository (%s): %s\n", entry.provider(), orDash(entry.URI))
+		switch {
+		case entry.Git != nil:
+			printGitSummary(w, entry.Git)
+		case entry.Hg != nil:
+			printRevision(w, entry.Hg.Revision.Node, entry.Hg.Revision.Branch, entry.Hg.Revision.CommitTime)
+			printList(w, "Bookmarks", entry.Hg.Revision.Bookmarks)
+			printList(w, "Tags", entry.Hg.Revision.Tags)
+			printRemotes(w, entry.Hg.Remotes)
+		case entry.JJ != nil:
+			printRevision(w, entry.JJ.WorkingCopy.CommitID, "", entry.JJ.WorkingCopy.CommitTime)
+			fmt.Fprintf(w, "      Change: %s\n", entry.JJ.WorkingCopy.ChangeID)
+			printList(w, "Bookmarks", entry.JJ.WorkingCopy.Bookmarks)
+			printList(w, "Tags", entry.JJ.WorkingCopy.Tags)
+			printRemotes(w, entry.JJ.Remotes)
+		case entry.Dir != nil:
+			if entry.Dir.Source != nil {
+				if entry.Dir.Source.URL != "" {
+					fmt.Fprintf(w, "      Source: %s\n", entry.Dir.Source.URL)
+				}
+				if entry.Dir.Source.Revision != "" {
+					fmt.Fprintf(w, "      Revision: %s\n", entry.Dir.Source.Revision)
+				}
+			}
+			fmt.Fprintf(w, "      Tree Digest: sha256:%s\n", entry.Dir.TreeDigest)
+		}
+
+		status := "unmodified"
+		for _, entry := range group {
+			if len(entry.modifiedFiles()) != 0 {
+				status = "modified"
+				break
+			}
+		}
+		if entry.Dir != nil {
+			status = "not tracked by VCS"
+		}
+		fmt.Fprintf(w, "      Status: %s\n", status)
+	}
+}
+
+func stdinMarker(stdin bool) string {
+	if stdin {
+		return " (stdin)"
+	}
+	return ""
+}
+
+func printGitSummary(w io.Writer, summary *git.GitSummary) {
+	ref := summary.Reference
+	name := ref.Name
+	if name == "HEAD" || name == "" {
+		name = "detached"
+	}
+	// commit hash is only set for objects that are files
+	commit := summary.Object.CommitHash
+	if commit == "" {
+		commit = ref.Hash
+	}
+	printRevision(w, commit, name, ref.CommitTime)
+	fmt.Fprintf(w, "      Signature: %s\n", signatureStatus(ref.Signature))
+	if len(ref.Tags) != 0 {
+		fmt.Fprintf(w, "      Tags:\n")
+		for _, tag := range ref.Tags {
+			fmt.Fprintf(w, "        %s (%s)\n", tag.Name, signatureStatus(tag.Signature))
+		}
+	}
+	if len(summary.Remotes) != 0 {
+		fmt.Fprintf(w, "      Remotes:\n")
+		for _, remote := range sortedKeys(summary.Remotes) {
+			fmt.Fprintf(w, "        %s: %s\n", remote, strings.Join(summary.Remotes[remote], ", "))
+		}
+	}
+}
+
+func printRevision(w io.Writer, commit, ref string, commitTime *time.Time) {
+	fmt.Fprintf(w, "      Commit: %s", orDash(commit))
+	details := []string{}
+	if ref != "" {
+		details = append(details, ref)
+	}
+	if commitTime != nil {
+		details = append(details, commitTime.UTC().Format(time.RFC3339))
+	}
+	if len(details) != 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(details, ", "))
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+func printList(w io.Writer, name string, values []string) {
+	if len(values) != 0 {
+		fmt.Fprintf(w, "      %s: %s\n", name, strings.Join(values, ", "))
+	}
+}
+
+func printRemotes(w io.Writer, remotes map[string]string) {
+	if len(remotes) != 0 {
+		fmt.Fprintf(w, "      Remotes:\n")
+		for _, remote := range sortedKeys(remotes) {
+			fmt.Fprintf(w, "        %s: %s\n", remote, remotes[remote])
+		}
+	}
+}
+
+func signatureStatus(signature *git.Signature) string {
+	switch {
+	case signature == nil || len(signature.PGP) == 0:
+		return "unsigned"
+	case signature.Validated:
+		return "PGP signature, validated"
+	default:
+		return "PGP signature, not validated"
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,269 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/errordeveloper/tape/attest/manifest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+)
+
+func makeStatement(predicateType, predicate string, subjects ...string) attestTypes.ExportedStatement {
+	statement := attestTypes.ExportedStatement{
+		Type:          attestTypes.CurrentStatementType,
+		PredicateType: predicateType,
+		Predicate:     json.RawMessage(predicate),
+	}
+	for _, subject := range subjects {
+		statement.Subject = append(statement.Subject, attestTypes.ResourceDescriptor{
+			Name:   subject,
+			Digest: map[string]string{"sha256": "0000000000000000000000000000000000000000000000000000000000000000"},
+		})
+	}
+	return statement
+}
+
+func imageRefStatement(predicateType, subject, reference string, line, column int) attestTypes.ExportedStatement {
+	field := map[string]string{
+		manifest.OriginalImageRefPredicateType: "foundImageReference",
+		manifest.ResolvedImageRefPredicateType: "resolvedImageReference",
+		manifest.ReplacedImageRefPredicateType: "replacedImageReference",
+	}[predicateType]
+	predicate, err := json.Marshal(map[string]manifest.ImageRefenceWithLocation{
+		field: {Reference: reference, Line: line, Column: column},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return makeStatement(predicateType, string(predicate), subject)
+}
+
+const (
+	digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestDecodeImageRefs(t *testing.T) {
+	testCases := []struct {
+		description string
+		statements  []attestTypes.ExportedStatement
+		expected    []imageRefRow
+	}{
+		{
+			description: "replaced in place",
+			statements: []attestTypes.ExportedStatement{
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "deployment.yaml", "example.com/out:app.1@"+digestA, 10, 16),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "deployment.yaml", "nginx:1.25", 10, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "deployment.yaml", "nginx:1.25@"+digestA, 10, 16),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "cronjob.yaml", "busybox", 12, 20),
+			},
+			expected: []imageRefRow{
+				{file: "cronjob.yaml", line: 12, column: 20, original: "busybox"},
+				{file: "deployment.yaml", line: 10, column: 16, original: "nginx:1.25", resolved: "nginx:1.25@" + digestA, replaced: "example.com/out:app.1@" + digestA},
+			},
+		},
+		{
+			description: "normalised",
+			statements: []attestTypes.ExportedStatement{
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "all.yaml", "nginx:1.25", 8, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "all.yaml", "nginx:1.25@"+digestA, 8, 16),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "all.yaml", "nginx:1.25", 20, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "all.yaml", "nginx:1.25@"+digestA, 20, 16),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "all.yaml", "redis", 22, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "all.yaml", "redis@"+digestB, 22, 16),
+				makeStatement(manifest.ManifestNormalisationPredicateType, `{"normalisedObjects":{"objects":[
+					{"line":13,"column":1,"kind":"Deployment","name":"b","path":"app/deployment-b.yaml"},
+					{"line":1,"column":1,"kind":"Deployment","name":"a","path":"app/deployment-a.yaml"}
+				]}}`, "all.yaml"),
+				// objects are sorted differently, so that locations of the normalised files
+				// don't correspond to the order of the objects in the original manifest
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "app/deployment-b.yaml", "example.com/out:app.b@"+digestA, 3, 14),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "app/deployment-b.yaml", "example.com/out:app.redis@"+digestB, 5, 14),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "app/deployment-a.yaml", "example.com/out:app.a@"+digestA, 30, 14),
+			},
+			expected: []imageRefRow{
+				{file: "all.yaml", line: 8, column: 16, original: "nginx:1.25", resolved: "nginx:1.25@" + digestA, replaced: "example.com/out:app.a@" + digestA},
+				{file: "all.yaml", line: 20, column: 16, original: "nginx:1.25", resolved: "nginx:1.25@" + digestA, replaced: "example.com/out:app.b@" + digestA},
+				{file: "all.yaml", line: 22, column: 16, original: "redis", resolved: "redis@" + digestB, replaced: "example.com/out:app.redis@" + digestB},
+			},
+		},
+		{
+			description: "kustomization",
+			statements: []attestTypes.ExportedStatement{
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "base/deployment.yaml", "nginx", 8, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "base/deployment.yaml", "nginx:1.25@"+digestA, 8, 16),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "base/job.yaml", "nginx", 10, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "base/job.yaml", "nginx:1.25@"+digestA, 10, 16),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "base/job.yaml", "example.com/out:app.1@"+digestA, 10, 16),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "kustomization.yaml", "example.com/out:app.1@"+digestA, 5, 3),
+			},
+			expected: []imageRefRow{
+				{file: "base/deployment.yaml", line: 8, column: 16, original: "nginx", resolved: "nginx:1.25@" + digestA, replaced: "example.com/out:app.1@" + digestA},
+				{file: "base/job.yaml", line: 10, column: 16, original: "nginx", resolved: "nginx:1.25@" + digestA, replaced: "example.com/out:app.1@" + digestA},
+			},
+		},
+		{
+			description: "replaced without counterpart",
+			statements: []attestTypes.ExportedStatement{
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "deployment.yaml", "nginx", 8, 16),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "other.yaml", "example.com/out:app.1@"+digestB, 5, 3),
+				imageRefStatement(manifest.ReplacedImageRefPredicateType, "other.yaml", "redis", 7, 3),
+			},
+			expected: []imageRefRow{
+				{file: "deployment.yaml", line: 8, column: 16, original: "nginx"},
+				{file: "other.yaml", line: 5, column: 3, replaced: "example.com/out:app.1@" + digestB},
+				{file: "other.yaml", line: 7, column: 3, replaced: "redis"},
+			},
+		},
+		{
+			description: "no image references",
+			statements: []attestTypes.ExportedStatement{
+				makeStatement(manifest.ManifestDirPredicateType, `{"containedInDirectory":{"path":"."}}`, "deployment.yaml"),
+			},
+			expected: []imageRefRow{},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			rows, err := decodeImageRefs(tc.statements)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(rows).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestPrintDetails(t *testing.T) {
+	testCases := []struct {
+		description string
+		statements  []attestTypes.ExportedStatement
+		expected    string
+	}{
+		{
+			description: "multiple roots",
+			statements: []attestTypes.ExportedStatement{
+				makeStatement(manifest.ManifestDirPredicateType, `{"containedInDirectory":{
+					"path": "deploy",
+					"roots": [{"path":"deploy","prefix":"deploy"},{"path":"crds","prefix":"crds"}],
+					"vcsEntries": {
+						"providers": ["dir","git"],
+						"entryGroups": [
+							[{
+								"unmodified": true, "path": "deploy", "uri": "https://example.com/app.git", "isDir": true,
+								"git": {
+									"object": {"treeHash": "1111"},
+									"remotes": {"origin": ["https://example.com/app.git"]},
+									"reference": {"name": "refs/heads/main", "hash": "2222", "commitTime": "2024-01-02T03:04:05Z"}
+								}
+							}],
+							[{
+								"unmodified": false, "path": "crds", "isDir": true,
+								"dir": {"source": {"url": "https://example.com/crds.tar.gz"}, "treeDigest": {"sha256": "3333"}}
+							}]
+						]
+					}
+				}}`, "deploy//deploy/deployment.yaml"),
+				imageRefStatement(manifest.OriginalImageRefPredicateType, "deploy//deploy/deployment.yaml", "nginx", 8, 16),
+				imageRefStatement(manifest.ResolvedImageRefPredicateType, "deploy//deploy/deployment.yaml", "nginx:1.25@"+digestA, 8, 16),
+			},
+			expected: `  Source:
+    Path: deploy
+    Roots:
+      deploy -> deploy
+      crds -> crds
+    Repository (git): https://example.com/app.git
+      Commit: 2222 (refs/heads/main, 2024-01-02T03:04:05Z)
+      Signature: unsigned
+      Remotes:
+        origin: https://example.com/app.git
+      Status: unmodified
+    Repository (dir): -
+      Source: https://example.com/crds.tar.gz
+      Tree Digest: sha256:3333
+      Status: not tracked by VCS
+  Image References:
+    FILE                            LINE  ORIGINAL  RESOLVED` + strings.Repeat(" ", len("nginx:1.25@"+digestA)-len("RESOLVED")+2) + `REPLACED
+    deploy//deploy/deployment.yaml  8:16  nginx     nginx:1.25@` + digestA + `  -
+`,
+		},
+		{
+			description: "stdin",
+			statements: []attestTypes.ExportedStatement{
+				makeStatement(manifest.ManifestDirPredicateType, `{"containedInDirectory":{
+					"path": "stdin.yaml",
+					"stdin": true,
+					"vcsEntries": {
+						"providers": ["dir"],
+						"entryGroups": [
+							[{
+								"unmodified": false, "path": "stdin.yaml", "uri": "stdin",
+								"dir": {"source": {"url": "stdin", "revision": "sha256:4444"}, "treeDigest": {"sha256": "4444"}}
+							}]
+						]
+					}
+				}}`, "stdin.yaml"),
+			},
+			expected: `  Source:
+    Path: stdin.yaml (stdin)
+    Repository (dir): stdin
+      Source: stdin
+      Revision: sha256:4444
+      Tree Digest: sha256:4444
+      Status: not tracked by VCS
+`,
+		},
+		{
+			description: "modified files",
+			statements: []attestTypes.ExportedStatement{
+				makeStatement(manifest.ManifestDirPredicateType, `{"containedInDirectory":{
+					"path": ".",
+					"excluded": ["tests/"],
+					"vcsEntries": {
+						"providers": ["git"],
+						"entryGroups": [
+							[{
+								"unmodified": false, "path": ".", "isDir": true,
+								"git": {
+									"object": {"treeHash": "1111"},
+									"reference": {"name": "HEAD", "hash": "2222"},
+									"modifiedFiles": ["deployment.yaml"]
+								}
+							}]
+						]
+					}
+				}}`, "deployment.yaml"),
+			},
+			expected: `  !!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!
+  WARNING: source was modified, manifests may not match any revision in VCS
+  Modified Files:
+    deployment.yaml
+  !!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!
+  Source:
+    Path: .
+    Excluded: tests/
+    Repository (git): -
+      Commit: 2222 (detached)
+      Signature: unsigned
+      Status: modified
+`,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			g := NewWithT(t)
+
+			buf := bytes.NewBuffer(nil)
+			g.Expect(printDetails(buf, tc.statements)).To(Succeed())
+			g.Expect(buf.String()).To(Equal(tc.expected))
+		})
+	}
+}
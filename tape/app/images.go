@@ -8,18 +8,18 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
-	"github.com/in-toto/in-toto-golang/in_toto"
-
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 
+	attestTypes "github.com/errordeveloper/tape/attest/types"
 	"github.com/errordeveloper/tape/manifest/imageresolver"
 	"github.com/errordeveloper/tape/manifest/imagescanner"
-	"github.com/errordeveloper/tape/manifest/loader"
 	"github.com/errordeveloper/tape/manifest/types"
 	"github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/printer"
 )
 
 type TapeImagesCommand struct {
@@ -67,17 +67,31 @@ func (c *TapeImagesCommand) Execute(args []string) error {
 		return fmt.Errorf("unexpected arguments: %v", args)
 	}
 
+	outputPrinter, err := c.newPrinter()
+	if err != nil {
+		return err
+	}
+
 	if err := c.tape.Init(); err != nil {
 		return err
 	}
 
-	loader := loader.NewRecursiveManifestDirectoryLoader(c.ManifestDir)
+	loader, contentKind, err := c.newLoader(ctx, c.tape)
+	if err != nil {
+		return err
+	}
+	c.tape.log.Debugf("loading %q as %s", c.manifestDirs(), contentKind)
 	if err := loader.Load(); err != nil {
 		return fmt.Errorf("failed to load manifests: %w", err)
 	}
 	c.tape.log.Debugf("loaded manifests: %v", loader.Paths())
+	c.tape.emitManifestsLoaded(loader)
 
 	scanner := imagescanner.NewDefaultImageScanner()
+	scanner.WithEvents(c.tape.Events())
+	if err := c.configureScanner(scanner, contentKind); err != nil {
+		return err
+	}
 
 	if err := scanner.Scan(loader.RelPaths()); err != nil {
 		return fmt.Errorf("failed to scan images: %w", err)
@@ -86,7 +100,7 @@ func (c *TapeImagesCommand) Execute(args []string) error {
 	images := scanner.GetImages()
 	c.tape.log.Debugf("found images: %#v", images.Items())
 
-	client := oci.NewClient(nil) // oci.NewDebugClient(os.Stdout, nil)
+	client := c.tape.NewClient()
 	// TODO: use client.LoginWithCredentials() and/or other options
 	// TODO: integrate with docker-credential-helpers
 
@@ -97,7 +111,7 @@ func (c *TapeImagesCommand) Execute(args []string) error {
 		return fmt.Errorf("failed to collect info about images: %w", err)
 	}
 
-	if err := c.PrintInfo(ctx, outputInfo); err != nil {
+	if err := outputPrinter.Print(os.Stdout, newImagesOutput(outputInfo)); err != nil {
 		return fmt.Errorf("failed to print info about images: %w", err)
 	}
 
@@ -177,7 +191,7 @@ func (c *TapeImagesCommand) CollectInfo(ctx context.Context, images *types.Image
 
 			doc := document{
 				MediaType: string(artefact.MediaType),
-				Object:    &in_toto.Statement{},
+				Object:    &attestTypes.ExportedStatement{},
 			}
 
 			if err := json.NewDecoder(artefact).Decode(doc.Object); err != nil {
@@ -191,7 +205,7 @@ func (c *TapeImagesCommand) CollectInfo(ctx context.Context, images *types.Image
 					return fmt.Errorf("attestation manifest %q does not have 'vnd.docker.reference.digest' annotation", artefact.Digest)
 				}
 			} else {
-				statementSubject := doc.Object.(*in_toto.Statement).Subject
+				statementSubject := doc.Object.(*attestTypes.ExportedStatement).Subject
 				if len(statementSubject) == 0 {
 					return fmt.Errorf("statement in %q does not have a subject", artefact.Digest)
 				}
@@ -390,90 +404,125 @@ func (c *TapeImagesCommand) CollectInfo(ctx context.Context, images *types.Image
 	return outputInfo, nil
 }
 
-func (c *TapeImagesCommand) PrintInfo(ctx context.Context, outputInfo map[string]imageInfo) error {
-	stdj := json.NewEncoder(os.Stdout)
+// imagesOutputSchema versions JSON and YAML output of images command
+var imagesOutputSchema = printer.Schema{Kind: "Images", Version: "v1"}
 
-	for _, info := range outputInfo {
-		switch c.OutputFormat {
-		case OutputFormatDirectJSON:
-			stdj.SetIndent("", "  ")
-			if err := stdj.Encode(info); err != nil {
-				return fmt.Errorf("failed to marshal output: %w", err)
-			}
-		case OutputFormatText, OutputFormatDetailedText:
-			// TODO: make this a method of the struct, perhaps use a tag for description
-			fmt.Printf("%s\n", info.Ref)
-			if info.Alias != nil {
-				fmt.Printf("  Alias: %s\n", *info.Alias)
+// imagesOutput lists info about each image ordered by reference
+type imagesOutput []imageInfo
 
-			}
-			fmt.Printf("  Sources:\n")
-			for _, source := range info.Sources {
-				fmt.Printf("    %s %s:%d:%d@sha256:%s\n", source.OriginalRef, source.Manifest, source.Line, source.Column, source.ManifestDigest)
-			}
-			fmt.Printf("  Digest provided: %v\n", info.DigestProvided)
+func newImagesOutput(outputInfo map[string]imageInfo) imagesOutput {
+	output := make(imagesOutput, 0, len(outputInfo))
+	for _, ref := range sortedKeys(outputInfo) {
+		output = append(output, outputInfo[ref])
+	}
+	return output
+}
 
-			if len(info.Manifests) > 0 {
-				fmt.Printf("  OCI manifests:\n")
-				for _, manifest := range info.Manifests {
-					fmt.Printf("    %s  %s  %s  %d\n", manifest.Digest, manifest.MediaType, manifest.Platform.String(), manifest.Size)
-				}
-			}
+func (o imagesOutput) Schema() printer.Schema { return imagesOutputSchema }
+
+func (o imagesOutput) Items() []any {
+	items := make([]any, len(o))
+	for i := range o {
+		items[i] = o[i]
+	}
+	return items
+}
+
+func (o imagesOutput) Table() ([]string, [][]string) {
+	columns := []string{"REF", "ALIAS", "SOURCES", "MANIFESTS", "ATTESTATIONS", "SBOMS", "SIGNATURES"}
+	rows := make([][]string, 0, len(o))
+	for _, info := range o {
+		alias := "-"
+		if info.Alias != nil {
+			alias = *info.Alias
+		}
+		rows = append(rows, []string{
+			info.Ref,
+			alias,
+			strconv.Itoa(len(info.Sources)),
+			strconv.Itoa(len(info.Manifests)),
+			strconv.Itoa(len(info.InlineAttestations) + len(info.ExternalAttestations)),
+			strconv.Itoa(len(info.InlineSBOMs) + len(info.ExternalSBOMs)),
+			strconv.Itoa(len(info.InlineSignatures) + len(info.ExternalSignatures)),
+		})
+	}
+	return columns, rows
+}
+
+func (o imagesOutput) Text(w io.Writer, detailed bool) error {
+	stdj := json.NewEncoder(w)
+
+	for _, info := range o {
+		// TODO: make this a method of the struct, perhaps use a tag for description
+		fmt.Fprintf(w, "%s\n", info.Ref)
+		if info.Alias != nil {
+			fmt.Fprintf(w, "  Alias: %s\n", *info.Alias)
+
+		}
+		fmt.Fprintf(w, "  Sources:\n")
+		for _, source := range info.Sources {
+			fmt.Fprintf(w, "    %s %s:%d:%d@sha256:%s\n", source.OriginalRef, source.Manifest, source.Line, source.Column, source.ManifestDigest)
+		}
+		fmt.Fprintf(w, "  Digest provided: %v\n", info.DigestProvided)
 
-			docsets := map[string]map[string]document{
-				"Inline attestations":   info.InlineAttestations,
-				"External attestations": info.ExternalAttestations,
-				"Inline SBOMs":          info.InlineSBOMs,
-				"External SBOMs":        info.ExternalSBOMs,
-				"Inline signatures":     info.InlineSignatures,
-				"External signatures":   info.ExternalSignatures,
+		if len(info.Manifests) > 0 {
+			fmt.Fprintf(w, "  OCI manifests:\n")
+			for _, manifest := range info.Manifests {
+				fmt.Fprintf(w, "    %s  %s  %s  %d\n", manifest.Digest, manifest.MediaType, manifest.Platform.String(), manifest.Size)
 			}
+		}
 
-			if c.OutputFormat == OutputFormatText {
-				for desc, docset := range docsets {
-					fmt.Printf("  %s: %d\n", desc, len(docset))
-				}
-				break
+		docsets := map[string]map[string]document{
+			"Inline attestations":   info.InlineAttestations,
+			"External attestations": info.ExternalAttestations,
+			"Inline SBOMs":          info.InlineSBOMs,
+			"External SBOMs":        info.ExternalSBOMs,
+			"Inline signatures":     info.InlineSignatures,
+			"External signatures":   info.ExternalSignatures,
+		}
+
+		if !detailed {
+			for desc, docset := range docsets {
+				fmt.Fprintf(w, "  %s: %d\n", desc, len(docset))
 			}
+			continue
+		}
 
-			if len(info.Related) > 0 {
-				fmt.Printf("  Related tags:\n")
-				for relatedTo, related := range info.Related {
-					for _, relatedImage := range related.Items() {
-						fmt.Printf("   %s  %s\n", relatedTo, relatedImage.Ref(true))
-					}
+		if len(info.Related) > 0 {
+			fmt.Fprintf(w, "  Related tags:\n")
+			for relatedTo, related := range info.Related {
+				for _, relatedImage := range related.Items() {
+					fmt.Fprintf(w, "   %s  %s\n", relatedTo, relatedImage.Ref(true))
 				}
 			}
-			if len(info.RelatedUnclassified) > 0 {
-				fmt.Printf("  Related unclassified:\n")
-				for _, ref := range info.RelatedUnclassified {
-					fmt.Printf("    %s\n", ref)
-				}
+		}
+		if len(info.RelatedUnclassified) > 0 {
+			fmt.Fprintf(w, "  Related unclassified:\n")
+			for _, ref := range info.RelatedUnclassified {
+				fmt.Fprintf(w, "    %s\n", ref)
 			}
-			for desc, docset := range docsets {
-				if len(docset) == 0 {
-					fmt.Printf("  %s: <none>\n", desc)
-				} else {
-					fmt.Printf("  %s:\n", desc)
+		}
+		for desc, docset := range docsets {
+			if len(docset) == 0 {
+				fmt.Fprintf(w, "  %s: <none>\n", desc)
+			} else {
+				fmt.Fprintf(w, "  %s:\n", desc)
+			}
+			for subject, doc := range docset {
+				if doc.Object == nil && len(doc.Data) == 0 {
+					fmt.Fprintf(w, "    %s %s: <none>\n", subject, doc.MediaType)
 				}
-				for subject, doc := range docset {
-					if doc.Object == nil && len(doc.Data) == 0 {
-						fmt.Printf("    %s %s: <none>\n", subject, doc.MediaType)
-					}
-					if doc.Object != nil {
-						fmt.Printf("    %s %s:\n        ", subject, doc.MediaType)
-						stdj.SetIndent("        ", "  ")
-						if err := stdj.Encode(doc.Object); err != nil {
-							return fmt.Errorf("failed to marshal output: %w", err)
-						}
-					}
-					if len(doc.Data) > 0 {
-						fmt.Printf("    %s %s: %s\n", subject, doc.MediaType, base64.RawStdEncoding.EncodeToString(doc.Data))
+				if doc.Object != nil {
+					fmt.Fprintf(w, "    %s %s:\n        ", subject, doc.MediaType)
+					stdj.SetIndent("        ", "  ")
+					if err := stdj.Encode(doc.Object); err != nil {
+						return fmt.Errorf("failed to marshal output: %w", err)
 					}
 				}
+				if len(doc.Data) > 0 {
+					fmt.Fprintf(w, "    %s %s: %s\n", subject, doc.MediaType, base64.RawStdEncoding.EncodeToString(doc.Data))
+				}
 			}
-		default:
-			return fmt.Errorf("unsupported output format: %s", c.OutputFormat)
 		}
 	}
 	return nil
@@ -3,31 +3,57 @@ package app
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	kimage "sigs.k8s.io/kustomize/api/image"
 
 	"github.com/errordeveloper/tape/attest"
+	"github.com/errordeveloper/tape/attest/digest"
 	"github.com/errordeveloper/tape/attest/manifest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/vcs/dir"
 	"github.com/errordeveloper/tape/manifest/imagecopier"
 	"github.com/errordeveloper/tape/manifest/imageresolver"
 	"github.com/errordeveloper/tape/manifest/imagescanner"
 	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/manifest/normaliser"
 	"github.com/errordeveloper/tape/manifest/packager"
+	"github.com/errordeveloper/tape/manifest/types"
 	"github.com/errordeveloper/tape/manifest/updater"
-	"github.com/errordeveloper/tape/oci"
+)
+
+const (
+	timestampSourceMtime = "mtime"
+	timestampSourceVCS   = "vcs"
 )
 
 type TapePackageCommand struct {
 	tape *TapeCommand
 	InputManifestDirOptions
+	ValidationOptions
+	SecretsScanOptions
 
 	// WithImages  map[string]string `short:"I" long:"with-images" required:"false" description:"Names of new images to use instead of what specified in the manifests"`
 	OutputImage string `short:"O" long:"output-image" required:"true" description:"Name of the image to push"`
 
+	SourceURL      string `long:"source-url" description:"URL of the source to record in provenance when manifest dir is not in VCS"`
+	SourceRevision string `long:"source-revision" description:"Revision of the source to record in provenance when manifest dir is not in VCS"`
+
+	SourceDateEpoch   string `long:"source-date-epoch" env:"SOURCE_DATE_EPOCH" description:"Unix timestamp to use as artefact creation time, overrides --timestamp-source"`
+	TimestampSource   string `long:"timestamp-source" choice:"mtime" choice:"vcs" default:"mtime" description:"Use most recent file modification time or time of the commit as artefact creation time"`
+	CheckReproducible bool   `long:"check-reproducible" description:"Build the artefact twice and fail if digests don't match before pushing it"`
+
+	Normalise bool `long:"normalise" description:"Write each object to a separate file named '<namespace>/<kind>-<name>.yaml', with keys sorted and non-semantic fields removed"`
+
 	// TODO: implement
 	// Push bool `short:"P" long:"push" description:"Push the resulting image to the registry"`
 }
@@ -52,9 +78,54 @@ func (c *TapePackageCommand) ValidateFlags() error {
 		return invalidOutputImageErr("must not contain upper case characters", name)
 	}
 
+	if err := c.validateSecretsScanFlags(); err != nil {
+		return err
+	}
+
+	if c.SourceDateEpoch != "" {
+		if _, err := strconv.ParseInt(c.SourceDateEpoch, 10, 64); err != nil {
+			return fmt.Errorf("invalid source date epoch %q: %w", c.SourceDateEpoch, err)
+		}
+	}
+
 	return nil
 }
 
+func (c *TapePackageCommand) sourceEpochTimestamp(loader loader.Loader, vcsSummaries []attestTypes.PathCheckSummary) (time.Time, error) {
+	if c.SourceDateEpoch != "" {
+		epoch, err := strconv.ParseInt(c.SourceDateEpoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid source date epoch %q: %w", c.SourceDateEpoch, err)
+		}
+		timestamp := time.Unix(epoch, 0).UTC()
+		c.tape.log.Debugf("using source epoch timestamp %s from source date epoch", timestamp)
+		return timestamp, nil
+	}
+
+	switch c.TimestampSource {
+	case timestampSourceVCS:
+		// when there are multiple roots, the most recent commit is used
+		var timestamp time.Time
+		for _, vcsSummary := range vcsSummaries {
+			summary, ok := vcsSummary.(attestTypes.CommitTimeProvider)
+			if !ok || summary.CommitTime() == nil {
+				return time.Time{}, fmt.Errorf("commit time is not known for %q", vcsSummary.Common().Path)
+			}
+			if commitTime := summary.CommitTime().UTC(); commitTime.After(timestamp) {
+				timestamp = commitTime
+			}
+		}
+		c.tape.log.Debugf("using source epoch timestamp %s from time of the commit", timestamp)
+		return timestamp, nil
+	case timestampSourceMtime:
+		path, timestamp := loader.MostRecentlyModified()
+		c.tape.log.Debugf("using source epoch timestamp %s from most recently modified manifest file %q", timestamp, path)
+		return timestamp, nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp source %q", c.TimestampSource)
+	}
+}
+
 func (c *TapePackageCommand) Execute(args []string) error {
 	ctx := context.WithValue(c.tape.ctx, "command", "package")
 	if len(args) != 0 {
@@ -69,47 +140,102 @@ func (c *TapePackageCommand) Execute(args []string) error {
 		return err
 	}
 
-	loader := loader.NewRecursiveManifestDirectoryLoader(c.ManifestDir)
-	if err := loader.Load(); err != nil {
+	manifestLoader, contentKind, err := c.newLoader(ctx, c.tape)
+	if err != nil {
+		return err
+	}
+	if c.Normalise && contentKind != loader.ContentKindManifests {
+		return fmt.Errorf("normalisation is not supported for content of kind %s", contentKind)
+	}
+	c.tape.log.Debugf("loading %q as %s", c.manifestDirs(), contentKind)
+	if err := manifestLoader.Load(); err != nil {
 		return fmt.Errorf("failed to load manifests: %w", err)
 	}
-	c.tape.log.Debugf("loaded manifests: %v", loader.Paths())
-
-	repoDetected, attreg, err := attest.DetectVCS(c.ManifestDir)
+	c.tape.log.Debugf("loaded manifests: %v", manifestLoader.Paths())
+	c.tape.emitManifestsLoaded(manifestLoader)
+
+	roots := manifestLoader.Roots()
+	attestRoots := c.attestRoots(roots)
+	repoDetected, attreg, err := attest.DetectVCSForRoots(attestRoots, dir.Source{
+		URL:      c.SourceURL,
+		Revision: c.SourceRevision,
+	})
 	if err != nil {
 		return err
 	}
-	/// baseDir := c.ManifestDir
-	if vcsSummary := attreg.BaseDirSummary(); repoDetected && vcsSummary != nil {
-		// baseDir = vcsSummary.Common().Path
+	for i, vcsSummary := range attreg.BaseDirSummaries() {
+		if vcsSummary == nil {
+			continue
+		}
+		if !repoDetected && vcsSummary.ProviderName() == dir.ProviderName && !attestRoots[i].Stdin {
+			c.tape.log.Warnf("path %q is not in VCS, recording plain directory provenance", roots[i].Path)
+		}
 		summaryJSON, err := json.Marshal(vcsSummary.Full())
 		if err != nil {
 			return err
 		}
-		c.tape.log.Infof("VCS info for %q: %s", c.ManifestDir, summaryJSON)
-	} else {
-		c.tape.log.Warnf("path %q is not in VCS", c.ManifestDir)
+		c.tape.log.Infof("VCS info for %q: %s", roots[i].Path, summaryJSON)
+	}
+
+	validationStatements, err := c.validate(manifestLoader, contentKind)
+	if err != nil {
+		return err
+	}
+
+	secretsScanStatements, err := c.scanSecrets(manifestLoader)
+	if err != nil {
+		return err
+	}
+
+	if excluded := manifestLoader.Excluded(); len(excluded) != 0 {
+		c.tape.log.Infof("excluded paths: %s", strings.Join(excluded, ", "))
+		attreg.SetExcludedPaths(excluded)
 	}
 
 	scanner := imagescanner.NewDefaultImageScanner()
 	scanner.WithProvinanceAttestor(attreg)
+	scanner.WithEvents(c.tape.Events())
+	if err := c.configureScanner(scanner, contentKind); err != nil {
+		return err
+	}
 
-	if err := scanner.Scan(loader.RelPaths()); err != nil {
+	if err := scanner.Scan(manifestLoader.RelPaths()); err != nil {
 		return fmt.Errorf("failed to scan images: %w", err)
 	}
 
 	images := scanner.GetImages()
 	c.tape.log.Debugf("found images: %#v", images.Items())
 
+	if err := registerUnscannedFiles(attreg, manifestLoader); err != nil {
+		return err
+	}
+
 	if err := attreg.AssociateCoreStatements(); err != nil {
 		return err
 	}
 
+	if err := attreg.AssociateStatements(validationStatements...); err != nil {
+		return err
+	}
+
+	if err := attreg.AssociateStatements(secretsScanStatements...); err != nil {
+		return err
+	}
+
 	if err := attreg.AssociateStatements(manifest.MakeOriginalImageRefStatements(images)...); err != nil {
 		return err
 	}
 
-	client := oci.NewClient(nil)
+	kustomizationUpdater := updater.NewKustomizationUpdater()
+	if contentKind == loader.ContentKindKustomize {
+		// images that kustomization already transforms are resolved as what
+		// the transformer produces, and the transformer is updated later
+		if err := kustomizationUpdater.ApplyTransformers(images); err != nil {
+			return fmt.Errorf("failed to apply kustomization transformers: %w", err)
+		}
+	}
+
+	client := c.tape.NewClient()
 	// TODO: use client.LoginWithCredentials() and/or other options
 	// TODO: integrate with docker-credential-helpers
 
@@ -126,10 +252,6 @@ func (c *TapePackageCommand) Execute(args []string) error {
 		return fmt.Errorf("failed to dedup images: %w", err)
 	}
 
-	if err := attreg.AssociateStatements(manifest.MakeResovedImageRefStatements(images)...); err != nil {
-		return err
-	}
-
 	c.tape.log.Info("resolving related images")
 	related, err := resolver.FindRelatedTags(ctx, images)
 	if err != nil {
@@ -149,18 +271,63 @@ func (c *TapePackageCommand) Execute(args []string) error {
 	}
 	c.tape.log.Infof("copied images: %s", strings.Join(imageRefs, ", "))
 
+	// statements are made after copying, so that mirrors images were copied from are recorded
+	if err := attreg.AssociateStatements(manifest.MakeResovedImageRefStatements(images)...); err != nil {
+		return err
+	}
+
 	c.tape.log.Info("updating manifest files")
 
-	updater := updater.NewFileUpdater()
-	if err := updater.Update(images); err != nil {
-		return fmt.Errorf("failed to update manifest files: %w", err)
+	var replacedImages *types.ImageList
+	switch contentKind {
+	case loader.ContentKindKustomize:
+		// manifests of kustomize bases are left as they are, and images
+		// are pinned via the images transformer instead
+		if err := kustomizationUpdater.Update(images); err != nil {
+			return fmt.Errorf("failed to update kustomization: %w", err)
+		}
+		attreg.RegisterMutated(kustomizationUpdater.Mutations())
+		replacedImages = kustomizationUpdater.TransformedImages(images)
+	default:
+		fileUpdater := updater.NewFileUpdater()
+		if err := fileUpdater.Update(images); err != nil {
+			return fmt.Errorf("failed to update manifest files: %w", err)
+		}
+		attreg.RegisterMutated(fileUpdater.Mutations())
+		scanner.Reset()
+		if err := scanner.Scan(manifestLoader.RelPaths()); err != nil {
+			return fmt.Errorf("failed to scan updated manifest files: %w", err)
+		}
+		replacedImages = scanner.GetImages()
 	}
-	attreg.RegisterMutated(updater.Mutations())
-	scanner.Reset()
-	if err := scanner.Scan(loader.RelPaths()); err != nil {
-		return fmt.Errorf("failed to scan updated manifest files: %w", err)
+
+	contentDir := images.Dir()
+	if c.Normalise {
+		c.tape.log.Info("normalising manifest files")
+		normaliser := normaliser.NewNormaliser()
+		defer normaliser.Cleanup()
+		if err := normaliser.Normalise(manifestLoader.RelPaths()); err != nil {
+			return fmt.Errorf("failed to normalise manifest files: %w", err)
+		}
+		results := normaliser.Results()
+		if err := attreg.AssociateStatements(manifest.MakeNormalisationStatements(results)...); err != nil {
+			return err
+		}
+		normalised := []attestTypes.Subject{}
+		for _, result := range results {
+			for _, object := range result.Objects {
+				normalised = append(normalised, attestTypes.Subject{Name: object.Path, Digest: object.Digest})
+			}
+		}
+		attreg.RegisterNormalised(normalised...)
+
+		scanner.Reset()
+		if err := scanner.Scan(normaliser.RelPaths()); err != nil {
+			return fmt.Errorf("failed to scan normalised manifest files: %w", err)
+		}
+		replacedImages = scanner.GetImages()
+		contentDir = replacedImages.Dir()
 	}
-	replacedImages := scanner.GetImages()
 	replacedImages.Dedup()
 
 	if err := attreg.AssociateStatements(manifest.MakeReplacedImageRefStatements(replacedImages)...); err != nil {
@@ -179,10 +346,22 @@ func (c *TapePackageCommand) Execute(args []string) error {
 		return []interface{}{"attestations: ", buf.String()}
 	})
 
-	path, sourceEpochTimestamp := loader.MostRecentlyModified()
-	c.tape.log.Debugf("using source epoch timestamp %s from most recently modified manifest file %q", sourceEpochTimestamp, path)
+	sourceEpochTimestamp, err := c.sourceEpochTimestamp(manifestLoader, attreg.BaseDirSummaries())
+	if err != nil {
+		return err
+	}
 	packager := packager.NewDefaultPackager(client, c.OutputImage, &sourceEpochTimestamp, attreg.GetStatements()...)
-	packageRefs, err := packager.Push(ctx, images.Dir())
+	packager.WithContentInterpreter(contentInterpreterFor(contentKind))
+
+	if c.CheckReproducible {
+		digest, err := packager.CheckReproducible(ctx, contentDir)
+		if err != nil {
+			return err
+		}
+		c.tape.log.Infof("artefact is reproducible, digest of both builds is %s", digest)
+	}
+
+	packageRefs, err := packager.Push(ctx, contentDir)
 	if err != nil {
 		return fmt.Errorf("failed to create package: %w", err)
 	}
@@ -195,3 +374,31 @@ func (c *TapePackageCommand) Execute(args []string) error {
 	}
 	return nil
 }
+
+// registerUnscannedFiles registers files that are packaged, but weren't scanned for images,
+// e.g. templates of Helm charts, so that all of the files are covered by statements
+func registerUnscannedFiles(attreg *attest.PathCheckerRegistry, manifestLoader loader.Loader) error {
+	dir, _ := manifestLoader.RelPaths()
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if manifestLoader.ContainsRelPath(relPath) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, _ = hash.Write(data)
+		if err := attreg.Register(relPath, digest.MakeSHA256(hash)); err != nil {
+			return fmt.Errorf("unable to register %q: %w", relPath, err)
+		}
+		return nil
+	})
+}
@@ -1,15 +1,22 @@
 package app
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/attest/verifier"
 	"github.com/errordeveloper/tape/oci"
-	"github.com/fluxcd/pkg/tar"
 )
 
 type TapePullCommand struct {
@@ -18,6 +25,15 @@ type TapePullCommand struct {
 
 	Image        string `short:"I" long:"image" description:"Name of the image to pull" required:"true"`
 	Attestations string `short:"a" long:"attestations" description:"Path to wrtie attestations file"`
+
+	Raw     string   `long:"raw" description:"Path to write content layer to as a compressed tarball, instead of extracting it ('-' for stdout)"`
+	Stdout  bool     `long:"stdout" description:"Print manifests as a single YAML stream, instead of extracting them"`
+	Only    []string `long:"only" description:"Only extract files that match given glob in gitignore syntax, can be repeated"`
+	MaxSize int64    `long:"max-size" description:"Maximum total size of extracted files in bytes, zero means the default limit of 100MiB, negative value means no limit"`
+	Force   bool     `long:"force" description:"Overwrite existing files"`
+
+	Key              string `long:"key" description:"Path to a PEM-encoded public key to verify cosign signatures of the artefact with"`
+	SkipVerification bool   `long:"skip-verification" description:"Don't check digests of files against attestations"`
 }
 
 const regularFileMode = 0o640
@@ -28,59 +44,249 @@ func (c *TapePullCommand) Execute(args []string) error {
 		return fmt.Errorf("unexpected arguments: %v", args)
 	}
 
+	if err := c.validateFlags(); err != nil {
+		return err
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = oci.DefaultMaxContentSize
+	}
+
 	if err := c.tape.Init(); err != nil {
 		return err
 	}
 
-	client := oci.NewClient(nil)
+	var key crypto.PublicKey
+	if c.Key != "" {
+		data, err := os.ReadFile(c.Key)
+		if err != nil {
+			return fmt.Errorf("failed to read public key: %w", err)
+		}
+		key, err = cryptoutils.UnmarshalPEMToPublicKey(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse public key %q: %w", c.Key, err)
+		}
+	}
+
+	client := c.tape.NewClient()
 
-	artefacts, err := client.Fetch(ctx, c.Image, oci.ContentMediaType, oci.AttestMediaType)
+	imageIndex, indexManifest, image, err := client.GetIndexOrImage(ctx, c.Image)
 	if err != nil {
 		return err
 	}
 
+	if key != nil {
+		digest, err := imageOrIndexDigest(imageIndex, image)
+		if err != nil {
+			return err
+		}
+		numSignatures, err := client.VerifySignatures(ctx, c.Image, digest, key)
+		if err != nil {
+			return fmt.Errorf("failed to verify signatures: %w", err)
+		}
+		c.tape.log.Infof("verified %d signatures of %s", numSignatures, digest)
+	}
+
+	artefacts, _, err := client.FetchFromIndexOrImage(ctx, imageIndex, indexManifest, image, append(oci.ContentMediaTypes(), oci.AttestMediaType)...)
+	if err != nil {
+		return err
+	}
+
+	// everything is read and checked before anything gets written
+	var (
+		content          *oci.ArtefactInfo
+		contentData      []byte
+		attestationsData []byte
+		statements       []attestTypes.ExportedStatement
+	)
 	for i := range artefacts {
 		artefact := artefacts[i]
-		switch artefact.MediaType {
-		case oci.ContentMediaType:
-			if err := tar.Untar(artefact, c.OutputManifestDirOptions.ManifestDir, tar.WithMaxUntarSize(-1)); err != nil {
-				return fmt.Errorf("failed to exatract manifests: %w", err)
+		switch {
+		case oci.IsContentMediaType(artefact.MediaType):
+			content = artefact
+			contentData, err = readContent(artefact, c.MaxSize)
+			if err != nil {
+				return fmt.Errorf("failed to read content: %w", err)
 			}
-			c.tape.log.Infof("extracted manifest to %q", c.OutputManifestDirOptions.ManifestDir)
-			// TODO: add mode to just dump the tarball
-		case oci.AttestMediaType:
-			if c.Attestations == "" {
-				break
+		case artefact.MediaType == oci.AttestMediaType:
+			// attestations are validated before being written, so that only
+			// statements of supported in-toto versions get extracted
+			attestationsData, err = io.ReadAll(artefact)
+			if err != nil {
+				return fmt.Errorf("failed to read attestations: %w", err)
 			}
+			statements, err = oci.DecodeAttestations(bytes.NewReader(attestationsData))
+			if err != nil {
+				return fmt.Errorf("invalid attestations: %w", err)
+			}
+		}
+	}
+	if content == nil {
+		return fmt.Errorf("no content found in %q", c.Image)
+	}
 
-			r, w := io.ReadCloser(nil), io.WriteCloser(nil)
+	files, err := oci.ReadContent(bytes.NewReader(contentData), oci.ContentOptions{
+		Only:    c.Only,
+		MaxSize: c.MaxSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read manifests: %w", err)
+	}
+	if len(c.Only) != 0 && len(files) == 0 {
+		c.tape.log.Warnf("no files matched %v", c.Only)
+	}
 
-			if filepath.Ext(c.Attestations) == ".gz" {
-				r = artefact
-			} else {
-				r, err = gzip.NewReader(artefact)
-				if err != nil {
-					return fmt.Errorf("failed to decompress attestations file: %w", err)
-				}
-				defer r.Close()
-			}
+	if c.SkipVerification {
+		c.tape.log.Warn("skipping verification of files against attestations")
+	} else if err := c.verify(statements, files); err != nil {
+		return err
+	}
 
-			if c.Attestations == "-" {
-				w = os.Stdout
-			} else {
-				w, err = os.OpenFile(c.Attestations, os.O_RDWR|os.O_CREATE|os.O_EXCL, regularFileMode)
-				if err != nil {
-					return fmt.Errorf("failed to create attestations file: %w", err)
-				}
-				defer w.Close()
-			}
+	if err := c.writeContent(content.MediaType, contentData, files); err != nil {
+		return err
+	}
+
+	if c.Attestations != "" && attestationsData != nil {
+		if err := c.writeAttestations(attestationsData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TapePullCommand) validateFlags() error {
+	modes := 0
+	for _, set := range []bool{c.ManifestDir != "", c.Raw != "", c.Stdout} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		return fmt.Errorf("exactly one of --manifest-dir, --raw or --stdout must be given")
+	}
+	if c.Raw != "" && len(c.Only) != 0 {
+		return fmt.Errorf("--only cannot be used with --raw")
+	}
+	if (c.Stdout || c.Raw == "-") && c.Attestations == "-" {
+		return fmt.Errorf("content and attestations cannot be both written to stdout")
+	}
+	return nil
+}
+
+// readContent reads compressed content layer, which is expected to be no larger than
+// the files it contains, so it's also bounded by maxSize, unless it's negative
+func readContent(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize < 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("size of compressed content exceeds maximum of %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+func imageOrIndexDigest(imageIndex oci.ImageIndex, image oci.Image) (oci.Hash, error) {
+	if imageIndex != nil {
+		return imageIndex.Digest()
+	}
+	return image.Digest()
+}
+
+// verify checks digest of each file against subjects of attestations
+func (c *TapePullCommand) verify(statements []attestTypes.ExportedStatement, files []oci.ContentFile) error {
+	if len(statements) == 0 {
+		return fmt.Errorf("failed to verify content: %q has no attestations (use --skip-verification to pull it anyway)", c.Image)
+	}
+	v, err := verifier.NewVerifier(statements)
+	if err != nil {
+		return fmt.Errorf("failed to verify content: %w", err)
+	}
+	for _, file := range files {
+		result := v.Verify(file.Path, file.Data)
+		c.tape.log.Debugf("%s: %s (%s)", result.Path, result.Status, strings.Join(result.PredicateTypes, ", "))
+	}
+	summary := v.Summary()
+	c.tape.log.Infof("verification summary: %d verified, %d mismatched, %d not covered by attestations",
+		summary.Verified, summary.Mismatch, summary.Uncovered)
+	if err := summary.Err(); err != nil {
+		return fmt.Errorf("failed to verify content: %w", err)
+	}
+	return nil
+}
 
-			if _, err := io.Copy(w, r); err != nil {
-				return fmt.Errorf("failed to write attestations file: %w", err)
+func (c *TapePullCommand) writeContent(mediaType oci.MediaType, data []byte, files []oci.ContentFile) error {
+	if c.Raw != "" {
+		w := io.WriteCloser(os.Stdout)
+		if c.Raw != "-" {
+			file, err := c.createFile(c.Raw)
+			if err != nil {
+				return fmt.Errorf("failed to create content file: %w", err)
 			}
-			c.tape.log.Infof("extracted attestations to %q", c.Attestations)
+			defer file.Close()
+			w = file
 		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write content file: %w", err)
+		}
+		c.tape.log.Infof("wrote content to %q", c.Raw)
+		return nil
+	}
+
+	if c.Stdout {
+		if mediaType != oci.ContentMediaType {
+			return fmt.Errorf("--stdout is not supported for content of type %q, only plain manifests can be printed", mediaType)
+		}
+		return oci.WriteManifestStream(os.Stdout, files)
+	}
+
+	if err := oci.WriteContentFiles(c.ManifestDir, files, c.Force); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to extract manifests: %w (use --force to overwrite existing files)", err)
+		}
+		return fmt.Errorf("failed to extract manifests: %w", err)
+	}
+	c.tape.log.Infof("extracted %d files to %q", len(files), c.ManifestDir)
+	return nil
+}
 
+func (c *TapePullCommand) writeAttestations(data []byte) error {
+	r, w := io.ReadCloser(io.NopCloser(bytes.NewReader(data))), io.WriteCloser(nil)
+
+	if filepath.Ext(c.Attestations) != ".gz" {
+		var err error
+		r, err = gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress attestations file: %w", err)
+		}
+		defer r.Close()
+	}
+
+	if c.Attestations == "-" {
+		w = os.Stdout
+	} else {
+		file, err := c.createFile(c.Attestations)
+		if err != nil {
+			return fmt.Errorf("failed to create attestations file: %w", err)
+		}
+		defer file.Close()
+		w = file
 	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to write attestations file: %w", err)
+	}
+	c.tape.log.Infof("extracted attestations to %q", c.Attestations)
 	return nil
 }
+
+// createFile creates a new file, existing file is only truncated when --force is given
+func (c *TapePullCommand) createFile(path string) (*os.File, error) {
+	flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if c.Force {
+		flags = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+	return os.OpenFile(path, flags, regularFileMode)
+}
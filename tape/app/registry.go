@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/errordeveloper/tape/trex"
+)
+
+type TapeRegistryCommand struct {
+	Serve TapeRegistryServeCommand `command:"serve" description:"Run a local registry for development"`
+}
+
+type TapeRegistryServeCommand struct {
+	tape *TapeCommand
+
+	Port         int    `short:"p" long:"port" description:"Port to listen on, use 0 to pick a free port" default:"5000"`
+	Storage      string `long:"storage" description:"Type of storage to use" choice:"inmemory" choice:"filesystem" default:"inmemory"`
+	StorageDir   string `long:"storage-dir" description:"Directory to use for filesystem storage"`
+	HtpasswdFile string `long:"htpasswd" description:"Path to htpasswd file, enables basic auth"`
+	CAFile       string `long:"ca-file" description:"Path to write generated CA certificate to, it's printed when not set"`
+	PlainHTTP    bool   `long:"plain-http" description:"Serve plain HTTP without TLS"`
+}
+
+const (
+	registryStorageInMemory   = "inmemory"
+	registryStorageFilesystem = "filesystem"
+)
+
+func (c *TapeRegistryCommand) Execute(args []string) error {
+	return fmt.Errorf("subcommand is required")
+}
+
+func (c *TapeRegistryServeCommand) Execute(args []string) error {
+	ctx := context.WithValue(c.tape.ctx, "command", "registry serve")
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+
+	if err := c.tape.Init(); err != nil {
+		return err
+	}
+
+	options := trex.Options{
+		Port:         c.Port,
+		HtpasswdFile: c.HtpasswdFile,
+		PlainHTTP:    c.PlainHTTP,
+	}
+	switch c.Storage {
+	case registryStorageFilesystem:
+		if c.StorageDir == "" {
+			return fmt.Errorf("--storage-dir must be set for %q storage", registryStorageFilesystem)
+		}
+		options.StorageDir = c.StorageDir
+	case registryStorageInMemory:
+		if c.StorageDir != "" {
+			return fmt.Errorf("--storage-dir cannot be used with %q storage", registryStorageInMemory)
+		}
+	}
+
+	if c.PlainHTTP && c.CAFile != "" {
+		return fmt.Errorf("--ca-file cannot be used with --plain-http")
+	}
+
+	registry := trex.NewWithOptions(options)
+
+	if err := registry.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start registry: %w", err)
+	}
+	defer registry.Close()
+	go func() {
+		<-ctx.Done()
+		if err := registry.Stop(); err != nil {
+			c.tape.log.Errorf("failed to stop registry: %s", err)
+		}
+	}()
+
+	switch {
+	case c.PlainHTTP:
+	case c.CAFile != "":
+		if err := os.WriteFile(c.CAFile, registry.CACert(), regularFileMode); err != nil {
+			return fmt.Errorf("failed to write CA certificate: %w", err)
+		}
+		c.tape.log.Infof("wrote CA certificate to %q", c.CAFile)
+	default:
+		fmt.Printf("%s", registry.CACert())
+	}
+	c.tape.log.Infof("registry is ready at %q", registry.Addr())
+
+	if err := registry.Wait(); err != nil {
+		return fmt.Errorf("registry failed: %w", err)
+	}
+	return nil
+}
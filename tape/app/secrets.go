@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/errordeveloper/tape/attest/manifest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/manifest/secretscanner"
+)
+
+type SecretsScanOptions struct {
+	AllowSecrets     bool     `long:"allow-secrets" description:"Package manifests even if potential secrets were found in them, findings are still reported"`
+	AllowSecretRules []string `long:"allow-secret-rule" description:"Don't fail on findings of given rule, e.g. 'high-entropy', can be repeated"`
+}
+
+func (o *SecretsScanOptions) validateSecretsScanFlags() error {
+	for _, rule := range o.AllowSecretRules {
+		if !slices.Contains(secretscanner.Rules(), rule) {
+			return fmt.Errorf("unknown secrets scan rule %q, known rules are: %s", rule, strings.Join(secretscanner.Rules(), ", "))
+		}
+	}
+	return nil
+}
+
+// scanSecrets looks for potential secrets in manifests, packaging fails when any are
+// found, unless these were allowed; findings are recorded in statements either way
+func (c *TapePackageCommand) scanSecrets(manifestLoader loader.Loader) (attestTypes.Statements, error) {
+	scanner := secretscanner.NewScanner()
+	c.tape.log.Info("scanning manifests for secrets")
+	if err := scanner.Scan(manifestLoader.RelPaths()); err != nil {
+		return nil, fmt.Errorf("failed to scan manifests for secrets: %w", err)
+	}
+
+	for _, finding := range scanner.Findings() {
+		c.tape.log.Warnf("potential secret found in %s: %s %q", finding.Error(), finding.Kind, finding.Name)
+	}
+	if findings := scanner.Findings(c.AllowSecretRules...); !c.AllowSecrets && len(findings) != 0 {
+		return nil, fmt.Errorf("%w (use --allow-secrets or --allow-secret-rule to package anyway)", findings)
+	}
+	return manifest.MakeSecretsScanStatements(c.AllowSecrets, c.AllowSecretRules, scanner.Results()), nil
+}
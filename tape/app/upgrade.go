@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/oci"
+)
+
+type TapeUpgradeCommand struct {
+	tape *TapeCommand
+
+	Image string `short:"I" long:"image" description:"Name of the image to upgrade attestations for" required:"true"`
+}
+
+func (c *TapeUpgradeCommand) Execute(args []string) error {
+	ctx := context.WithValue(c.tape.ctx, "command", "upgrade")
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+
+	if err := c.tape.Init(); err != nil {
+		return err
+	}
+
+	client := c.tape.NewClient()
+
+	imageIndex, indexManifest, _, err := client.GetIndexOrImage(ctx, c.Image)
+	if err != nil {
+		return err
+	}
+	if indexManifest == nil {
+		return fmt.Errorf("no index manifest found for %q", c.Image)
+	}
+
+	imageIndexDigest, err := imageIndex.Digest()
+	if err != nil {
+		return err
+	}
+	_, referrerDigest, referrerManifest, err := client.FetchLatestReferrer(ctx, c.Image, imageIndexDigest, oci.AttestMediaType)
+	if err != nil {
+		return err
+	}
+	if referrerDigest != nil && referrerManifest.Annotations[oci.AttestationsStatementTypeAnnotation] == attestTypes.CurrentStatementType {
+		c.tape.log.Infof("attestations in %q were already upgraded, referrer %s has the current format", c.Image, referrerDigest.String())
+		return nil
+	}
+
+	artefacts, _, err := client.FetchFromIndexOrImage(ctx, imageIndex, indexManifest, nil, oci.AttestMediaType)
+	if err != nil {
+		return err
+	}
+	if len(artefacts) == 0 {
+		return fmt.Errorf("no attestations found in %q", c.Image)
+	}
+
+	annotations := map[string]string{}
+	upgraded := false
+	statements := []attestTypes.ExportedStatement{}
+	for i := range artefacts {
+		decoded, err := oci.DecodeAttestations(artefacts[i])
+		if err != nil {
+			return err
+		}
+		for j := range decoded {
+			if decoded[j].Upgrade() {
+				upgraded = true
+			}
+		}
+		statements = append(statements, decoded...)
+		maps.Copy(annotations, artefacts[i].Annotations)
+	}
+
+	if !upgraded {
+		c.tape.log.Infof("attestations in %q are already in the current format", c.Image)
+		return nil
+	}
+
+	digest, err := client.PushAttestationsReferrer(ctx, c.Image, imageIndex, statements, annotations)
+	if err != nil {
+		return err
+	}
+	c.tape.log.Infof("pushed upgraded attestations for %q as referrer %s", c.Image, digest.String())
+	return nil
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+
+	flags "github.com/thought-machine/go-flags"
+
+	"github.com/errordeveloper/tape/attest/manifest"
+	attestTypes "github.com/errordeveloper/tape/attest/types"
+	"github.com/errordeveloper/tape/manifest/loader"
+	"github.com/errordeveloper/tape/manifest/validator"
+)
+
+type ValidationOptions struct {
+	SkipValidation      bool     `long:"skip-validation" description:"Don't validate manifests before packaging"`
+	KubernetesVersion   string   `long:"kubernetes-version" description:"Version of bundled Kubernetes OpenAPI schemas to validate manifests against"`
+	KubernetesSchema    string   `long:"kubernetes-schema" description:"Kubernetes OpenAPI v2 schema file (e.g. api/openapi-spec/swagger.json from Kubernetes repo) to use instead of bundled schemas"`
+	RejectUnknownFields bool     `long:"reject-unknown-fields" description:"Fail validation when manifests have fields that are not in the schema, instead of warning about these"`
+	CRDSchemas          []string `long:"crd-schema" description:"File with custom resource definitions to validate custom resources against, can be repeated"`
+}
+
+// setValidationDefaults sets defaults that are not known until runtime, so that
+// these are shown in help text
+func setValidationDefaults(command *flags.Command) {
+	if option := command.FindOptionByLongName("kubernetes-version"); option != nil {
+		option.Default = []string{validator.DefaultKubernetesVersion()}
+	}
+}
+
+// newValidator uses schema file when it's given, as it's meant for versions that are not bundled
+func (o *ValidationOptions) newValidator() (*validator.Validator, error) {
+	if o.KubernetesSchema != "" {
+		return validator.NewValidatorWithSchemaFile(o.KubernetesSchema)
+	}
+	return validator.NewValidator(o.KubernetesVersion)
+}
+
+// validate checks manifests against OpenAPI schemas and returns statements
+// that record the result, Helm charts and kustomize bases are not validated
+// as these need to be rendered first
+func (c *TapePackageCommand) validate(manifestLoader loader.Loader, kind loader.ContentKind) (attestTypes.Statements, error) {
+	if c.SkipValidation {
+		return nil, nil
+	}
+	if kind != loader.ContentKindManifests {
+		c.tape.log.Debugf("skipping validation of %s", kind)
+		return nil, nil
+	}
+
+	validator, err := c.newValidator()
+	if err != nil {
+		return nil, err
+	}
+	validator.SetRejectUnknownFields(c.RejectUnknownFields)
+	for _, path := range c.CRDSchemas {
+		if err := validator.AddCRDSchemasFromFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	c.tape.log.Infof("validating manifests against Kubernetes %s schemas", validator.KubernetesVersion())
+	if err := validator.Validate(manifestLoader.RelPaths()); err != nil {
+		return nil, fmt.Errorf("manifest validation failed: %w", err)
+	}
+	for _, warning := range validator.Warnings() {
+		c.tape.log.Warnf("%s, it may be a field that was added in a later version of Kubernetes", warning.Error())
+	}
+	for _, result := range validator.Results() {
+		for _, resource := range result.Resources {
+			if resource.Schema == "" {
+				c.tape.log.Warnf("no schema for %s %q (%s) in %s:%d:%d, it was not validated",
+					resource.Kind, resource.Name, resource.APIVersion, result.Manifest, resource.Line, resource.Column)
+			}
+		}
+	}
+	return manifest.MakeValidationStatements(validator.KubernetesVersion(), validator.Results()), nil
+}
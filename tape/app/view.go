@@ -1,20 +1,17 @@
 package app
 
 import (
-	"bufio"
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-
-	toto "github.com/in-toto/in-toto-golang/in_toto"
+	"strconv"
 
 	"github.com/errordeveloper/tape/attest/manifest"
 	attestTypes "github.com/errordeveloper/tape/attest/types"
 
 	"github.com/errordeveloper/tape/oci"
+	"github.com/errordeveloper/tape/printer"
 )
 
 type TapeViewCommand struct {
@@ -25,14 +22,18 @@ type TapeViewCommand struct {
 }
 
 type artefactInfo struct {
-	AppImages    []string `json:"appImages"`
-	RawManifests struct {
+	Ref                string   `json:"ref"`
+	AppImages          []string `json:"appImages"`
+	ContentInterpreter string   `json:"contentInterpreter,omitempty"`
+	RawManifests       struct {
 		Index   rawManifest[oci.IndexManifest] `json:"index"`
 		Content rawManifest[oci.Manifest]      `json:"content"`
 		Attest  rawManifest[oci.Manifest]      `json:"attest"`
+		// AttestReferrer is set when attestations were upgraded and pushed as a referrer
+		AttestReferrer *rawManifest[oci.Manifest] `json:"attestReferrer,omitempty"`
 	} `json:"rawManifests"`
-	Attestations        []toto.Statement               `json:"attestations"`
-	AttestationsSummary *attestTypes.SummaryAnnotation `json:"attestationsSummary,omitempty"`
+	Attestations        []attestTypes.ExportedStatement `json:"attestations"`
+	AttestationsSummary *attestTypes.SummaryAnnotation  `json:"attestationsSummary,omitempty"`
 }
 
 type rawManifest[T oci.Manifest | oci.IndexManifest] struct {
@@ -46,18 +47,23 @@ func (c *TapeViewCommand) Execute(args []string) error {
 		return fmt.Errorf("unexpected arguments: %v", args)
 	}
 
+	outputPrinter, err := c.newPrinter()
+	if err != nil {
+		return err
+	}
+
 	if err := c.tape.Init(); err != nil {
 		return err
 	}
 
-	client := oci.NewClient(nil)
+	client := c.tape.NewClient()
 
 	outputInfo, err := c.CollectInfo(ctx, client)
 	if err != nil {
 		return fmt.Errorf("failed to collect info about artifact: %w", err)
 	}
 
-	if err := c.PrintInfo(ctx, outputInfo); err != nil {
+	if err := outputPrinter.Print(os.Stdout, outputInfo); err != nil {
 		return fmt.Errorf("failed to print info about artifact: %w", err)
 	}
 
@@ -65,7 +71,8 @@ func (c *TapeViewCommand) Execute(args []string) error {
 }
 
 func (c *TapeViewCommand) CollectInfo(ctx context.Context, client *oci.Client) (*artefactInfo, error) {
-	artefactInfo := &artefactInfo{}
+	// app images are listed even when there are none, so that it's an empty list rather than null
+	artefactInfo := &artefactInfo{AppImages: []string{}}
 
 	imageIndex, indexManifest, _, err := client.GetIndexOrImage(ctx, c.Image)
 	if err != nil {
@@ -80,6 +87,7 @@ func (c *TapeViewCommand) CollectInfo(ctx context.Context, client *oci.Client) (
 		return nil, err
 	}
 
+	artefactInfo.Ref = c.Image
 	artefactInfo.RawManifests.Index = rawManifest[oci.IndexManifest]{
 		Digest:   imageIndexDigest.String(),
 		Manifest: indexManifest,
@@ -96,9 +104,10 @@ func (c *TapeViewCommand) CollectInfo(ctx context.Context, client *oci.Client) (
 
 	for i := range imageInfo {
 		info := imageInfo[i]
-		switch info.MediaType {
-		case oci.ContentMediaType:
-		case oci.AttestMediaType:
+		switch {
+		case oci.IsContentMediaType(info.MediaType):
+			artefactInfo.ContentInterpreter = info.Annotations[oci.ContentInterpreterAnnotation]
+		case info.MediaType == oci.AttestMediaType:
 			if annotation, ok := info.Annotations[oci.AttestationsSummaryAnnotation]; ok {
 				summary, err := attestTypes.UnmarshalSummaryAnnotation(annotation)
 				if err != nil {
@@ -107,37 +116,42 @@ func (c *TapeViewCommand) CollectInfo(ctx context.Context, client *oci.Client) (
 				artefactInfo.AttestationsSummary = summary
 			}
 
-			gr, err := gzip.NewReader(info)
+			statements, err := oci.DecodeAttestations(info)
 			if err != nil {
 				return nil, err
 			}
-			scanner := bufio.NewScanner(gr)
-			for scanner.Scan() {
-				statement := toto.Statement{} // attestTypes.GenericStatement[any]{}
-				if err := json.NewDecoder(bytes.NewBuffer(scanner.Bytes())).Decode(&statement); err != nil {
-					return nil, err
-				}
-				artefactInfo.Attestations = append(artefactInfo.Attestations, statement)
-			}
-			if err := scanner.Err(); err != nil {
-				return nil, err
-			}
-			if err := gr.Close(); err != nil {
+			artefactInfo.Attestations = append(artefactInfo.Attestations, statements...)
+		}
+	}
+
+	// attestations of artefacts that were built with older version of the statement
+	// format may have been upgraded, in which case the upgraded ones are shown
+	referrerInfo, referrerDigest, referrerManifest, err := client.FetchLatestReferrer(ctx, c.Image, imageIndexDigest, oci.AttestMediaType)
+	if err != nil {
+		c.tape.log.Warnf("unable to check for attestations referrers: %s", err)
+	}
+	if len(referrerInfo) > 0 {
+		// when upgrade was done more than once, the latest referrer is shown
+		artefactInfo.RawManifests.AttestReferrer = &rawManifest[oci.Manifest]{
+			Digest:   referrerDigest.String(),
+			Manifest: referrerManifest,
+		}
+		artefactInfo.Attestations = nil
+		for i := range referrerInfo {
+			statements, err := oci.DecodeAttestations(referrerInfo[i])
+			if err != nil {
 				return nil, err
 			}
+			artefactInfo.Attestations = append(artefactInfo.Attestations, statements...)
 		}
 	}
 
 	for _, statement := range artefactInfo.Attestations {
 		if statement.PredicateType == manifest.ReplacedImageRefPredicateType {
-			buf := bytes.NewBuffer(nil)
-			if err := json.NewEncoder(buf).Encode(statement.Predicate); err != nil {
-				return nil, err
-			}
 			predicate := &struct {
 				manifest.ImageRefenceWithLocation `json:"replacedImageReference"`
 			}{}
-			if err := json.NewDecoder(buf).Decode(predicate); err != nil {
+			if err := decodePredicate(statement, predicate); err != nil {
 				return nil, err
 			}
 
@@ -150,52 +164,73 @@ func (c *TapeViewCommand) CollectInfo(ctx context.Context, client *oci.Client) (
 			Digest:   digest.String(),
 			Manifest: manifests[digest],
 		}
-		switch m.Manifest.Config.MediaType {
-		case oci.ContentMediaType:
+		switch {
+		case oci.IsContentMediaType(m.Manifest.Config.MediaType):
 			artefactInfo.RawManifests.Content = m
-		case oci.AttestMediaType:
+		case m.Manifest.Config.MediaType == oci.AttestMediaType:
 			artefactInfo.RawManifests.Attest = m
 		}
 	}
 	return artefactInfo, nil
 }
 
-func (c *TapeViewCommand) PrintInfo(ctx context.Context, outputInfo *artefactInfo) error {
-	stdj := json.NewEncoder(os.Stdout)
-	switch c.OutputFormat {
-	case OutputFormatDirectJSON:
-		stdj.SetIndent("", "  ")
-		if err := stdj.Encode(outputInfo); err != nil {
-			return fmt.Errorf("failed to marshal output: %w", err)
+// artefactOutputSchema versions JSON and YAML output of view command
+var artefactOutputSchema = printer.Schema{Kind: "Artefact", Version: "v1"}
+
+func (info *artefactInfo) Schema() printer.Schema { return artefactOutputSchema }
+
+func (info *artefactInfo) Items() []any { return []any{info} }
+
+func (info *artefactInfo) Table() ([]string, [][]string) {
+	columns := []string{"REF", "DIGEST", "INTERPRETER", "APP IMAGES", "STATEMENTS"}
+	return columns, [][]string{{
+		info.Ref,
+		info.RawManifests.Index.Digest,
+		orDash(info.ContentInterpreter),
+		strconv.Itoa(len(info.AppImages)),
+		strconv.Itoa(len(info.Attestations)),
+	}}
+}
+
+func (info *artefactInfo) Text(w io.Writer, detailed bool) error {
+	fmt.Fprintf(w, "%s\n", info.Ref)
+	fmt.Fprintf(w, "  Digest: %s\n", info.RawManifests.Index.Digest)
+	if info.ContentInterpreter != "" {
+		fmt.Fprintf(w, "  Content Interpreter: %s\n", info.ContentInterpreter)
+	}
+	fmt.Fprintf(w, "  OCI Manifests:\n")
+	fmt.Fprintf(w, "    %s %s\n", info.RawManifests.Content.Digest,
+		info.RawManifests.Content.Manifest.Config.MediaType)
+	fmt.Fprintf(w, "    %s %s\n", info.RawManifests.Attest.Digest,
+		info.RawManifests.Attest.Manifest.Config.MediaType)
+	if referrer := info.RawManifests.AttestReferrer; referrer != nil {
+		fmt.Fprintf(w, "  Upgraded Attestations:\n")
+		fmt.Fprintf(w, "    %s %s\n", referrer.Digest, referrer.Manifest.Annotations[oci.AttestationsStatementTypeAnnotation])
+	}
+	if len(info.AppImages) > 0 {
+		fmt.Fprintf(w, "  App Images:\n")
+		for i := range info.AppImages {
+			fmt.Fprintf(w, "    %s\n", info.AppImages[i])
 		}
-	case OutputFormatText, OutputFormatDetailedText:
-		fmt.Printf("%s\n", c.Image)
-		fmt.Printf("  Digest: %s\n", outputInfo.RawManifests.Index.Digest)
-		fmt.Printf("  OCI Manifests:\n")
-		fmt.Printf("    %s %s\n", outputInfo.RawManifests.Content.Digest,
-			outputInfo.RawManifests.Content.Manifest.Config.MediaType)
-		fmt.Printf("    %s %s\n", outputInfo.RawManifests.Attest.Digest,
-			outputInfo.RawManifests.Attest.Manifest.Config.MediaType)
-		if len(outputInfo.AppImages) > 0 {
-			fmt.Printf("  App Images:\n")
-			for i := range outputInfo.AppImages {
-				fmt.Printf("    %s\n", outputInfo.AppImages[i])
+	}
+	if info.AttestationsSummary != nil {
+		fmt.Fprintf(w, "  Attestations Summary:\n")
+		fmt.Fprintf(w, "    Number of Statements: %v\n", info.AttestationsSummary.NumStamentes)
+		if info.AttestationsSummary.NumStamentes > 0 {
+			fmt.Fprintf(w, "      Predicate Types:\n")
+			for i := range info.AttestationsSummary.PredicateTypes {
+				fmt.Fprintf(w, "        %s\n", info.AttestationsSummary.PredicateTypes[i])
 			}
-		}
-		if outputInfo.AttestationsSummary != nil {
-			fmt.Printf("  Attestations Summary:\n")
-			fmt.Printf("    Number of Statements: %v\n", outputInfo.AttestationsSummary.NumStamentes)
-			if outputInfo.AttestationsSummary.NumStamentes > 0 {
-				fmt.Printf("      Predicate Types:\n")
-				for i := range outputInfo.AttestationsSummary.PredicateTypes {
-					fmt.Printf("        %s\n", outputInfo.AttestationsSummary.PredicateTypes[i])
-				}
-				fmt.Printf("      Subjects:\n")
-				for i := range outputInfo.AttestationsSummary.Subjects {
-					fmt.Printf("        %s@sha256:%s\n", outputInfo.AttestationsSummary.Subjects[i].Name, outputInfo.AttestationsSummary.Subjects[i].Digest.String())
-				}
+			fmt.Fprintf(w, "      Subjects:\n")
+			for i := range info.AttestationsSummary.Subjects {
+				fmt.Fprintf(w, "        %s@sha256:%s\n", info.AttestationsSummary.Subjects[i].Name, info.AttestationsSummary.Subjects[i].Digest.String())
 			}
 		}
 	}
+	if detailed {
+		if err := printDetails(w, info.Attestations); err != nil {
+			return fmt.Errorf("failed to print attestations: %w", err)
+		}
+	}
 	return nil
 }
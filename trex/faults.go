@@ -0,0 +1,162 @@
+package trex
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault describes how requests that match path and method should fail,
+// it's intended for testing how clients deal with misbehaving registries
+type Fault struct {
+	// Path is matched against request path, e.g. `/v2/.*/manifests/.*`
+	Path *regexp.Regexp
+	// Method to match, any method is matched when empty
+	Method string
+	// Times limits number of requests the fault applies to, when 0
+	// it applies to all matching requests
+	Times int
+
+	// StatusCode is returned instead of passing request to the registry
+	StatusCode int
+	// RetryAfter sets Retry-After header for responses with StatusCode
+	RetryAfter time.Duration
+	// Delay is applied before the request is handled
+	Delay time.Duration
+	// Truncate aborts the connection after writing half of the response body
+	Truncate bool
+	// Corrupt appends a byte to the response body, so that it
+	// no longer matches its digest
+	Corrupt bool
+}
+
+type faultRule struct {
+	Fault
+	injected int
+}
+
+// FaultInjector wraps registry handler and injects faults
+// into responses based on the rules it was configured with
+type FaultInjector struct {
+	handler http.Handler
+
+	lock  sync.Mutex
+	rules []*faultRule
+}
+
+func NewFaultInjector(handler http.Handler, faults ...Fault) *FaultInjector {
+	f := &FaultInjector{handler: handler}
+	f.SetFaults(faults...)
+	return f
+}
+
+// SetFaults replaces current rules, first matching rule applies to each request
+func (f *FaultInjector) SetFaults(faults ...Fault) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.rules = make([]*faultRule, len(faults))
+	for i := range faults {
+		f.rules[i] = &faultRule{Fault: faults[i]}
+	}
+}
+
+// Injected returns how many times each of the current rules has been applied
+func (f *FaultInjector) Injected() []int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	injected := make([]int, len(f.rules))
+	for i := range f.rules {
+		injected[i] = f.rules[i].injected
+	}
+	return injected
+}
+
+func (f *FaultInjector) match(r *http.Request) *Fault {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, rule := range f.rules {
+		if rule.Method != "" && rule.Method != r.Method {
+			continue
+		}
+		if rule.Path != nil && !rule.Path.MatchString(r.URL.Path) {
+			continue
+		}
+		if rule.Times > 0 && rule.injected >= rule.Times {
+			continue
+		}
+		rule.injected++
+		fault := rule.Fault
+		return &fault
+	}
+	return nil
+}
+
+func (f *FaultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fault := f.match(r)
+	if fault == nil {
+		f.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fault.StatusCode != 0 {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Round(time.Second).Seconds())))
+		}
+		http.Error(w, http.StatusText(fault.StatusCode), fault.StatusCode)
+		return
+	}
+
+	if !fault.Truncate && !fault.Corrupt {
+		f.handler.ServeHTTP(w, r)
+		return
+	}
+
+	response := &bufferedResponse{header: http.Header{}, statusCode: http.StatusOK}
+	f.handler.ServeHTTP(response, r)
+
+	body := response.body.Bytes()
+	if fault.Corrupt && len(body) > 0 {
+		body = append(body, '\n')
+	}
+	for k, v := range response.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(response.statusCode)
+
+	if fault.Truncate && len(body) > 0 {
+		_, _ = w.Write(body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		// server closes the connection without writing the rest of the response
+		panic(http.ErrAbortHandler)
+	}
+	_, _ = w.Write(body)
+}
+
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(statusCode int) { b.statusCode = statusCode }
+
+func (b *bufferedResponse) Write(data []byte) (int, error) { return b.body.Write(data) }
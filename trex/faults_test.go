@@ -0,0 +1,184 @@
+package trex_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/trex"
+)
+
+func TestFaultInjector(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := NewWithOptions(Options{})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	ref := pushTestImage(g, registry, t.Name())
+	image, err := crane.Pull(ref, registry.CraneOptions()...)
+	g.Expect(err).NotTo(HaveOccurred())
+	layers, err := image.Layers()
+	g.Expect(err).NotTo(HaveOccurred())
+	layerDigest, err := layers[0].Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+	imageDigest, err := image.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	caPool := x509.NewCertPool()
+	g.Expect(caPool.AppendCertsFromPEM(registry.CACert())).To(BeTrue())
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: caPool},
+		},
+	}
+
+	repoRef := strings.TrimSuffix(ref, ":test")
+	repo := strings.TrimPrefix(repoRef, registry.Addr()+"/")
+	manifestPath := fmt.Sprintf("/v2/%s/manifests/test", repo)
+	blobPath := fmt.Sprintf("/v2/%s/blobs/%s", repo, layerDigest.String())
+
+	do := func(method, path string) (*http.Response, []byte, error) {
+		req, err := http.NewRequest(method, "https://"+registry.Addr()+path, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, body, err
+	}
+
+	manifests := regexp.MustCompile(`^/v2/.*/manifests/.*$`)
+	blobs := regexp.MustCompile(`^/v2/.*/blobs/.*$`)
+
+	testCases := []struct {
+		description string
+		faults      []Fault
+		check       func(g *WithT)
+	}{
+		{
+			description: "status code with retry after",
+			faults: []Fault{{
+				Path:       manifests,
+				Method:     http.MethodGet,
+				Times:      1,
+				StatusCode: http.StatusTooManyRequests,
+				RetryAfter: 2 * time.Second,
+			}},
+			check: func(g *WithT) {
+				resp, _, err := do(http.MethodGet, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+				g.Expect(resp.Header.Get("Retry-After")).To(Equal("2"))
+
+				resp, _, err = do(http.MethodGet, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				g.Expect(registry.Faults().Injected()).To(Equal([]int{1}))
+			},
+		},
+		{
+			description: "method is matched",
+			faults: []Fault{{
+				Path:       manifests,
+				Method:     http.MethodGet,
+				StatusCode: http.StatusServiceUnavailable,
+			}},
+			check: func(g *WithT) {
+				resp, _, err := do(http.MethodHead, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				resp, _, err = do(http.MethodGet, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+				_, err = crane.Digest(ref, registry.CraneOptions()...)
+				g.Expect(err).NotTo(HaveOccurred())
+				_, err = crane.Manifest(ref, registry.CraneOptions()...)
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			description: "delay",
+			faults: []Fault{{
+				Path:  manifests,
+				Delay: 500 * time.Millisecond,
+			}},
+			check: func(g *WithT) {
+				start := time.Now()
+				resp, _, err := do(http.MethodGet, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				g.Expect(time.Since(start)).To(BeNumerically(">=", 500*time.Millisecond))
+			},
+		},
+		{
+			description: "truncated blob",
+			faults: []Fault{{
+				Path:     blobs,
+				Truncate: true,
+			}},
+			check: func(g *WithT) {
+				_, _, err := do(http.MethodGet, blobPath)
+				g.Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+
+				image, err := crane.Pull(ref, registry.CraneOptions()...)
+				g.Expect(err).NotTo(HaveOccurred())
+				layers, err := image.Layers()
+				g.Expect(err).NotTo(HaveOccurred())
+				blob, err := layers[0].Compressed()
+				if err == nil {
+					_, err = io.ReadAll(blob)
+				}
+				g.Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			description: "digest mismatch",
+			faults: []Fault{{
+				Path:    manifests,
+				Method:  http.MethodGet,
+				Corrupt: true,
+			}},
+			check: func(g *WithT) {
+				resp, body, err := do(http.MethodGet, manifestPath)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				digest := fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+				g.Expect(resp.Header.Get("Docker-Content-Digest")).NotTo(BeEmpty())
+				g.Expect(resp.Header.Get("Docker-Content-Digest")).NotTo(Equal(digest))
+
+				// client can only detect mismatch when pulling by digest
+				_, err = crane.Manifest(repoRef+"@"+imageDigest.String(), registry.CraneOptions()...)
+				g.Expect(err).To(MatchError(ContainSubstring("does not match")))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.description, func(t *testing.T) {
+			registry.Faults().SetFaults(tc.faults...)
+			t.Cleanup(func() { registry.Faults().SetFaults() })
+
+			tc.check(NewWithT(t))
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -27,20 +28,62 @@ import (
 	_ "github.com/distribution/distribution/v3/registry/storage/driver/middleware/redirect"
 
 	"github.com/distribution/distribution/v3/configuration"
-	"github.com/distribution/distribution/v3/registry"
+	"github.com/distribution/distribution/v3/registry/handlers"
 )
 
 type Trex struct {
-	port                    int
-	caCert, tlsKey, tlsCert string
+	addr     string
+	port     int
+	listener net.Listener
 
 	caPool *x509.CertPool
+	caPEM  []byte
+
+	storageDir   string
+	htpasswdFile string
+	plainHTTP    bool
+
+	faults *FaultInjector
+
+	server   *http.Server
+	done     chan struct{}
+	serveErr error
 }
 
+// Options are used to configure the registry
+type Options struct {
+	// Port to listen on, when 0 a free port is allocated automatically
+	Port int
+	// Listener to serve on, when set Port is ignored, it's closed when
+	// the registry is stopped
+	Listener net.Listener
+	// StorageDir is where blobs and manifests are stored, when
+	// empty in-memory storage is used
+	StorageDir string
+	// HtpasswdFile enables basic auth with credentials from the file
+	HtpasswdFile string
+	// Faults are injected into responses, these can be changed once
+	// the registry is started via Faults().SetFaults
+	Faults []Fault
+	// PlainHTTP disables TLS, no CA is generated in that case
+	PlainHTTP bool
+}
+
+const htpasswdRealm = "trex"
+
 func New(port int) *Trex {
+	return NewWithOptions(Options{Port: port})
+}
+
+func NewWithOptions(options Options) *Trex {
 	return &Trex{
-		port:   port,
-		caPool: x509.NewCertPool(),
+		port:         options.Port,
+		listener:     options.Listener,
+		caPool:       x509.NewCertPool(),
+		storageDir:   options.StorageDir,
+		htpasswdFile: options.HtpasswdFile,
+		plainHTTP:    options.PlainHTTP,
+		faults:       NewFaultInjector(nil, options.Faults...),
 	}
 }
 
@@ -54,102 +97,240 @@ var Shared = struct {
 
 func RunShared() {
 	Shared.Once.Do(func() {
-		go func() {
-			err := Shared.Run(context.Background())
-			if err != nil {
-				panic(err)
-			}
-		}()
-	})
-	for {
-		_, err := (&net.Dialer{Timeout: 2 * time.Second}).
-			DialContext(context.Background(), "tcp", Shared.Addr())
-		if err == nil {
-			break
+		if err := Shared.Start(context.Background()); err != nil {
+			panic(err)
 		}
-	}
+	})
 }
 
+// Faults returns fault injector that all requests pass through
+func (r *Trex) Faults() *FaultInjector { return r.faults }
+
+// CACert returns PEM-encoded CA certificate, it's only set once the registry is started
+// and TLS is enabled
+func (r *Trex) CACert() []byte { return r.caPEM }
+
+// Run starts the registry and blocks until the context is cancelled
 func (r *Trex) Run(ctx context.Context) error {
-	if r.port == 0 {
-		// automatically allocate the port, and use it for the registry;
-		// albeit this can be racy, since registry cannot take a listener
-		// and copying the code here is not worth it
-		l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err := r.Start(ctx); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return r.Stop()
+	case <-r.done:
+		return r.Wait()
+	}
+}
+
+// Start returns once the registry accepts connections, cancelling the context
+// doesn't stop the server, Stop or Close must be called for that
+func (r *Trex) Start(ctx context.Context) (err error) {
+	if r.server != nil {
+		return fmt.Errorf("registry has already been started")
+	}
+
+	if r.htpasswdFile != "" {
+		// registry creates an empty file with random credentials
+		// when one is missing, which is not what the user wants
+		if _, err := os.Stat(r.htpasswdFile); err != nil {
+			return fmt.Errorf("unable to use htpasswd file: %w", err)
+		}
+	}
+	if r.storageDir != "" {
+		if err := os.MkdirAll(r.storageDir, 0o750); err != nil {
+			return fmt.Errorf("unable to create storage directory: %w", err)
+		}
+	}
+
+	if r.listener == nil {
+		r.listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", r.port))
 		if err != nil {
 			return err
 		}
-		r.port = l.Addr().(*net.TCPAddr).Port
-		if err := l.Close(); err != nil {
+	}
+	defer func() {
+		if err != nil {
+			_ = r.listener.Close()
+		}
+	}()
+	r.addr = r.listener.Addr().String()
+	if addr, ok := r.listener.Addr().(*net.TCPAddr); ok {
+		r.port = addr.Port
+	}
+
+	var tlsConfig *tls.Config
+	if !r.plainHTTP {
+		certificate, err := r.generatePKI(ctx)
+		if err != nil {
 			return err
 		}
+		tlsConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*certificate},
+		}
 	}
-	// TODO: return a channel to indicate when the server is ready, or perhpas
-	// just copy what RunShared does and start a goroutine?
-	pkiDir, err := os.MkdirTemp("", "trex-pki-*")
+
+	app, err := r.newApp(ctx)
 	if err != nil {
 		return err
 	}
 
+	r.faults.handler = app
+	r.server = &http.Server{
+		Handler:   r.faults,
+		TLSConfig: tlsConfig,
+	}
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		serve := func() error { return r.server.ServeTLS(r.listener, "", "") }
+		if r.plainHTTP {
+			serve = func() error { return r.server.Serve(r.listener) }
+		}
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.serveErr = err
+		}
+	}()
+	return nil
+}
+
+// Stop shuts down the registry gracefully, waiting for active requests to complete
+func (r *Trex) Stop() error {
+	if r.server == nil {
+		return nil
+	}
+	if err := r.server.Shutdown(context.Background()); err != nil {
+		return err
+	}
+	return r.Wait()
+}
+
+// Close shuts down the registry immediately, closing all connections
+func (r *Trex) Close() error {
+	if r.server == nil {
+		return nil
+	}
+	if err := r.server.Close(); err != nil {
+		return err
+	}
+	return r.Wait()
+}
+
+// Wait blocks until the registry stops serving and returns the error it failed with
+func (r *Trex) Wait() error {
+	if r.done == nil {
+		return nil
+	}
+	<-r.done
+	return r.serveErr
+}
+
+// generatePKI creates a self-signed CA and a certificate for the registry, temporary
+// files are removed before returning, so that nothing is left behind once the server
+// is stopped
+func (r *Trex) generatePKI(ctx context.Context) (*tls.Certificate, error) {
+	pkiDir, err := os.MkdirTemp("", "trex-pki-*")
+	if err != nil {
+		return nil, err
+	}
+
 	defer os.RemoveAll(pkiDir)
 
-	r.caCert = filepath.Join(pkiDir, "ca.crt")
-	r.tlsKey = filepath.Join(pkiDir, "tls.key")
-	r.tlsCert = filepath.Join(pkiDir, "tls.crt")
+	caCertPath := filepath.Join(pkiDir, "ca.crt")
+	tlsKeyPath := filepath.Join(pkiDir, "tls.key")
+	tlsCertPath := filepath.Join(pkiDir, "tls.crt")
 
 	if err := certs.NewGenerator(zerolog.Ctx(ctx)).
 		MakeDevCert(&certs.CertGenConfig{
 			CommonName:  "localhost",
-			CACertPath:  r.caCert,
-			CertKeyPath: r.tlsKey,
-			CertPath:    r.tlsCert,
+			CACertPath:  caCertPath,
+			CertKeyPath: tlsKeyPath,
+			CertPath:    tlsCertPath,
 		}); err != nil {
-		return err
+		return nil, err
 	}
 
-	caCert, err := os.ReadFile(r.caCert)
+	caCert, err := os.ReadFile(caCertPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !r.caPool.AppendCertsFromPEM(caCert) {
-		return fmt.Errorf("failed to setup CA certificate pool")
+		return nil, fmt.Errorf("failed to setup CA certificate pool")
+	}
+	r.caPEM = caCert
+
+	certificate, err := tls.LoadX509KeyPair(tlsCertPath, tlsKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
+
+func (r *Trex) config() *configuration.Configuration {
+	storage := configuration.Storage{
+		"inmemory": configuration.Parameters{},
 	}
+	if r.storageDir != "" {
+		storage = configuration.Storage{
+			"filesystem": configuration.Parameters{
+				"rootdirectory": r.storageDir,
+			},
+		}
+	}
+	storage["delete"] = configuration.Parameters{"enabled": false}
+	storage["maintenance"] = configuration.Parameters{
+		"uploadpurging": map[interface{}]interface{}{
+			"enabled": false,
+		}}
 
 	config := &configuration.Configuration{
-		Storage: configuration.Storage{
-			"inmemory": configuration.Parameters{},
-			"delete":   configuration.Parameters{"enabled": false},
-			"maintenance": configuration.Parameters{
-				"uploadpurging": map[interface{}]interface{}{
-					"enabled": false,
-				}},
-		},
+		Storage: storage,
 		Catalog: configuration.Catalog{
 			MaxEntries: 100,
 		},
 	}
-	config.HTTP.Addr = r.Addr()
-	config.HTTP.TLS.Certificate = r.tlsCert
-	config.HTTP.TLS.Key = r.tlsKey
-
-	registry, err := registry.NewRegistry(ctx, config)
-	if err != nil {
-		return err
+	if r.htpasswdFile != "" {
+		config.Auth = configuration.Auth{
+			"htpasswd": configuration.Parameters{
+				"realm": htpasswdRealm,
+				"path":  r.htpasswdFile,
+			},
+		}
 	}
+	config.HTTP.Addr = r.Addr()
+	return config
+}
 
-	if err = registry.ListenAndServe(); err != nil {
-		return err
-	}
-	return nil
+// newApp creates registry handler, it's used directly instead of registry.NewRegistry
+// so that the listener is owned here and readiness can be signalled
+func (r *Trex) newApp(ctx context.Context) (_ http.Handler, err error) {
+	// registry panics on invalid configuration
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("failed to setup registry: %v", v)
+		}
+	}()
+	return handlers.NewApp(ctx, r.config()), nil
 }
 
+func (r *Trex) Port() int { return r.port }
+
 func (r *Trex) Addr() string {
+	if r.addr != "" {
+		return r.addr
+	}
 	return fmt.Sprintf("127.0.0.1:%d", r.port)
 }
 
-func (r *Trex) CraneOptions() []crane.Option {
+// Transport returns HTTP transport that trusts the CA of the registry
+func (r *Trex) Transport() http.RoundTripper {
 	transport := remote.DefaultTransport.(*http.Transport).Clone()
+	if r.plainHTTP {
+		return transport
+	}
 
 	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
 		if addr != r.Addr() {
@@ -168,9 +349,12 @@ func (r *Trex) CraneOptions() []crane.Option {
 
 		return dialer.DialContext(ctx, network, addr)
 	}
+	return transport
+}
 
+func (r *Trex) CraneOptions() []crane.Option {
 	return []crane.Option{
-		crane.WithTransport(transport),
+		crane.WithTransport(r.Transport()),
 	}
 }
 
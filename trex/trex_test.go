@@ -0,0 +1,74 @@
+package trex_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	. "github.com/onsi/gomega"
+
+	. "github.com/errordeveloper/tape/trex"
+)
+
+func TestParallelRegistries(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprintf("registry-%d", i), func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			g.Expect(err).NotTo(HaveOccurred())
+
+			registry := NewWithOptions(Options{Listener: l})
+			g.Expect(registry.Start(context.Background())).To(Succeed())
+			t.Cleanup(func() { _ = registry.Close() })
+
+			g.Expect(registry.Addr()).To(Equal(l.Addr().String()))
+			g.Expect(registry.CACert()).NotTo(BeEmpty())
+
+			ref := pushTestImage(g, registry, t.Name())
+
+			g.Expect(registry.Stop()).To(Succeed())
+			_, err = crane.Digest(ref, registry.CraneOptions()...)
+			g.Expect(err).To(HaveOccurred())
+
+			g.Expect(registry.Close()).To(Succeed())
+			g.Expect(registry.Start(context.Background())).To(MatchError("registry has already been started"))
+		})
+	}
+}
+
+func TestFilesystemStorage(t *testing.T) {
+	g := NewWithT(t)
+
+	storageDir := t.TempDir()
+
+	registry := NewWithOptions(Options{StorageDir: storageDir})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	ref := pushTestImage(g, registry, t.Name())
+	digest, err := crane.Digest(ref, registry.CraneOptions()...)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(registry.Stop()).To(Succeed())
+
+	// port is reused, so that the reference remains valid
+	registry = NewWithOptions(Options{StorageDir: storageDir, Port: registry.Port()})
+	g.Expect(registry.Start(context.Background())).To(Succeed())
+	t.Cleanup(func() { _ = registry.Close() })
+
+	g.Expect(crane.Digest(ref, registry.CraneOptions()...)).To(Equal(digest))
+}
+
+func pushTestImage(g *WithT, registry *Trex, contents string) string {
+	image, err := crane.Image(map[string][]byte{"test": []byte(contents)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ref := registry.NewUniqueRepoNamer("trex-test")("image") + ":test"
+	g.Expect(crane.Push(image, ref, registry.CraneOptions()...)).To(Succeed())
+
+	expected, err := image.Digest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(crane.Digest(ref, registry.CraneOptions()...)).To(Equal(expected.String()))
+	return ref
+}